@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/lib/logger/handlers/logruspretty"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/export"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// exporter выгружает задачи в CSV, JSON или NDJSON:
+//
+//	go run ./cmd/exporter -format ndjson -status closed -from 2023-09-01 -out tasks.ndjson
+func main() {
+	formatName := flag.String("format", "csv", "output format: csv, json or ndjson")
+	outPath := flag.String("out", "", "output file, stdout if empty")
+	statusName := flag.String("status", "", "task status, e.g. open or closed")
+	clusterID := flag.Int64("cluster", 0, "cluster id")
+	labelNames := flag.String("labels", "", "comma separated labels, tasks must have all of them")
+	from := flag.String("from", "", "created at or after, YYYY-MM-DD or RFC3339")
+	to := flag.String("to", "", "created before, YYYY-MM-DD or RFC3339")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+
+	log := logrus.New()
+	log.SetOutput(os.Stderr)
+	log.SetFormatter(logruspretty.NewPrettyHandler(os.Stderr))
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		log.WithError(err).Fatal("invalid -format")
+	}
+
+	query, err := buildQuery(*statusName, *clusterID, *labelNames, *from, *to)
+	if err != nil {
+		log.WithError(err).Fatal("invalid filters")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	postgre, err := postgresql.New(log, &cfg.Postgres)
+	if err != nil {
+		log.WithError(err).Fatal("failed to connect to database")
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.WithError(err).Fatal("failed to create output file")
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if _, err := export.New(log, postgre).Export(ctx, query, format, out); err != nil {
+		log.WithError(err).Error("export failed")
+		os.Exit(1)
+	}
+}
+
+func buildQuery(statusName string, clusterID int64, labelNames, from, to string) (models.TaskQuery, error) {
+	var query models.TaskQuery
+
+	if statusName != "" {
+		status, err := export.ParseStatus(statusName)
+		if err != nil {
+			return models.TaskQuery{}, err
+		}
+		query.Status = &status
+	}
+	if clusterID > 0 {
+		query.ClusterID = &clusterID
+	}
+	if labelNames != "" {
+		query.Labels = strings.Split(labelNames, ",")
+	}
+	if from != "" {
+		t, err := parseTime(from)
+		if err != nil {
+			return models.TaskQuery{}, err
+		}
+		query.CreatedFrom = &t
+	}
+	if to != "" {
+		t, err := parseTime(to)
+		if err != nil {
+			return models.TaskQuery{}, err
+		}
+		query.CreatedTo = &t
+	}
+
+	return query, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/lib/logger/handlers/logruspretty"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/importer"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// importer загружает историю сообщений чата в задачи:
+//
+//	go run ./cmd/importer -author 1
+func main() {
+	messagesPath := flag.String("messages", "data/dataset_hack.json", "path to the JSON array of chat messages")
+	clustersPath := flag.String("clusters", "data/clustered_messages.csv", "path to the CSV with id and cluster columns")
+	authorID := flag.Int64("author", 0, "id of the user who authors imported thread replies")
+	orphansPath := flag.String("orphans", "", "path to write replies whose root message was not found, as CSV with id and root_id columns")
+	batchSize := flag.Int("batch", importer.DefaultBatchSize, "number of messages checked for re-import at once")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+
+	log := logrus.New()
+	log.SetFormatter(logruspretty.NewPrettyHandler(os.Stdout))
+
+	if *authorID <= 0 {
+		log.Fatal("-author is required")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	postgre, err := postgresql.New(log, &cfg.Postgres)
+	if err != nil {
+		log.WithError(err).Fatal("failed to connect to database")
+	}
+
+	messages, err := os.Open(*messagesPath)
+	if err != nil {
+		log.WithError(err).Fatal("failed to open messages")
+	}
+	defer messages.Close()
+
+	clusters, err := os.Open(*clustersPath)
+	if err != nil {
+		log.WithError(err).Fatal("failed to open cluster labels")
+	}
+	defer clusters.Close()
+
+	var orphans io.Writer
+	if *orphansPath != "" {
+		report, err := os.Create(*orphansPath)
+		if err != nil {
+			log.WithError(err).Fatal("failed to create orphans report")
+		}
+		defer report.Close()
+		orphans = report
+	}
+
+	stats, err := importer.New(log, postgre, *authorID, *batchSize).Import(ctx, messages, clusters, orphans)
+	if err != nil {
+		log.WithError(err).WithField("stats", stats).Error("import stopped, run again to resume")
+		os.Exit(1)
+	}
+
+	log.WithField("stats", stats).Info("import completed")
+}
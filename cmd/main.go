@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/app"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/lib/logger/handlers/logruspretty"
@@ -26,6 +27,11 @@ func main() {
 
 	application := app.New(log, cfg)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	application.RunWorkers(ctx)
+
 	go application.GRPCSrv.MustRun()
 
 	stop := make(chan os.Signal, 1)
@@ -34,6 +40,7 @@ func main() {
 	sign := <-stop
 	log.Info("Aplication stopping", slog.Any("signal", sign))
 
+	cancel()
 	application.GRPCSrv.Stop()
 
 	log.Info("Application stopped!")
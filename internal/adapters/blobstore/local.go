@@ -0,0 +1,159 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	ErrBlobTooLarge = errors.New("blob is too large")
+	ErrInvalidKey   = errors.New("invalid blob key")
+)
+
+// Blob описывает сохранённое содержимое; Key - sha256 содержимого в hex
+type Blob struct {
+	Key  string
+	Size int64
+}
+
+// LocalStore хранит содержимое в файловой системе по его sha256: одинаковые файлы хранятся один раз
+type LocalStore struct {
+	root    string
+	maxSize int64
+}
+
+// NewLocalStore создает хранилище в каталоге root; maxSize ограничивает размер одного файла в байтах
+func NewLocalStore(root string, maxSize int64) (*LocalStore, error) {
+	const op = "blobstore.NewLocalStore"
+
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &LocalStore{root: root, maxSize: maxSize}, nil
+}
+
+// Put сохраняет содержимое r; при превышении maxSize возвращает ErrBlobTooLarge и ничего не сохраняет
+func (s *LocalStore) Put(ctx context.Context, r io.Reader) (Blob, error) {
+	const op = "blobstore.LocalStore.Put"
+
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "upload-*")
+	if err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), &contextReader{ctx: ctx, r: io.LimitReader(r, s.maxSize+1)})
+	if err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if size > s.maxSize {
+		return Blob{}, fmt.Errorf("%s: %w", op, ErrBlobTooLarge)
+	}
+	if err := tmp.Sync(); err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	blob := Blob{Key: hex.EncodeToString(hash.Sum(nil)), Size: size}
+	path := s.path(blob.Key)
+	if _, err := os.Stat(path); err == nil {
+		return blob, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return blob, nil
+}
+
+func (s *LocalStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	const op = "blobstore.LocalStore.Open"
+
+	if !validKey(key) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidKey)
+	}
+
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", op, ErrBlobNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return file, nil
+}
+
+// Exists проверяет, что содержимое с ключом key сохранено
+func (s *LocalStore) Exists(_ context.Context, key string) (bool, error) {
+	const op = "blobstore.LocalStore.Exists"
+
+	if !validKey(key) {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidKey)
+	}
+
+	if _, err := os.Stat(s.path(key)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	const op = "blobstore.LocalStore.Delete"
+
+	if !validKey(key) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidKey)
+	}
+
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// path раскладывает файлы по подкаталогам из первых символов ключа, чтобы не держать всё в одном каталоге
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key[2:4], key)
+}
+
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// contextReader прерывает чтение после отмены контекста
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
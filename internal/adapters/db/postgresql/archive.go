@@ -0,0 +1,281 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrArchivedTaskNotFound = errors.New("archived task not found")
+	ErrTaskNotArchivable    = errors.New("task is not archivable")
+)
+
+// archiveMigrations выполняются после AutoMigrate
+var archiveMigrations = []string{
+	// поиск ссылок архивных задач на содержимое вложений в CountAttachmentsByBlob
+	`CREATE INDEX IF NOT EXISTS idx_archived_tasks_attachments ON archived_tasks USING GIN ((snapshot->'attachments') jsonb_path_ops)`,
+}
+
+func migrateArchive(db *gorm.DB) error {
+	for _, statement := range archiveMigrations {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchivableTaskIDs возвращает задачи в статусах statuses, завершённые раньше completedBefore, начиная с самых старых
+func (p *Postgres) ArchivableTaskIDs(ctx context.Context, statuses []models.TaskStatus, completedBefore time.Time, limit int) ([]int64, error) {
+	const op = "postgresql.Postgres.ArchivableTaskIDs"
+
+	var ids []int64
+	err := p.conn(ctx).Model(&models.Task{}).
+		Where("status IN ? AND completed_at < ?", statuses, completedBefore).
+		Order("completed_at").Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// ArchiveTask переносит задачу в архив вместе с её связями, вложениями и комментариями.
+// Задача должна оставаться в статусах statuses, иначе возвращается ErrTaskNotArchivable
+func (p *Postgres) ArchiveTask(ctx context.Context, taskID int64, statuses []models.TaskStatus) error {
+	const op = "postgresql.Postgres.ArchiveTask"
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		task, err := p.TaskByID(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		if task.CompletedAt == nil || !containsStatus(statuses, task.Status) {
+			return ErrTaskNotArchivable
+		}
+
+		snapshot := models.TaskSnapshot{Task: task}
+		if err := p.conn(ctx).Where("from_task_id = ? OR to_task_id = ?", taskID, taskID).Order("id").Find(&snapshot.Links).Error; err != nil {
+			return err
+		}
+		if err := p.conn(ctx).Where("task_id = ?", taskID).Order("id").Find(&snapshot.Attachments).Error; err != nil {
+			return err
+		}
+		if err := p.conn(ctx).Where("task_id = ?", taskID).Order("id").Find(&snapshot.Comments).Error; err != nil {
+			return err
+		}
+		commentIDs := make([]int64, 0, len(snapshot.Comments))
+		for _, comment := range snapshot.Comments {
+			commentIDs = append(commentIDs, comment.ID)
+		}
+		if len(commentIDs) > 0 {
+			if err := p.conn(ctx).Where("comment_id IN ?", commentIDs).Order("id").Find(&snapshot.CommentRevisions).Error; err != nil {
+				return err
+			}
+		}
+		stripUsers(&snapshot)
+
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+
+		archived := models.ArchivedTask{
+			ID:          task.ID,
+			ClusterID:   task.ClusterID,
+			CompletedAt: *task.CompletedAt,
+			Snapshot:    data,
+		}
+		if err := p.conn(ctx).Create(&archived).Error; err != nil {
+			return err
+		}
+
+		if err := p.conn(ctx).Exec("DELETE FROM task_labels WHERE task_id = ?", taskID).Error; err != nil {
+			return err
+		}
+		// у комментариев нет внешнего ключа на задачу, поэтому они удаляются явно
+		if len(commentIDs) > 0 {
+			if err := p.conn(ctx).Where("comment_id IN ?", commentIDs).Delete(&models.TaskCommentRevision{}).Error; err != nil {
+				return err
+			}
+			if err := p.conn(ctx).Where("task_id = ?", taskID).Delete(&models.TaskComment{}).Error; err != nil {
+				return err
+			}
+		}
+
+		result := p.conn(ctx).Where("id = ? AND version = ?", taskID, task.Version).Delete(&models.Task{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskVersionConflict
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ArchivedTaskByID возвращает задачу из архива в том виде, в котором она была заархивирована
+func (p *Postgres) ArchivedTaskByID(ctx context.Context, taskID int64) (models.Task, error) {
+	const op = "postgresql.Postgres.ArchivedTaskByID"
+
+	_, snapshot, err := p.archivedTask(ctx, taskID)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	task := snapshot.Task
+	if task.UserID != nil {
+		var user models.User
+		if err := p.conn(ctx).Where("id = ?", *task.UserID).Limit(1).Find(&user).Error; err != nil {
+			return models.Task{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if user.ID != 0 {
+			task.User = &user
+		}
+	}
+
+	return task, nil
+}
+
+// RestoreTask возвращает задачу из архива вместе с комментариями. Метки, связи и вложения восстанавливаются,
+// если то, на что они ссылаются, ещё существует
+func (p *Postgres) RestoreTask(ctx context.Context, taskID int64) (models.Task, error) {
+	const op = "postgresql.Postgres.RestoreTask"
+
+	var task models.Task
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		archived, snapshot, err := p.archivedTask(ctx, taskID)
+		if err != nil {
+			return err
+		}
+
+		restored := snapshot.Task
+		restored.ArchivedAt = nil
+		if err := p.conn(ctx).Omit(clause.Associations).Create(&restored).Error; err != nil {
+			return err
+		}
+
+		if len(restored.Labels) > 0 {
+			labelIDs := make([]int64, 0, len(restored.Labels))
+			for _, label := range restored.Labels {
+				labelIDs = append(labelIDs, label.ID)
+			}
+			if err := p.conn(ctx).Exec("INSERT INTO task_labels (task_id, label_id) SELECT ?, id FROM labels WHERE id IN ?", taskID, labelIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, link := range snapshot.Links {
+			other := link.FromTaskID
+			if other == taskID {
+				other = link.ToTaskID
+			}
+
+			var count int64
+			if err := p.conn(ctx).Model(&models.Task{}).Where("id = ?", other).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				continue
+			}
+
+			link.FromTask, link.ToTask, link.CreatedBy = nil, nil, nil
+			if err := p.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&link).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, comment := range snapshot.Comments {
+			comment.Author = nil
+			if err := p.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&comment).Error; err != nil {
+				return err
+			}
+		}
+		for _, revision := range snapshot.CommentRevisions {
+			revision.Editor = nil
+			if err := p.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&revision).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, attachment := range snapshot.Attachments {
+			attachment.Uploader = nil
+			if err := p.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&attachment).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := p.conn(ctx).Delete(&archived).Error; err != nil {
+			return err
+		}
+
+		task, err = p.TaskByID(ctx, taskID)
+		return err
+	})
+	if err != nil {
+		return models.Task{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return task, nil
+}
+
+func (p *Postgres) archivedTask(ctx context.Context, taskID int64) (models.ArchivedTask, models.TaskSnapshot, error) {
+	var archived models.ArchivedTask
+	if err := p.conn(ctx).First(&archived, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ArchivedTask{}, models.TaskSnapshot{}, ErrArchivedTaskNotFound
+		}
+		return models.ArchivedTask{}, models.TaskSnapshot{}, err
+	}
+
+	var snapshot models.TaskSnapshot
+	if err := json.Unmarshal(archived.Snapshot, &snapshot); err != nil {
+		return models.ArchivedTask{}, models.TaskSnapshot{}, err
+	}
+	snapshot.Task.ArchivedAt = &archived.ArchivedAt
+
+	return archived, snapshot, nil
+}
+
+// stripUsers убирает из снимка загруженных пользователей: снимок хранится в jsonb без ограничения срока,
+// и данные учётных записей в нём не нужны
+func stripUsers(snapshot *models.TaskSnapshot) {
+	snapshot.Task.User = nil
+	for i := range snapshot.Links {
+		snapshot.Links[i].CreatedBy = nil
+		snapshot.Links[i].FromTask = nil
+		snapshot.Links[i].ToTask = nil
+	}
+	for i := range snapshot.Attachments {
+		snapshot.Attachments[i].Uploader = nil
+	}
+	for i := range snapshot.Comments {
+		snapshot.Comments[i].Author = nil
+	}
+	for i := range snapshot.CommentRevisions {
+		snapshot.CommentRevisions[i].Editor = nil
+	}
+}
+
+func containsStatus(statuses []models.TaskStatus, status models.TaskStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+func (p *Postgres) SaveAttachment(ctx context.Context, attachment models.Attachment) (models.Attachment, error) {
+	const op = "postgresql.Postgres.SaveAttachment"
+
+	if err := p.conn(ctx).Create(&attachment).Error; err != nil {
+		return models.Attachment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attachment, nil
+}
+
+func (p *Postgres) AttachmentByID(ctx context.Context, id int64) (models.Attachment, error) {
+	const op = "postgresql.Postgres.AttachmentByID"
+
+	var attachment models.Attachment
+	if err := p.conn(ctx).Joins("Uploader").Where("attachments.id = ?", id).First(&attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Attachment{}, fmt.Errorf("%s: %w", op, ErrAttachmentNotFound)
+		}
+
+		return models.Attachment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attachment, nil
+}
+
+// ListAttachments возвращает вложения задачи; commentID != nil оставляет только вложения комментария
+func (p *Postgres) ListAttachments(ctx context.Context, taskID int64, commentID *int64) ([]models.Attachment, error) {
+	const op = "postgresql.Postgres.ListAttachments"
+
+	db := p.conn(ctx).Joins("Uploader").Where("attachments.task_id = ?", taskID)
+	if commentID != nil {
+		db = db.Where("attachments.comment_id = ?", *commentID)
+	}
+
+	var attachments []models.Attachment
+	if err := db.Order("attachments.id").Find(&attachments).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attachments, nil
+}
+
+func (p *Postgres) DeleteAttachment(ctx context.Context, id int64) error {
+	const op = "postgresql.Postgres.DeleteAttachment"
+
+	result := p.conn(ctx).Delete(&models.Attachment{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("%s: %w", op, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAttachmentNotFound)
+	}
+
+	return nil
+}
+
+// LockBlob блокирует содержимое blobKey до конца текущей транзакции. Строки для содержимого нет,
+// поэтому используется транзакционная advisory-блокировка по хешу ключа
+func (p *Postgres) LockBlob(ctx context.Context, blobKey string) error {
+	const op = "postgresql.Postgres.LockBlob"
+
+	if err := p.conn(ctx).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", blobKey).Error; err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CountAttachmentsByBlob возвращает число вложений, ссылающихся на содержимое blobKey, включая вложения
+// заархивированных задач: содержимое должно остаться, чтобы задачу можно было восстановить
+func (p *Postgres) CountAttachmentsByBlob(ctx context.Context, blobKey string) (int64, error) {
+	const op = "postgresql.Postgres.CountAttachmentsByBlob"
+
+	archivedRef, err := json.Marshal([]map[string]string{{"blob_key": blobKey}})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var count int64
+	err = p.conn(ctx).Raw(
+		"SELECT (SELECT COUNT(*) FROM attachments WHERE blob_key = ?) + (SELECT COUNT(*) FROM archived_tasks WHERE snapshot->'attachments' @> ?::jsonb)",
+		blobKey, string(archivedRef),
+	).Scan(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
@@ -55,7 +55,7 @@ func (p *Postgres) UserByEmail(ctx context.Context, email string) (models.User,
 
 	var user models.User
 
-	if err := p.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+	if err := p.conn(ctx).Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
@@ -71,7 +71,7 @@ func (p *Postgres) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	var user models.User
 
-	if err := p.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+	if err := p.conn(ctx).First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
@@ -95,7 +95,7 @@ func (p *Postgres) App(ctx context.Context, appID int) (models.App, error) {
 
 	var app models.App
 
-	if err := p.db.WithContext(ctx).First(&app, appID).Error; err != nil {
+	if err := p.conn(ctx).First(&app, appID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return app, fmt.Errorf("%s: %w", op, ErrAppNotFound)
 		}
@@ -109,7 +109,7 @@ func (p *Postgres) App(ctx context.Context, appID int) (models.App, error) {
 func (p *Postgres) DeleteUser(ctx context.Context, userID int64) error {
 	const op = "postgresql.Postgres.DeleteUser"
 
-	tx := p.db.WithContext(ctx).Begin()
+	tx := p.conn(ctx).Begin()
 	if tx.Error != nil {
 		return fmt.Errorf("%s: %w", op, tx.Error)
 	}
@@ -144,7 +144,7 @@ func (p *Postgres) DeleteUser(ctx context.Context, userID int64) error {
 func (p *Postgres) UpdateUser(ctx context.Context, user models.User) error {
 	const op = "postgresql.Postgres.UpdateUser"
 
-	if err := p.db.WithContext(ctx).Save(user).Error; err != nil {
+	if err := p.conn(ctx).Save(user).Error; err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -13,13 +13,13 @@ var (
 func (p *Postgres) SaveCluster(ctx context.Context, cluster models.Cluster) error {
 	const op = "postgresql.Postgres.SaveCluster"
 
-	return p.db.WithContext(ctx).Create(&cluster).Error
+	return p.conn(ctx).Create(&cluster).Error
 }
 
 func (p *Postgres) UpdateCluster(ctx context.Context, cluster models.Cluster) (models.Cluster, error) {
 	const op = "postgresql.Postgres.UpdateCluster"
 
-	if err := p.db.WithContext(ctx).Save(&cluster).Error; err != nil {
+	if err := p.conn(ctx).Save(&cluster).Error; err != nil {
 		return models.Cluster{}, err
 	}
 
@@ -30,7 +30,7 @@ func (p *Postgres) ClusterByID(ctx context.Context, id int64) (models.Cluster, e
 	const op = "postgresql.Postgres.ClusterByID"
 
 	var cluster models.Cluster
-	if err := p.db.WithContext(ctx).Preload("Tasks").Preload("Cases").First(&cluster, id).Error; err != nil {
+	if err := p.conn(ctx).Preload("Tasks").Preload("Cases").First(&cluster, id).Error; err != nil {
 		return models.Cluster{}, err
 	}
 
@@ -41,7 +41,7 @@ func (p *Postgres) ListClusters(ctx context.Context) ([]models.Cluster, error) {
 	const op = "postgresql.Postgres.ListClusters"
 
 	var clusters []models.Cluster
-	if err := p.db.WithContext(ctx).Preload("Tasks").Preload("Cases").Find(&clusters).Error; err != nil {
+	if err := p.conn(ctx).Preload("Tasks").Preload("Cases").Find(&clusters).Error; err != nil {
 		return nil, err
 	}
 
@@ -52,7 +52,7 @@ func (p *Postgres) ClusterByIndex(ctx context.Context, index int64) (models.Clus
 	const op = "postgresql.Postgres.ClusterByIndex"
 
 	var cluster models.Cluster
-	if err := p.db.WithContext(ctx).Where("cluster_index = ?", index).Preload("Tasks").Preload("Cases").First(&cluster).Error; err != nil {
+	if err := p.conn(ctx).Where("cluster_index = ?", index).Preload("Tasks").Preload("Cases").First(&cluster).Error; err != nil {
 		return models.Cluster{}, err
 	}
 
@@ -0,0 +1,98 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+)
+
+func (p *Postgres) SaveComment(ctx context.Context, comment models.TaskComment) (models.TaskComment, error) {
+	const op = "postgresql.Postgres.SaveComment"
+
+	if err := p.conn(ctx).Create(&comment).Error; err != nil {
+		return models.TaskComment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comment, nil
+}
+
+// UpdateComment сохраняет комментарий вместе с ревизией его предыдущей версии
+func (p *Postgres) UpdateComment(ctx context.Context, comment models.TaskComment, revision models.TaskCommentRevision) error {
+	const op = "postgresql.Postgres.UpdateComment"
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.conn(ctx).Create(&revision).Error; err != nil {
+			return err
+		}
+
+		comment.Author = nil
+		return p.conn(ctx).Save(&comment).Error
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) CommentByID(ctx context.Context, id int64) (models.TaskComment, error) {
+	const op = "postgresql.Postgres.CommentByID"
+
+	var comment models.TaskComment
+	if err := p.conn(ctx).Joins("Author").Where("task_comments.deleted_at IS NULL").First(&comment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.TaskComment{}, fmt.Errorf("%s: %w", op, ErrCommentNotFound)
+		}
+
+		return models.TaskComment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comment, nil
+}
+
+// ListComments возвращает не удаленные комментарии задачи с id больше afterID
+func (p *Postgres) ListComments(ctx context.Context, taskID int64, includeInternal bool, afterID int64, limit int) ([]models.TaskComment, error) {
+	const op = "postgresql.Postgres.ListComments"
+
+	db := p.conn(ctx).Joins("Author").
+		Where("task_comments.task_id = ? AND task_comments.deleted_at IS NULL AND task_comments.id > ?", taskID, afterID)
+	if !includeInternal {
+		db = db.Where("task_comments.visibility = ?", models.CommentVisibilityCustomer)
+	}
+
+	var comments []models.TaskComment
+	if err := db.Order("task_comments.id").Limit(limit).Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comments, nil
+}
+
+func (p *Postgres) ListCommentRevisions(ctx context.Context, commentID int64) ([]models.TaskCommentRevision, error) {
+	const op = "postgresql.Postgres.ListCommentRevisions"
+
+	var revisions []models.TaskCommentRevision
+	if err := p.conn(ctx).Joins("Editor").Where("task_comment_revisions.comment_id = ?", commentID).Order("task_comment_revisions.id").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revisions, nil
+}
+
+// MoveComments переносит все комментарии задачи в другую задачу
+func (p *Postgres) MoveComments(ctx context.Context, fromTaskID, toTaskID int64) error {
+	const op = "postgresql.Postgres.MoveComments"
+
+	err := p.conn(ctx).Model(&models.TaskComment{}).Where("task_id = ?", fromTaskID).Update("task_id", toTaskID).Error
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+)
+
+// ImportedMessages возвращает для уже импортированных сообщений из sourceIDs задачу, в которую они попали:
+// саму задачу для корневых сообщений и задачу комментария для ответов
+func (p *Postgres) ImportedMessages(ctx context.Context, sourceIDs []string) (map[string]int64, error) {
+	const op = "postgresql.Postgres.ImportedMessages"
+
+	imported := make(map[string]int64, len(sourceIDs))
+	if len(sourceIDs) == 0 {
+		return imported, nil
+	}
+
+	var rows []struct {
+		SourceMessageID string
+		TaskID          int64
+	}
+	err := p.conn(ctx).Raw(`SELECT source_message_id, id AS task_id FROM tasks WHERE source_message_id IN ?
+		UNION ALL
+		SELECT source_message_id, task_id FROM task_comments WHERE source_message_id IN ?`, sourceIDs, sourceIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, row := range rows {
+		imported[row.SourceMessageID] = row.TaskID
+	}
+
+	return imported, nil
+}
+
+// EnsureCluster возвращает кластер с индексом cluster.ClusterIndex, создавая его, если его нет
+func (p *Postgres) EnsureCluster(ctx context.Context, cluster models.Cluster) (models.Cluster, error) {
+	const op = "postgresql.Postgres.EnsureCluster"
+
+	err := p.conn(ctx).Where(models.Cluster{ClusterIndex: cluster.ClusterIndex}).
+		Attrs(models.Cluster{Name: cluster.Name, Frequency: cluster.Frequency}).
+		FirstOrCreate(&cluster).Error
+	if err != nil {
+		return models.Cluster{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cluster, nil
+}
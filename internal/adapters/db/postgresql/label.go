@@ -0,0 +1,188 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLabelNotFound = errors.New("label not found")
+	ErrLabelExists   = errors.New("label already exists")
+)
+
+func (p *Postgres) SaveLabel(ctx context.Context, label models.Label) (models.Label, error) {
+	const op = "postgresql.Postgres.SaveLabel"
+
+	if err := p.conn(ctx).Create(&label).Error; err != nil {
+		if isUniqueViolation(err) {
+			return models.Label{}, fmt.Errorf("%s: %w", op, ErrLabelExists)
+		}
+
+		return models.Label{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return label, nil
+}
+
+func (p *Postgres) UpdateLabel(ctx context.Context, label models.Label) error {
+	const op = "postgresql.Postgres.UpdateLabel"
+
+	if err := p.conn(ctx).Save(&label).Error; err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, ErrLabelExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteLabel удаляет метку из каталога и снимает её со всех задач
+func (p *Postgres) DeleteLabel(ctx context.Context, labelID int64) error {
+	const op = "postgresql.Postgres.DeleteLabel"
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.conn(ctx).Exec("DELETE FROM task_labels WHERE label_id = ?", labelID).Error; err != nil {
+			return err
+		}
+
+		result := p.conn(ctx).Delete(&models.Label{}, labelID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLabelNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) LabelByID(ctx context.Context, labelID int64) (models.Label, error) {
+	const op = "postgresql.Postgres.LabelByID"
+
+	var label models.Label
+	if err := p.conn(ctx).Where("id = ?", labelID).First(&label).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Label{}, fmt.Errorf("%s: %w", op, ErrLabelNotFound)
+		}
+
+		return models.Label{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return label, nil
+}
+
+func (p *Postgres) ListLabels(ctx context.Context) ([]models.Label, error) {
+	const op = "postgresql.Postgres.ListLabels"
+
+	var labels []models.Label
+	if err := p.conn(ctx).Order("name").Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return labels, nil
+}
+
+// EnsureLabels возвращает метки по именам, создавая отсутствующие в каталоге с цветом color
+func (p *Postgres) EnsureLabels(ctx context.Context, names []string, color string) ([]models.Label, error) {
+	const op = "postgresql.Postgres.EnsureLabels"
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	labels := make([]models.Label, 0, len(names))
+	for _, name := range names {
+		labels = append(labels, models.Label{Name: name, Color: color})
+	}
+
+	db := p.conn(ctx)
+	if err := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&labels).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	labels = nil
+	if err := db.Where("name IN ?", names).Order("name").Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return labels, nil
+}
+
+func (p *Postgres) AddTaskLabels(ctx context.Context, taskID int64, labels []models.Label) error {
+	const op = "postgresql.Postgres.AddTaskLabels"
+
+	if err := p.conn(ctx).Model(&models.Task{ID: taskID}).Association("Labels").Append(&labels); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) RemoveTaskLabels(ctx context.Context, taskID int64, labels []models.Label) error {
+	const op = "postgresql.Postgres.RemoveTaskLabels"
+
+	if err := p.conn(ctx).Model(&models.Task{ID: taskID}).Association("Labels").Delete(&labels); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TaskCountsByLabel возвращает количество задач по меткам среди задач, подходящих под фильтры q
+func (p *Postgres) TaskCountsByLabel(ctx context.Context, q models.TaskQuery) ([]models.LabelCount, error) {
+	const op = "postgresql.Postgres.TaskCountsByLabel"
+
+	var rows []struct {
+		LabelID int64
+		Total   int64
+		Active  int64
+		Closed  int64
+		Fire    int64
+	}
+	db := applyTaskFilters(p.conn(ctx).Model(&models.Task{}), q).
+		Select(`task_labels.label_id AS label_id,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE tasks.status NOT IN ?) AS active,
+			COUNT(*) FILTER (WHERE tasks.status = ?) AS closed,
+			COUNT(*) FILTER (WHERE tasks.fire) AS fire`,
+			[]models.TaskStatus{models.TaskStatusClosed, models.TaskStatusCancelled}, models.TaskStatusClosed).
+		Joins("JOIN task_labels ON task_labels.task_id = tasks.id").
+		Group("task_labels.label_id")
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	labels, err := p.ListLabels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	byID := make(map[int64]models.Label, len(labels))
+	for _, label := range labels {
+		byID[label.ID] = label
+	}
+
+	counts := make([]models.LabelCount, 0, len(rows))
+	for _, row := range rows {
+		counts = append(counts, models.LabelCount{
+			Label:  byID[row.LabelID],
+			Total:  row.Total,
+			Active: row.Active,
+			Closed: row.Closed,
+			Fire:   row.Fire,
+		})
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,99 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrOutboxMessageNotFound = errors.New("outbox message not found")
+)
+
+func (p *Postgres) SaveOutboxMessages(ctx context.Context, messages ...models.OutboxMessage) error {
+	const op = "postgresql.Postgres.SaveOutboxMessages"
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if err := p.conn(ctx).Create(&messages).Error; err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClaimOutboxMessages выбирает готовые к отправке сообщения и откладывает их повтор на lease,
+// чтобы параллельные обработчики не взяли те же сообщения
+func (p *Postgres) ClaimOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxMessage, error) {
+	const op = "postgresql.Postgres.ClaimOutboxMessages"
+
+	var messages []models.OutboxMessage
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.conn(ctx).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+
+		return p.conn(ctx).Model(&models.OutboxMessage{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return messages, nil
+}
+
+func (p *Postgres) UpdateOutboxMessage(ctx context.Context, message models.OutboxMessage) error {
+	const op = "postgresql.Postgres.UpdateOutboxMessage"
+
+	if err := p.conn(ctx).Save(&message).Error; err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) OutboxMessageByID(ctx context.Context, id int64) (models.OutboxMessage, error) {
+	const op = "postgresql.Postgres.OutboxMessageByID"
+
+	var message models.OutboxMessage
+	if err := p.conn(ctx).First(&message, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.OutboxMessage{}, fmt.Errorf("%s: %w", op, ErrOutboxMessageNotFound)
+		}
+
+		return models.OutboxMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return message, nil
+}
+
+func (p *Postgres) ListOutboxMessages(ctx context.Context, status models.OutboxStatus, limit int) ([]models.OutboxMessage, error) {
+	const op = "postgresql.Postgres.ListOutboxMessages"
+
+	var messages []models.OutboxMessage
+	if err := p.conn(ctx).Where("status = ?", status).Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return messages, nil
+}
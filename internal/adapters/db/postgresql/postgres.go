@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
 	"github.com/sirupsen/logrus"
@@ -16,6 +17,38 @@ type Postgres struct {
 	db *gorm.DB
 }
 
+type txKey struct{}
+
+// Transaction выполняет fn в транзакции; методы Postgres, вызванные с контекстом fn, работают в ней.
+// Вложенный вызов использует уже открытую транзакцию.
+func (p *Postgres) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// codeUniqueViolation код ошибки PostgreSQL при нарушении уникального ограничения
+const codeUniqueViolation = "23505"
+
+// isUniqueViolation сообщает, нарушил ли запрос уникальное ограничение
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == codeUniqueViolation
+}
+
+// conn возвращает транзакцию из контекста или общее подключение
+func (p *Postgres) conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return p.db.WithContext(ctx)
+}
+
 func (p *Postgres) ListTasksUserID(ctx context.Context, userID int64) ([]models.Task, error) {
 	//TODO implement me
 	panic("implement me")
@@ -55,13 +88,23 @@ func Migrate(log *logrus.Logger, db *gorm.DB) error {
 
 	log.Info("execute database migrations")
 
-	if err := db.AutoMigrate(&models.User{}, &models.App{}, &models.Cluster{}, &models.Task{}, models.Case{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.App{}, &models.Cluster{}, &models.Requester{}, &models.Task{}, models.Case{}, &models.TaskEvent{}, &models.OutboxMessage{}, &models.Label{}, &models.TaskComment{}, &models.TaskCommentRevision{}, &models.TaskLink{}, &models.Attachment{}, &models.ArchivedTask{}, &models.TaskTemplate{}); err != nil {
 		log.WithError(err).Error("failed to migrate user model")
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("models migrated")
 
+	if err := migrateArchive(db); err != nil {
+		log.WithError(err).Error("failed to migrate archive indexes")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := migrateSearch(db); err != nil {
+		log.WithError(err).Error("failed to migrate search indexes")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	// Проверяем, существует ли запись приложения с заданным ID
 	var existingApp models.App
 	if err := db.First(&existingApp, "id = ?", 1).Error; err != nil {
@@ -0,0 +1,72 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrRequesterNotFound = errors.New("requester not found")
+
+// EnsureRequester возвращает клиента с внешним идентификатором requester.ExternalID, создавая его, если его нет.
+// Канал и имя существующего клиента обновляются, если переданы; уровень обслуживания не меняется
+func (p *Postgres) EnsureRequester(ctx context.Context, requester models.Requester) (models.Requester, error) {
+	const op = "postgresql.Postgres.EnsureRequester"
+
+	updates := make([]string, 0, 2)
+	if requester.Channel != "" {
+		updates = append(updates, "channel")
+	}
+	if requester.DisplayName != "" {
+		updates = append(updates, "display_name")
+	}
+
+	conflict := clause.OnConflict{Columns: []clause.Column{{Name: "external_id"}}}
+	if len(updates) > 0 {
+		conflict.DoUpdates = clause.AssignmentColumns(append(updates, "updated_at"))
+	} else {
+		conflict.DoNothing = true
+	}
+
+	if err := p.conn(ctx).Clauses(conflict).Create(&requester).Error; err != nil {
+		return models.Requester{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var saved models.Requester
+	if err := p.conn(ctx).Where("external_id = ?", requester.ExternalID).First(&saved).Error; err != nil {
+		return models.Requester{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+func (p *Postgres) RequesterByID(ctx context.Context, id int64) (models.Requester, error) {
+	const op = "postgresql.Postgres.RequesterByID"
+
+	var requester models.Requester
+	if err := p.conn(ctx).First(&requester, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Requester{}, fmt.Errorf("%s: %w", op, ErrRequesterNotFound)
+		}
+		return models.Requester{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requester, nil
+}
+
+func (p *Postgres) SetRequesterTier(ctx context.Context, id int64, tier models.RequesterTier) (models.Requester, error) {
+	const op = "postgresql.Postgres.SetRequesterTier"
+
+	result := p.conn(ctx).Model(&models.Requester{}).Where("id = ?", id).Update("tier", tier)
+	if result.Error != nil {
+		return models.Requester{}, fmt.Errorf("%s: %w", op, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return models.Requester{}, fmt.Errorf("%s: %w", op, ErrRequesterNotFound)
+	}
+
+	return p.RequesterByID(ctx, id)
+}
@@ -0,0 +1,169 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"html"
+	"strings"
+)
+
+// searchConfig конфигурация полнотекстового поиска PostgreSQL
+const searchConfig = "russian"
+
+// ts_headline выделяет совпадения символами из области частного использования Unicode, а не разметкой:
+// текст задач пользовательский и экранируется в Go, после чего выделение заменяется на <b></b>
+const (
+	headlineStartSel = "\uE000"
+	headlineStopSel  = "\uE001"
+)
+
+const headlineOptions = "StartSel=" + headlineStartSel + ", StopSel=" + headlineStopSel + ", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \""
+
+var headlineReplacer = strings.NewReplacer(headlineStartSel, "<b>", headlineStopSel, "</b>")
+
+// headline экранирует HTML во фрагменте ts_headline и размечает выделенные совпадения
+func headline(snippet string) string {
+	return headlineReplacer.Replace(html.EscapeString(snippet))
+}
+
+// searchMigrations добавляют вычисляемые tsvector-колонки и GIN-индексы; выполняются после AutoMigrate
+var searchMigrations = []string{
+	`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+		setweight(to_tsvector('russian', coalesce(solution, '')), 'C')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector)`,
+	`ALTER TABLE cases ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('russian', coalesce(solution, '')), 'B')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_cases_search_vector ON cases USING GIN (search_vector)`,
+}
+
+func migrateSearch(db *gorm.DB) error {
+	for _, statement := range searchMigrations {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type searchRow struct {
+	ID      int64
+	Rank    float64
+	Snippet string
+}
+
+// SearchTasks ищет задачи по заголовку, описанию и решению, лучшие совпадения первыми
+func (p *Postgres) SearchTasks(ctx context.Context, q models.TaskSearchQuery) ([]models.TaskSearchHit, error) {
+	const op = "postgresql.Postgres.SearchTasks"
+
+	ranked := applyTaskFilters(p.conn(ctx).Table("tasks"), models.TaskQuery{Statuses: q.Statuses, ClusterID: q.ClusterID}).
+		Select("tasks.id, ts_rank(tasks.search_vector, query) AS rank").
+		Joins("CROSS JOIN websearch_to_tsquery(?, ?) AS query", searchConfig, q.Text).
+		Where("tasks.search_vector @@ query")
+
+	var rows []searchRow
+	err := p.conn(ctx).Table("(?) AS hits", paginate(ranked, q.Limit, q.Offset)).
+		Select("hits.id, hits.rank, ts_headline(?, tasks.title || ' ' || tasks.description || ' ' || coalesce(tasks.solution, ''), websearch_to_tsquery(?, ?), ?) AS snippet",
+			searchConfig, searchConfig, q.Text, headlineOptions).
+		Joins("JOIN tasks ON tasks.id = hits.id").
+		Order("hits.rank DESC, hits.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var tasks []models.Task
+	if err := p.conn(ctx).Joins("User").Joins("Case").Joins("Cluster").Preload("Labels").
+		Where("tasks.id IN ?", searchIDs(rows)).Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	byID := make(map[int64]models.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	hits := make([]models.TaskSearchHit, 0, len(rows))
+	for _, row := range rows {
+		if task, ok := byID[row.ID]; ok {
+			hits = append(hits, models.TaskSearchHit{Task: task, Rank: row.Rank, Snippet: headline(row.Snippet)})
+		}
+	}
+
+	return hits, nil
+}
+
+// SearchCases ищет кейсы по заголовку и решению, лучшие совпадения первыми
+func (p *Postgres) SearchCases(ctx context.Context, q models.CaseSearchQuery) ([]models.CaseSearchHit, error) {
+	const op = "postgresql.Postgres.SearchCases"
+
+	ranked := p.conn(ctx).Table("cases").
+		Select("cases.id, ts_rank(cases.search_vector, query) AS rank").
+		Joins("CROSS JOIN websearch_to_tsquery(?, ?) AS query", searchConfig, q.Text).
+		Where("cases.search_vector @@ query")
+	if q.ClusterID != nil {
+		ranked = ranked.Where("cases.cluster_id = ?", *q.ClusterID)
+	}
+
+	var rows []searchRow
+	err := p.conn(ctx).Table("(?) AS hits", paginate(ranked, q.Limit, q.Offset)).
+		Select("hits.id, hits.rank, ts_headline(?, cases.title || ' ' || coalesce(cases.solution, ''), websearch_to_tsquery(?, ?), ?) AS snippet",
+			searchConfig, searchConfig, q.Text, headlineOptions).
+		Joins("JOIN cases ON cases.id = hits.id").
+		Order("hits.rank DESC, hits.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var cases []models.Case
+	if err := p.conn(ctx).Joins("Cluster").Where("cases.id IN ?", searchIDs(rows)).Find(&cases).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	byID := make(map[int64]models.Case, len(cases))
+	for _, caseItem := range cases {
+		byID[caseItem.ID] = caseItem
+	}
+
+	hits := make([]models.CaseSearchHit, 0, len(rows))
+	for _, row := range rows {
+		if caseItem, ok := byID[row.ID]; ok {
+			hits = append(hits, models.CaseSearchHit{Case: caseItem, Rank: row.Rank, Snippet: headline(row.Snippet)})
+		}
+	}
+
+	return hits, nil
+}
+
+// paginate сортирует по рангу и ограничивает выборку до построения фрагментов, которое дороже ранжирования
+func paginate(db *gorm.DB, limit, offset int) *gorm.DB {
+	db = db.Order("rank DESC").Order("id")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if offset > 0 {
+		db = db.Offset(offset)
+	}
+	return db
+}
+
+func searchIDs(rows []searchRow) []int64 {
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
@@ -6,17 +6,19 @@ import (
 	"fmt"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
-	ErrTaskNotFound = errors.New("tasks not found")
+	ErrTaskNotFound        = errors.New("tasks not found")
+	ErrTaskVersionConflict = errors.New("task was modified concurrently")
 )
 
 func (p *Postgres) TaskByID(ctx context.Context, id int64) (models.Task, error) {
 	const op = "postgresql.Postgres.TaskByID"
 
 	var task models.Task
-	if err := p.db.WithContext(ctx).Joins("User").Joins("Case").Joins("Cluster").First(&task, id).Error; err != nil {
+	if err := p.conn(ctx).Joins("User").Joins("Case").Joins("Cluster").Joins("Requester").Preload("Labels").First(&task, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return models.Task{}, fmt.Errorf("%s: %w", op, ErrTaskNotFound)
 		}
@@ -30,40 +32,81 @@ func (p *Postgres) TaskByID(ctx context.Context, id int64) (models.Task, error)
 	return task, nil
 }
 
-func (p *Postgres) ListTasks(ctx context.Context, status models.TaskStatus) ([]models.Task, error) {
-	const op = "postgresql.Postgres.ListTasks"
-
-	var tasks []models.Task
-	if err := p.db.WithContext(ctx).Joins("User").Joins("Case").Joins("Cluster").Where("tasks.status = ?", status).Find(&tasks).Error; err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	return tasks, nil
-}
-
 func (p *Postgres) SaveTask(ctx context.Context, task models.Task) (models.Task, error) {
 	const op = "postgresql.Postgres.SaveTask"
 
-	if err := p.db.WithContext(ctx).Create(&task).Error; err != nil {
+	if err := p.conn(ctx).Create(&task).Error; err != nil {
 		return models.Task{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return task, nil
 }
 
+// UpdateTask сохраняет задачу, только если её версия в базе равна task.Version, и увеличивает версию;
+// связанные записи и метки не сохраняются
 func (p *Postgres) UpdateTask(ctx context.Context, id int64, task models.Task) error {
 	const op = "postgresql.Postgres.UpdateTask"
-	err := p.db.WithContext(ctx).Save(&task).Error
-	return err
+
+	version := task.Version
+	task.Version++
+	result := p.conn(ctx).Model(&models.Task{}).
+		Where("id = ? AND version = ?", id, version).
+		Select("*").Omit("id", "created_at", clause.Associations).
+		Updates(&task)
+	if result.Error != nil {
+		return fmt.Errorf("%s: %w", op, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrTaskVersionConflict)
+	}
+
+	return nil
+}
+
+// ClosedTaskCountsByCluster возвращает количество закрытых задач кластера по исполнителям
+func (p *Postgres) ClosedTaskCountsByCluster(ctx context.Context, clusterID int64) (map[int64]int64, error) {
+	const op = "postgresql.Postgres.ClosedTaskCountsByCluster"
+
+	var rows []struct {
+		UserID int64
+		Count  int64
+	}
+	if err := p.conn(ctx).Model(&models.Task{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("cluster_id = ? AND status = ? AND user_id IS NOT NULL", clusterID, models.TaskStatusClosed).
+		Group("user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+
+	return counts, nil
 }
 
-func (p *Postgres) ListTasksByUserID(ctx context.Context, userID int64, status models.TaskStatus) ([]models.Task, error) {
-	const op = "postgresql.Postgres.ListTasksByUserID"
+// ActiveTaskCounts возвращает количество назначенных и не завершенных задач по исполнителям
+func (p *Postgres) ActiveTaskCounts(ctx context.Context) (map[int64]int64, error) {
+	const op = "postgresql.Postgres.ActiveTaskCounts"
 
-	var tasks []models.Task
-	if err := p.db.WithContext(ctx).Joins("User").Joins("Case").Joins("Cluster").Where("tasks.user_id = ? AND tasks.status = ?", userID, status).Find(&tasks).Error; err != nil {
+	var rows []struct {
+		UserID int64
+		Count  int64
+	}
+	if err := p.conn(ctx).Model(&models.Task{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("status IN ? AND user_id IS NOT NULL", []models.TaskStatus{models.TaskStatusInProgress, models.TaskStatusOnHold, models.TaskStatusWaitingForCustomer}).
+		Group("user_id").
+		Scan(&rows).Error; err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return tasks, nil
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+
+	return counts, nil
 }
@@ -0,0 +1,32 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+)
+
+func (p *Postgres) SaveTaskEvents(ctx context.Context, events ...models.TaskEvent) error {
+	const op = "postgresql.Postgres.SaveTaskEvents"
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := p.conn(ctx).Create(&events).Error; err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ListTaskEvents(ctx context.Context, taskID int64) ([]models.TaskEvent, error) {
+	const op = "postgresql.Postgres.ListTaskEvents"
+
+	var events []models.TaskEvent
+	if err := p.conn(ctx).Joins("Actor").Where("task_events.task_id = ?", taskID).Order("task_events.created_at, task_events.id").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
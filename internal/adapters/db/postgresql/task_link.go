@@ -0,0 +1,97 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTaskLinkNotFound = errors.New("task link not found")
+	ErrTaskLinkExists   = errors.New("task link already exists")
+)
+
+func (p *Postgres) SaveTaskLink(ctx context.Context, link models.TaskLink) (models.TaskLink, error) {
+	const op = "postgresql.Postgres.SaveTaskLink"
+
+	if err := p.conn(ctx).Create(&link).Error; err != nil {
+		if isUniqueViolation(err) {
+			return models.TaskLink{}, fmt.Errorf("%s: %w", op, ErrTaskLinkExists)
+		}
+
+		return models.TaskLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+func (p *Postgres) TaskLinkByID(ctx context.Context, linkID int64) (models.TaskLink, error) {
+	const op = "postgresql.Postgres.TaskLinkByID"
+
+	var link models.TaskLink
+	if err := p.conn(ctx).Where("id = ?", linkID).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.TaskLink{}, fmt.Errorf("%s: %w", op, ErrTaskLinkNotFound)
+		}
+
+		return models.TaskLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+func (p *Postgres) DeleteTaskLink(ctx context.Context, linkID int64) error {
+	const op = "postgresql.Postgres.DeleteTaskLink"
+
+	result := p.conn(ctx).Delete(&models.TaskLink{}, linkID)
+	if result.Error != nil {
+		return fmt.Errorf("%s: %w", op, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrTaskLinkNotFound)
+	}
+
+	return nil
+}
+
+// ListTaskLinks возвращает связи, в которых участвует задача, вместе со связанными задачами
+func (p *Postgres) ListTaskLinks(ctx context.Context, taskID int64) ([]models.TaskLink, error) {
+	const op = "postgresql.Postgres.ListTaskLinks"
+
+	var links []models.TaskLink
+	err := p.conn(ctx).Preload("FromTask").Preload("ToTask").
+		Where("from_task_id = ? OR to_task_id = ?", taskID, taskID).
+		Order("id").Find(&links).Error
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// LinkedTaskIDs возвращает задачи, на которые указывают связи kind из задачи (outgoing)
+// или которые указывают на неё (incoming); unfinished оставляет только не закрытые и не отменённые
+func (p *Postgres) LinkedTaskIDs(ctx context.Context, taskID int64, kind models.TaskLinkKind, outgoing, unfinished bool) ([]int64, error) {
+	const op = "postgresql.Postgres.LinkedTaskIDs"
+
+	self, other := "to_task_id", "from_task_id"
+	if outgoing {
+		self, other = "from_task_id", "to_task_id"
+	}
+
+	db := p.conn(ctx).Model(&models.TaskLink{}).
+		Where("task_links.kind = ? AND task_links."+self+" = ?", kind, taskID)
+	if unfinished {
+		db = db.Joins("JOIN tasks ON tasks.id = task_links."+other).
+			Where("tasks.status NOT IN ?", []models.TaskStatus{models.TaskStatusClosed, models.TaskStatusCancelled})
+	}
+
+	var ids []int64
+	if err := db.Order("task_links."+other).Pluck("task_links."+other, &ids).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,286 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrInvalidSortField = errors.New("invalid sort field")
+)
+
+// cursor хранит значения ключей сортировки последней задачи страницы
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func (p *Postgres) QueryTasks(ctx context.Context, q models.TaskQuery) (models.TaskPage, error) {
+	const op = "postgresql.Postgres.QueryTasks"
+
+	sorts, err := normalizeSort(q.Sort)
+	if err != nil {
+		return models.TaskPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	db := applyTaskFilters(p.conn(ctx).Joins("User").Joins("Case").Joins("Cluster").Joins("Requester").Preload("Labels"), q)
+
+	if q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor, sorts)
+		if err != nil {
+			return models.TaskPage{}, fmt.Errorf("%s: %w", op, err)
+		}
+		db = applyKeyset(db, sorts, values)
+	}
+
+	for _, sort := range sorts {
+		order := "tasks." + string(sort.Field)
+		if sort.Desc {
+			order += " DESC"
+		}
+		db = db.Order(order)
+	}
+
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit + 1)
+	}
+
+	var tasks []models.Task
+	if err := db.Find(&tasks).Error; err != nil {
+		return models.TaskPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.TaskPage{Tasks: tasks}
+	if q.Limit > 0 && len(tasks) > q.Limit {
+		page.Tasks = tasks[:q.Limit]
+		page.NextCursor = encodeCursor(sorts, page.Tasks[q.Limit-1])
+	}
+
+	return page, nil
+}
+
+// QueueTasks возвращает задачи по фильтрам q по убыванию базового веса в очереди, начиная с offset.
+// Сортировка и курсор из q не используются
+func (p *Postgres) QueueTasks(ctx context.Context, q models.TaskQuery, weights models.QueueWeights, offset, limit int) ([]models.Task, error) {
+	const op = "postgresql.Postgres.QueueTasks"
+
+	// база данных считает ту же базовую часть веса, что и baseQueueScore сервиса задач
+	order := gorm.Expr(
+		"(CASE WHEN tasks.priority < ? THEN ? ELSE tasks.priority END - ?) * ?"+
+			" + tasks.requester_tier * ?"+
+			" + CASE WHEN tasks.fire THEN ? ELSE 0 END"+
+			" + LEAST(GREATEST(EXTRACT(EPOCH FROM (?::timestamptz - tasks.created_at)) / 3600, 0), ?) * ? DESC, tasks.id",
+		models.TaskPriorityLow, models.TaskPriorityNormal, models.TaskPriorityLow, weights.PriorityStep,
+		weights.RequesterStep,
+		weights.Fire,
+		weights.Now, weights.AgeMaxHours, weights.AgePerHour,
+	)
+
+	db := applyTaskFilters(p.conn(ctx).Joins("User").Joins("Case").Joins("Cluster").Joins("Requester").Preload("Labels"), q).
+		Clauses(clause.OrderBy{Expression: order}).
+		Offset(offset).
+		Limit(limit)
+
+	var tasks []models.Task
+	if err := db.Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tasks, nil
+}
+
+func applyTaskFilters(db *gorm.DB, q models.TaskQuery) *gorm.DB {
+	if q.Status != nil {
+		db = db.Where("tasks.status = ?", *q.Status)
+	}
+	if len(q.Statuses) > 0 {
+		db = db.Where("tasks.status IN ?", q.Statuses)
+	}
+	if q.ClusterID != nil {
+		db = db.Where("tasks.cluster_id = ?", *q.ClusterID)
+	}
+	if q.UserID != nil {
+		db = db.Where("tasks.user_id = ?", *q.UserID)
+	}
+	if q.RequesterID != nil {
+		db = db.Where("tasks.requester_id = ?", *q.RequesterID)
+	}
+	if q.Fire != nil {
+		db = db.Where("tasks.fire = ?", *q.Fire)
+	}
+	if q.HasCase != nil {
+		if *q.HasCase {
+			db = db.Where("tasks.case_id IS NOT NULL")
+		} else {
+			db = db.Where("tasks.case_id IS NULL")
+		}
+	}
+	if q.HasSolution != nil {
+		if *q.HasSolution {
+			db = db.Where("tasks.solution IS NOT NULL AND tasks.solution <> ''")
+		} else {
+			db = db.Where("(tasks.solution IS NULL OR tasks.solution = '')")
+		}
+	}
+	if q.CreatedFrom != nil {
+		db = db.Where("tasks.created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		db = db.Where("tasks.created_at < ?", *q.CreatedTo)
+	}
+	if q.CompletedFrom != nil {
+		db = db.Where("tasks.completed_at >= ?", *q.CompletedFrom)
+	}
+	if q.CompletedTo != nil {
+		db = db.Where("tasks.completed_at < ?", *q.CompletedTo)
+	}
+	if q.Merged != nil {
+		if *q.Merged {
+			db = db.Where("tasks.merged_into_id IS NOT NULL")
+		} else {
+			db = db.Where("tasks.merged_into_id IS NULL")
+		}
+	}
+	if q.MergedIntoID != nil {
+		db = db.Where("tasks.merged_into_id = ?", *q.MergedIntoID)
+	}
+	if len(q.Labels) > 0 {
+		labeled := db.Session(&gorm.Session{NewDB: true}).Table("task_labels").
+			Select("task_labels.task_id").
+			Joins("JOIN labels ON labels.id = task_labels.label_id").
+			Where("labels.name IN ?", q.Labels).
+			Group("task_labels.task_id").
+			Having("COUNT(DISTINCT labels.id) = ?", len(q.Labels))
+		db = db.Where("tasks.id IN (?)", labeled)
+	}
+
+	return db
+}
+
+// normalizeSort проверяет поля сортировки и добавляет id для однозначного порядка
+func normalizeSort(sorts []models.TaskSort) ([]models.TaskSort, error) {
+	normalized := make([]models.TaskSort, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		switch sort.Field {
+		case models.TaskSortCreatedAt, models.TaskSortStatus, models.TaskSortFire, models.TaskSortAvarageDuration, models.TaskSortPriority:
+			normalized = append(normalized, sort)
+		case models.TaskSortID:
+			return append(normalized, sort), nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSortField, sort.Field)
+		}
+	}
+
+	return append(normalized, models.TaskSort{Field: models.TaskSortID}), nil
+}
+
+// applyKeyset добавляет условие (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... для перехода за курсор
+func applyKeyset(db *gorm.DB, sorts []models.TaskSort, values []interface{}) *gorm.DB {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	for i, sort := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("tasks.%s = ?", sorts[j].Field))
+			args = append(args, values[j])
+		}
+
+		cmp := ">"
+		if sort.Desc {
+			cmp = "<"
+		}
+		parts = append(parts, fmt.Sprintf("tasks.%s %s ?", sort.Field, cmp))
+		args = append(args, values[i])
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return db.Where("("+strings.Join(clauses, " OR ")+")", args...)
+}
+
+func sortSignature(sorts []models.TaskSort) string {
+	parts := make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		part := string(sort.Field)
+		if sort.Desc {
+			part = "-" + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(sorts []models.TaskSort, task models.Task) string {
+	c := cursor{Sort: sortSignature(sorts)}
+	for _, sort := range sorts {
+		switch sort.Field {
+		case models.TaskSortID:
+			c.Values = append(c.Values, strconv.FormatInt(task.ID, 10))
+		case models.TaskSortCreatedAt:
+			c.Values = append(c.Values, task.CreatedAt.Format(time.RFC3339Nano))
+		case models.TaskSortStatus:
+			c.Values = append(c.Values, strconv.FormatInt(int64(task.Status), 10))
+		case models.TaskSortPriority:
+			c.Values = append(c.Values, strconv.FormatInt(int64(task.Priority), 10))
+		case models.TaskSortFire:
+			c.Values = append(c.Values, strconv.FormatBool(task.Fire))
+		case models.TaskSortAvarageDuration:
+			c.Values = append(c.Values, strconv.FormatFloat(float64(task.AvarageDuration), 'g', -1, 32))
+		}
+	}
+
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(token string, sorts []models.TaskSort) ([]interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sortSignature(sorts) || len(c.Values) != len(sorts) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, 0, len(sorts))
+	for i, sort := range sorts {
+		var (
+			value interface{}
+			err   error
+		)
+		switch sort.Field {
+		case models.TaskSortID, models.TaskSortStatus, models.TaskSortPriority:
+			value, err = strconv.ParseInt(c.Values[i], 10, 64)
+		case models.TaskSortCreatedAt:
+			value, err = time.Parse(time.RFC3339Nano, c.Values[i])
+		case models.TaskSortFire:
+			value, err = strconv.ParseBool(c.Values[i])
+		case models.TaskSortAvarageDuration:
+			value, err = strconv.ParseFloat(c.Values[i], 32)
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,140 @@
+package postgresql
+
+import (
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		sorts   []models.TaskSort
+		want    []models.TaskSort
+		wantErr error
+	}{
+		{
+			name: "empty sorts by id",
+			want: []models.TaskSort{{Field: models.TaskSortID}},
+		},
+		{
+			name:  "id appended as tiebreak",
+			sorts: []models.TaskSort{{Field: models.TaskSortPriority, Desc: true}},
+			want:  []models.TaskSort{{Field: models.TaskSortPriority, Desc: true}, {Field: models.TaskSortID}},
+		},
+		{
+			name:  "fields after id are dropped",
+			sorts: []models.TaskSort{{Field: models.TaskSortID, Desc: true}, {Field: models.TaskSortCreatedAt}},
+			want:  []models.TaskSort{{Field: models.TaskSortID, Desc: true}},
+		},
+		{
+			name:    "unknown field",
+			sorts:   []models.TaskSort{{Field: "title; DROP TABLE tasks"}},
+			wantErr: ErrInvalidSortField,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeSort(tt.sorts)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 15, 123456789, time.UTC)
+	task := models.Task{
+		ID:              42,
+		CreatedAt:       createdAt,
+		Status:          models.TaskStatusInProgress,
+		Priority:        models.TaskPriorityHigh,
+		Fire:            true,
+		AvarageDuration: 12.5,
+	}
+
+	tests := []struct {
+		name  string
+		sorts []models.TaskSort
+		want  []interface{}
+	}{
+		{
+			name:  "id",
+			sorts: []models.TaskSort{{Field: models.TaskSortID}},
+			want:  []interface{}{int64(42)},
+		},
+		{
+			name:  "created at keeps nanoseconds",
+			sorts: []models.TaskSort{{Field: models.TaskSortCreatedAt, Desc: true}, {Field: models.TaskSortID}},
+			want:  []interface{}{createdAt, int64(42)},
+		},
+		{
+			name: "all fields",
+			sorts: []models.TaskSort{
+				{Field: models.TaskSortStatus}, {Field: models.TaskSortPriority, Desc: true},
+				{Field: models.TaskSortFire}, {Field: models.TaskSortAvarageDuration}, {Field: models.TaskSortID},
+			},
+			want: []interface{}{int64(models.TaskStatusInProgress), int64(models.TaskPriorityHigh), true, 12.5, int64(42)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := decodeCursor(encodeCursor(tt.sorts, task), tt.sorts)
+			require.NoError(t, err)
+			require.Len(t, values, len(tt.want))
+			for i, want := range tt.want {
+				if wantTime, ok := want.(time.Time); ok {
+					assert.True(t, wantTime.Equal(values[i].(time.Time)), "value %d: %v", i, values[i])
+					continue
+				}
+				assert.Equal(t, want, values[i], "value %d", i)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	byID := []models.TaskSort{{Field: models.TaskSortID}}
+	byCreated := []models.TaskSort{{Field: models.TaskSortCreatedAt}, {Field: models.TaskSortID}}
+	task := models.Task{ID: 1, CreatedAt: time.Now()}
+
+	tests := []struct {
+		name  string
+		token string
+		sorts []models.TaskSort
+	}{
+		{name: "not base64", token: "!!!", sorts: byID},
+		{name: "not json", token: "bm90IGpzb24", sorts: byID},
+		{name: "other sort", token: encodeCursor(byCreated, task), sorts: byID},
+		{name: "other direction", token: encodeCursor(byID, task), sorts: []models.TaskSort{{Field: models.TaskSortID, Desc: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.token, tt.sorts)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func TestApplyKeyset(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	sorts := []models.TaskSort{{Field: models.TaskSortPriority, Desc: true}, {Field: models.TaskSortID}}
+	stmt := applyKeyset(db.Model(&models.Task{}), sorts, []interface{}{int64(3), int64(10)}).
+		Find(&[]models.Task{}).Statement
+
+	assert.Contains(t, stmt.SQL.String(), "((tasks.priority < $1) OR (tasks.priority = $2 AND tasks.id > $3))")
+	assert.Equal(t, []interface{}{int64(3), int64(3), int64(10)}, stmt.Vars)
+}
@@ -0,0 +1,137 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTaskTemplateNotFound = errors.New("task template not found")
+	ErrTaskTemplateExists   = errors.New("task template already exists")
+)
+
+func (p *Postgres) SaveTaskTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error) {
+	const op = "postgresql.Postgres.SaveTaskTemplate"
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.checkTemplateRefs(ctx, template); err != nil {
+			return err
+		}
+
+		return p.conn(ctx).Omit(clause.Associations).Create(&template).Error
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.TaskTemplate{}, fmt.Errorf("%s: %w", op, ErrTaskTemplateExists)
+		}
+
+		return models.TaskTemplate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p.TaskTemplateByID(ctx, template.ID)
+}
+
+func (p *Postgres) UpdateTaskTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error) {
+	const op = "postgresql.Postgres.UpdateTaskTemplate"
+
+	err := p.Transaction(ctx, func(ctx context.Context) error {
+		if err := p.checkTemplateRefs(ctx, template); err != nil {
+			return err
+		}
+
+		result := p.conn(ctx).Model(&models.TaskTemplate{}).
+			Where("id = ?", template.ID).
+			Select("*").Omit("id", "created_at", clause.Associations).
+			Updates(&template)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskTemplateNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.TaskTemplate{}, fmt.Errorf("%s: %w", op, ErrTaskTemplateExists)
+		}
+
+		return models.TaskTemplate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p.TaskTemplateByID(ctx, template.ID)
+}
+
+func (p *Postgres) DeleteTaskTemplate(ctx context.Context, id int64) error {
+	const op = "postgresql.Postgres.DeleteTaskTemplate"
+
+	result := p.conn(ctx).Delete(&models.TaskTemplate{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("%s: %w", op, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrTaskTemplateNotFound)
+	}
+
+	return nil
+}
+
+func (p *Postgres) TaskTemplateByID(ctx context.Context, id int64) (models.TaskTemplate, error) {
+	const op = "postgresql.Postgres.TaskTemplateByID"
+
+	var template models.TaskTemplate
+	if err := p.conn(ctx).Joins("Cluster").Joins("DefaultCase").First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.TaskTemplate{}, fmt.Errorf("%s: %w", op, ErrTaskTemplateNotFound)
+		}
+
+		return models.TaskTemplate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return template, nil
+}
+
+// ListTaskTemplates возвращает шаблоны кластера clusterID или все шаблоны, если clusterID не задан
+func (p *Postgres) ListTaskTemplates(ctx context.Context, clusterID *int64) ([]models.TaskTemplate, error) {
+	const op = "postgresql.Postgres.ListTaskTemplates"
+
+	db := p.conn(ctx).Joins("Cluster").Joins("DefaultCase")
+	if clusterID != nil {
+		db = db.Where("task_templates.cluster_id = ?", *clusterID)
+	}
+
+	var templates []models.TaskTemplate
+	if err := db.Order("task_templates.cluster_id").Order("task_templates.name").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return templates, nil
+}
+
+// checkTemplateRefs проверяет, что кластер шаблона существует, а кейс по умолчанию относится к этому кластеру
+func (p *Postgres) checkTemplateRefs(ctx context.Context, template models.TaskTemplate) error {
+	var count int64
+	if err := p.conn(ctx).Model(&models.Cluster{}).Where("id = ?", template.ClusterID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrClusterNotFound
+	}
+
+	if template.DefaultCaseID == nil {
+		return nil
+	}
+	if err := p.conn(ctx).Model(&models.Case{}).Where("id = ? AND cluster_id = ?", *template.DefaultCaseID, template.ClusterID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCaseNotFound
+	}
+
+	return nil
+}
@@ -17,7 +17,7 @@ func (p *Postgres) UserList(ctx context.Context) ([]models.User, error) {
 
 	var users []models.User
 
-	if err := p.db.WithContext(ctx).Find(&users).Error; err != nil {
+	if err := p.conn(ctx).Find(&users).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("%s: %w", op, ErrUsersNotFound)
 		}
@@ -33,7 +33,7 @@ func (p *Postgres) UserByID(ctx context.Context, userID int64) (models.User, err
 
 	var user models.User
 
-	if err := p.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := p.conn(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
@@ -44,17 +44,31 @@ func (p *Postgres) UserByID(ctx context.Context, userID int64) (models.User, err
 	return user, nil
 }
 
-func (p *Postgres) UserWithMinAverageDuration(ctx context.Context) (models.User, error) {
-	const op = "postgresql.Postgres.UserWithMinAverageDuration"
+// AddUserAvarageDuration атомарно изменяет нагрузку пользователя на delta
+func (p *Postgres) AddUserAvarageDuration(ctx context.Context, userID int64, delta float32) error {
+	const op = "postgresql.Postgres.AddUserAvarageDuration"
 
-	var user models.User
+	result := p.conn(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("avarage_duration", gorm.Expr("avarage_duration + ?", delta))
+	if result.Error != nil {
+		return fmt.Errorf("%s: %w", op, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
 
-	if err := p.db.WithContext(ctx).Order("avarage_duration").First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return models.User{}, fmt.Errorf("%s: %w", op, ErrUsersNotFound)
-		}
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	return nil
+}
+
+// ListAgents возвращает активных пользователей с ролью агента
+func (p *Postgres) ListAgents(ctx context.Context) ([]models.User, error) {
+	const op = "postgresql.Postgres.ListAgents"
+
+	var users []models.User
+	if err := p.conn(ctx).Where("role = ? AND status = ?", RoleUser, StatusActive).Order("id").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return user, nil
+	return users, nil
 }
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+const idempotencyLockSuffix = ":lock"
+
+// IdempotentResponse возвращает сохранённый ответ на запрос с ключом идемпотентности
+func (r *Redis) IdempotentResponse(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.rd.Get(key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// LockIdempotencyKey захватывает ключ на время выполнения запроса; false - ключ уже захвачен
+func (r *Redis) LockIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.rd.SetNX(key+idempotencyLockSuffix, 1, ttl).Result()
+}
+
+// ExtendIdempotencyKey продлевает захват ключа на ttl; false - ключ уже не захвачен
+func (r *Redis) ExtendIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.rd.Expire(key+idempotencyLockSuffix, ttl).Result()
+}
+
+// UnlockIdempotencyKey освобождает ключ, не сохраняя ответ
+func (r *Redis) UnlockIdempotencyKey(ctx context.Context, key string) error {
+	return r.rd.Del(key + idempotencyLockSuffix).Err()
+}
+
+// SaveIdempotentResponse сохраняет ответ на ttl и освобождает ключ
+func (r *Redis) SaveIdempotentResponse(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	_, err := r.rd.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(key, data, ttl)
+		pipe.Del(key + idempotencyLockSuffix)
+		return nil
+	})
+	return err
+}
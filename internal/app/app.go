@@ -1,20 +1,42 @@
 package app
 
 import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/blobstore"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/redis"
 	grpcapp "github.com/markgregr/bestHack_support_gRPC_server/internal/app/grpc"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/auth"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/outbox"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/archive"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/attachments"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/cases"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/comments"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/dispatch"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/duplicates"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/escalation"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/export"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/sla"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/templates"
 	"github.com/markgregr/bestHack_support_gRPC_server/pkg/gmiddleware"
 	"github.com/sirupsen/logrus"
 )
 
 type App struct {
 	GRPCSrv *grpcapp.App
+	workers []Worker
+}
+
+// Worker фоновый процесс, работающий до отмены контекста
+type Worker interface {
+	Run(ctx context.Context)
 }
 
 func New(log *logrus.Entry, cfg *config.Config) *App {
@@ -32,16 +54,112 @@ func New(log *logrus.Entry, cfg *config.Config) *App {
 
 	userService := user.New(log.Logger, postgre)
 
-	taskService := tasks.New(log.Logger, cfg.FileData.InputFile, cfg.FileData.OutputFile, cfg.AnalyticsServiceURL, postgre, postgre, postgre, postgre, postgre, *userService)
+	assigner, err := assignment.New(log.Logger, postgre, cfg.Assignment.Strategy, cfg.Assignment.ClusterStrategies, cfg.Assignment.AgentCapacity)
+	if err != nil {
+		panic(err)
+	}
+
+	var (
+		dispatchQueue  *dispatch.Queue
+		taskDispatcher tasks.TaskDispatcher
+	)
+	if cfg.Dispatch.Enabled {
+		dispatchQueue = dispatch.NewQueue(cfg.Dispatch.QueueSize)
+		taskDispatcher = dispatchQueue
+	}
+
+	taskFeed := taskfeed.New(taskfeed.DefaultHistorySize)
+
+	slaPolicy := sla.NewPolicy(cfg.SLA)
+	var slaHistoricalFile string
+	if cfg.SLA.UseHistoricalMedians {
+		slaHistoricalFile = cfg.FileData.OutputFile
+		if err := slaPolicy.LoadHistorical(slaHistoricalFile); err != nil {
+			log.WithError(err).Warn("failed to load historical sla targets")
+		}
+	}
+
+	var duplicateDetector tasks.DuplicateDetector
+	if cfg.Duplicates.Enabled {
+		duplicateDetector = duplicates.New(cfg.Duplicates.Threshold, cfg.Duplicates.MaxCandidates)
+	}
+
+	taskService := tasks.New(log.Logger, cfg.FileData.InputFile, cfg.FileData.OutputFile, tasks.Deps{
+		TaskSaver:       postgre,
+		TaskProvider:    postgre,
+		ClusterProvider: postgre,
+		ClusterSaver:    postgre,
+		CaseProvider:    postgre,
+		TaskHistory:     postgre,
+		TxManager:       postgre,
+		OutboxSaver:     postgre,
+		TaskPublisher:   taskFeed,
+		SLAPolicy:       slaPolicy,
+		Assigner:        assigner,
+		Dispatcher:      taskDispatcher,
+		Duplicates:      duplicateDetector,
+		CommentMover:    postgre,
+		TaskLinks:       postgre,
+		TaskLabeler:     postgre,
+		TaskArchive:     postgre,
+		Requesters:      postgre,
+		TaskTemplates:   postgre,
+		UserService:     *userService,
+	})
+
+	outboxService := outbox.New(log.Logger, postgre, map[string]string{
+		models.OutboxTopicAssignmentNotification: cfg.AnalyticsServiceURL,
+	}, cfg.Outbox, *userService)
 
 	caseService := cases.New(log.Logger, postgre, postgre, postgre, *userService)
 
+	commentService := comments.New(log.Logger, postgre, postgre, postgre, userService)
+
+	labelService := labels.New(log.Logger, postgre)
+
+	blobStore, err := blobstore.NewLocalStore(cfg.Attachments.Dir, cfg.Attachments.MaxSize)
+	if err != nil {
+		panic(err)
+	}
+	attachmentService := attachments.New(log.Logger, blobStore, postgre, postgre, postgre, postgre, cfg.Attachments.AllowedTypes)
+
+	templateService := templates.New(log.Logger, postgre)
+
+	exporter := export.New(log.Logger, postgre)
+
 	authMd := gmiddleware.NewAuthInterceptor(cfg.JWT.TokenKey, authService)
 
-	grpcApp := grpcapp.New(log, authService, taskService, caseService, authMd, cfg.GRPC.Port, cfg.GRPC.Host)
+	var idempotency *gmiddleware.Idempotency
+	if cfg.Idempotency.Enabled {
+		idempotency = gmiddleware.NewIdempotencyInterceptor(log.Logger, redis, cfg.Idempotency.TTL, cfg.Idempotency.LockTTL)
+	}
+
+	grpcApp := grpcapp.New(log, authService, taskService, caseService, commentService, labelService, attachmentService, templateService, exporter, outboxService, authMd, idempotency, cfg.GRPC.Port, cfg.GRPC.Host)
+
+	workers := []Worker{outboxService}
+	if cfg.Dispatch.Enabled {
+		workers = append(workers, dispatch.New(log.Logger, dispatchQueue, taskService, cfg.Dispatch))
+	}
+	if cfg.SLA.Enabled {
+		workers = append(workers, sla.New(log.Logger, slaPolicy, taskService, cfg.SLA.Interval, slaHistoricalFile))
+	}
+	if cfg.Escalation.Enabled {
+		workers = append(workers, escalation.New(log.Logger, escalation.NewPolicy(cfg.Escalation), taskService, cfg.Escalation.Interval))
+	}
+	if cfg.Archive.Enabled {
+		workers = append(workers, archive.New(log.Logger, taskService, cfg.Archive))
+	}
 
 	return &App{
 		GRPCSrv: grpcApp,
+		workers: workers,
 	}
 
 }
+
+// RunWorkers запускает фоновые процессы приложения
+func (a *App) RunWorkers(ctx context.Context) {
+	for _, worker := range a.workers {
+		go worker.Run(ctx)
+	}
+}
@@ -4,8 +4,14 @@ import (
 	"fmt"
 	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	authgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/auth"
+	outboxgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/outbox"
+	attachmentsgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/attachments"
 	casesgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/cases"
+	commentsgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/comments"
+	exportgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/export"
+	labelsgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/labels"
 	tasksgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/tasks"
+	templatesgrpc "github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/workflow/templates"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/lib/logger/handlers/logruspretty"
 	"github.com/markgregr/bestHack_support_gRPC_server/pkg/gmiddleware"
 	"github.com/markgregr/bestHack_support_gRPC_server/pkg/gserver"
@@ -23,13 +29,24 @@ type App struct {
 	port       int
 }
 
-func New(log *logrus.Entry, authService authgrpc.AuthService, taskService tasksgrpc.TaskService, caseService casesgrpc.CaseService, authMd *gmiddleware.Auth, port int, host string) *App { // Создаем экземпляр PrettyHandler для вывода красивых логов
+func New(log *logrus.Entry, authService authgrpc.AuthService, taskService tasksgrpc.TaskService, caseService casesgrpc.CaseService, commentService commentsgrpc.CommentService, labelService labelsgrpc.LabelService, attachmentService attachmentsgrpc.AttachmentService, templateService templatesgrpc.TemplateService, exporter exportgrpc.Exporter, outboxService outboxgrpc.OutboxService, authMd *gmiddleware.Auth, idempotency *gmiddleware.Idempotency, port int, host string) *App { // Создаем экземпляр PrettyHandler для вывода красивых логов
 	prettyHandler := logruspretty.NewPrettyHandler(os.Stdout)
 	logrus.SetFormatter(prettyHandler)
 	logEntry := logrus.NewEntry(logrus.StandardLogger())
 
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpcauth.UnaryServerInterceptor(authMd.AuthFunc), gmiddleware.ExpectedVersionUnaryInterceptor()}
+	if idempotency != nil {
+		idempotentMethods := append(append([]string{}, tasksgrpc.IdempotentMethods...), casesgrpc.IdempotentMethods...)
+		idempotentMethods = append(idempotentMethods, commentsgrpc.IdempotentMethods...)
+		idempotentMethods = append(idempotentMethods, labelsgrpc.IdempotentMethods...)
+		idempotentMethods = append(idempotentMethods, attachmentsgrpc.IdempotentMethods...)
+		idempotentMethods = append(idempotentMethods, templatesgrpc.IdempotentMethods...)
+		idempotentMethods = append(idempotentMethods, outboxgrpc.IdempotentMethods...)
+		unaryInterceptors = append(unaryInterceptors, idempotency.UnaryServerInterceptor(idempotentMethods...))
+	}
+
 	gRPCServer := grpc.NewServer(
-		gserver.StdUnaryMiddleware(logEntry, grpcauth.UnaryServerInterceptor(authMd.AuthFunc)),
+		gserver.StdUnaryMiddleware(logEntry, unaryInterceptors...),
 		gserver.StdStreamMiddleware(logEntry, grpcauth.StreamServerInterceptor(authMd.AuthFunc)),
 	)
 
@@ -39,6 +56,18 @@ func New(log *logrus.Entry, authService authgrpc.AuthService, taskService tasksg
 
 	casesgrpc.Register(gRPCServer, caseService)
 
+	commentsgrpc.Register(gRPCServer, commentService)
+
+	labelsgrpc.Register(gRPCServer, labelService)
+
+	attachmentsgrpc.Register(gRPCServer, attachmentService)
+
+	templatesgrpc.Register(gRPCServer, templateService)
+
+	exportgrpc.Register(gRPCServer, exporter)
+
+	outboxgrpc.Register(gRPCServer, outboxService)
+
 	return &App{
 		log:        log,
 		gRPCServer: gRPCServer,
@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+type ArchiveConfig struct {
+	Enabled   bool          `env:"GRPC_SERVER_ARCHIVE_ENABLED" envDefault:"false"`
+	Interval  time.Duration `env:"GRPC_SERVER_ARCHIVE_INTERVAL" envDefault:"1h"`
+	After     time.Duration `env:"GRPC_SERVER_ARCHIVE_AFTER" envDefault:"720h"`
+	BatchSize int           `env:"GRPC_SERVER_ARCHIVE_BATCH_SIZE" envDefault:"100"`
+}
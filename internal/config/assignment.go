@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type AssignmentConfig struct {
+	Strategy          string                    `env:"GRPC_SERVER_ASSIGNMENT_STRATEGY" envDefault:"least_load"`
+	ClusterStrategies AssignmentClusterStrategy `env:"GRPC_SERVER_ASSIGNMENT_CLUSTER_STRATEGIES"`
+	AgentCapacity     int64                     `env:"GRPC_SERVER_ASSIGNMENT_AGENT_CAPACITY" envDefault:"0"`
+}
+
+// AssignmentClusterStrategy стратегия назначения по индексу кластера в формате "2=cluster_affinity,4=round_robin"
+type AssignmentClusterStrategy map[int64]string
+
+func (s *AssignmentClusterStrategy) UnmarshalText(text []byte) error {
+	strategies := make(AssignmentClusterStrategy)
+
+	for _, item := range strings.Split(string(text), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		index, name, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid assignment strategy %q", item)
+		}
+
+		clusterIndex, err := strconv.ParseInt(strings.TrimSpace(index), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid assignment cluster index %q: %w", index, err)
+		}
+
+		strategies[clusterIndex] = strings.TrimSpace(name)
+	}
+
+	*s = strategies
+	return nil
+}
@@ -0,0 +1,7 @@
+package config
+
+type AttachmentsConfig struct {
+	Dir          string   `env:"GRPC_SERVER_ATTACHMENTS_DIR" envDefault:"./data/attachments"`
+	MaxSize      int64    `env:"GRPC_SERVER_ATTACHMENTS_MAX_SIZE" envDefault:"20971520"`
+	AllowedTypes []string `env:"GRPC_SERVER_ATTACHMENTS_ALLOWED_TYPES" envSeparator:"," envDefault:"image/png,image/jpeg,image/gif,image/webp,text/plain,application/pdf,application/zip,application/octet-stream"`
+}
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"github.com/caarlos0/env/v6"
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -16,6 +18,15 @@ type Config struct {
 	Postgres            PostgresConfig
 	JWT                 JWTConfig
 	Redis               RedisConfig
+	SLA                 SLAConfig
+	Assignment          AssignmentConfig
+	Dispatch            DispatchConfig
+	Outbox              OutboxConfig
+	Duplicates          DuplicatesConfig
+	Attachments         AttachmentsConfig
+	Idempotency         IdempotencyConfig
+	Archive             ArchiveConfig
+	Escalation          EscalationConfig
 }
 
 func MustLoad() *Config {
@@ -29,5 +40,36 @@ func MustLoad() *Config {
 	if err := env.Parse(&cfg); err != nil {
 		log.Fatalf("error parsing environment variables: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
 	return &cfg
 }
+
+// Validate проверяет интервалы фоновых процессов: неположительный интервал останавливает процесс при запуске
+func (c *Config) Validate() error {
+	intervals := []struct {
+		name    string
+		enabled bool
+		value   time.Duration
+	}{
+		{"GRPC_SERVER_OUTBOX_INTERVAL", true, c.Outbox.Interval},
+		{"GRPC_SERVER_OUTBOX_RETRY_INTERVAL", true, c.Outbox.RetryInterval},
+		{"GRPC_SERVER_OUTBOX_MAX_RETRY_INTERVAL", true, c.Outbox.MaxRetryInterval},
+		{"GRPC_SERVER_SLA_INTERVAL", c.SLA.Enabled, c.SLA.Interval},
+		{"GRPC_SERVER_DISPATCH_RETRY_INTERVAL", c.Dispatch.Enabled, c.Dispatch.RetryInterval},
+		{"GRPC_SERVER_DISPATCH_MAX_RETRY_INTERVAL", c.Dispatch.Enabled, c.Dispatch.MaxRetryInterval},
+		{"GRPC_SERVER_DISPATCH_SWEEP_INTERVAL", c.Dispatch.Enabled, c.Dispatch.SweepInterval},
+		{"GRPC_SERVER_ARCHIVE_INTERVAL", c.Archive.Enabled, c.Archive.Interval},
+		{"GRPC_SERVER_ESCALATION_INTERVAL", c.Escalation.Enabled, c.Escalation.Interval},
+		{"GRPC_SERVER_IDEMPOTENCY_LOCK_TTL", c.Idempotency.Enabled, c.Idempotency.LockTTL},
+	}
+
+	for _, interval := range intervals {
+		if interval.enabled && interval.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", interval.name, interval.value)
+		}
+	}
+
+	return nil
+}
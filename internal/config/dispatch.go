@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type DispatchConfig struct {
+	Enabled          bool          `env:"GRPC_SERVER_DISPATCH_ENABLED" envDefault:"false"`
+	QueueSize        int           `env:"GRPC_SERVER_DISPATCH_QUEUE_SIZE" envDefault:"1024"`
+	RetryInterval    time.Duration `env:"GRPC_SERVER_DISPATCH_RETRY_INTERVAL" envDefault:"10s"`
+	MaxRetryInterval time.Duration `env:"GRPC_SERVER_DISPATCH_MAX_RETRY_INTERVAL" envDefault:"5m"`
+	SweepInterval    time.Duration `env:"GRPC_SERVER_DISPATCH_SWEEP_INTERVAL" envDefault:"1m"`
+}
@@ -0,0 +1,7 @@
+package config
+
+type DuplicatesConfig struct {
+	Enabled       bool    `env:"GRPC_SERVER_DUPLICATES_ENABLED" envDefault:"true"`
+	Threshold     float64 `env:"GRPC_SERVER_DUPLICATES_THRESHOLD" envDefault:"0.6"`
+	MaxCandidates int     `env:"GRPC_SERVER_DUPLICATES_MAX_CANDIDATES" envDefault:"200"`
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EscalationConfig struct {
+	Enabled         bool                      `env:"GRPC_SERVER_ESCALATION_ENABLED" envDefault:"false"`
+	Interval        time.Duration             `env:"GRPC_SERVER_ESCALATION_INTERVAL" envDefault:"1m"`
+	NotifyAfter     time.Duration             `env:"GRPC_SERVER_ESCALATION_NOTIFY_AFTER" envDefault:"30m"`
+	ReassignAfter   time.Duration             `env:"GRPC_SERVER_ESCALATION_REASSIGN_AFTER" envDefault:"2h"`
+	EscalateAfter   time.Duration             `env:"GRPC_SERVER_ESCALATION_ESCALATE_AFTER" envDefault:"4h"`
+	ClusterPolicies EscalationClusterPolicies `env:"GRPC_SERVER_ESCALATION_CLUSTER_POLICIES"`
+}
+
+// EscalationPolicy время с момента взятия задачи в работу до каждого шага; 0 отключает шаг
+type EscalationPolicy struct {
+	NotifyAfter   time.Duration
+	ReassignAfter time.Duration
+	EscalateAfter time.Duration
+}
+
+// EscalationClusterPolicies политики эскалации по индексу кластера в формате "2=15m/1h/2h,4=0/30m/1h"
+type EscalationClusterPolicies map[int64]EscalationPolicy
+
+func (p *EscalationClusterPolicies) UnmarshalText(text []byte) error {
+	policies := make(EscalationClusterPolicies)
+
+	for _, item := range strings.Split(string(text), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		index, durations, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid escalation policy %q", item)
+		}
+		steps := strings.Split(durations, "/")
+		if len(steps) != 3 {
+			return fmt.Errorf("invalid escalation policy %q", item)
+		}
+
+		clusterIndex, err := strconv.ParseInt(strings.TrimSpace(index), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid escalation cluster index %q: %w", index, err)
+		}
+
+		var parsed [3]time.Duration
+		for i, step := range steps {
+			if parsed[i], err = time.ParseDuration(strings.TrimSpace(step)); err != nil {
+				return fmt.Errorf("invalid escalation step %q: %w", step, err)
+			}
+		}
+
+		policies[clusterIndex] = EscalationPolicy{
+			NotifyAfter:   parsed[0],
+			ReassignAfter: parsed[1],
+			EscalateAfter: parsed[2],
+		}
+	}
+
+	*p = policies
+	return nil
+}
@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+type IdempotencyConfig struct {
+	Enabled bool          `env:"GRPC_SERVER_IDEMPOTENCY_ENABLED" envDefault:"true"`
+	TTL     time.Duration `env:"GRPC_SERVER_IDEMPOTENCY_TTL" envDefault:"24h"`
+	LockTTL time.Duration `env:"GRPC_SERVER_IDEMPOTENCY_LOCK_TTL" envDefault:"30s"`
+}
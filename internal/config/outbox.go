@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+type OutboxConfig struct {
+	Interval         time.Duration `env:"GRPC_SERVER_OUTBOX_INTERVAL" envDefault:"5s"`
+	BatchSize        int           `env:"GRPC_SERVER_OUTBOX_BATCH_SIZE" envDefault:"20"`
+	Timeout          time.Duration `env:"GRPC_SERVER_OUTBOX_TIMEOUT" envDefault:"10s"`
+	MaxAttempts      int           `env:"GRPC_SERVER_OUTBOX_MAX_ATTEMPTS" envDefault:"10"`
+	RetryInterval    time.Duration `env:"GRPC_SERVER_OUTBOX_RETRY_INTERVAL" envDefault:"10s"`
+	MaxRetryInterval time.Duration `env:"GRPC_SERVER_OUTBOX_MAX_RETRY_INTERVAL" envDefault:"1h"`
+}
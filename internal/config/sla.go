@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SLAConfig struct {
+	Enabled              bool              `env:"GRPC_SERVER_SLA_ENABLED" envDefault:"false"`
+	Interval             time.Duration     `env:"GRPC_SERVER_SLA_INTERVAL" envDefault:"1m"`
+	ReactionTime         time.Duration     `env:"GRPC_SERVER_SLA_REACTION_TIME" envDefault:"30m"`
+	ResolutionTime       time.Duration     `env:"GRPC_SERVER_SLA_RESOLUTION_TIME" envDefault:"4h"`
+	AtRiskRatio          float64           `env:"GRPC_SERVER_SLA_AT_RISK_RATIO" envDefault:"0.8"`
+	ClusterTargets       SLAClusterTargets `env:"GRPC_SERVER_SLA_CLUSTER_TARGETS"`
+	UseHistoricalMedians bool              `env:"GRPC_SERVER_SLA_USE_HISTORICAL_MEDIANS" envDefault:"false"`
+	HistoricalFactor     float64           `env:"GRPC_SERVER_SLA_HISTORICAL_FACTOR" envDefault:"1.5"`
+}
+
+type SLATarget struct {
+	Reaction   time.Duration
+	Resolution time.Duration
+}
+
+// SLAClusterTargets цели SLA по индексу кластера в формате "2=15m/2h,4=10m/1h"
+type SLAClusterTargets map[int64]SLATarget
+
+func (t *SLAClusterTargets) UnmarshalText(text []byte) error {
+	targets := make(SLAClusterTargets)
+
+	for _, item := range strings.Split(string(text), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		index, durations, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid sla target %q", item)
+		}
+		reaction, resolution, ok := strings.Cut(durations, "/")
+		if !ok {
+			return fmt.Errorf("invalid sla target %q", item)
+		}
+
+		clusterIndex, err := strconv.ParseInt(strings.TrimSpace(index), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid sla cluster index %q: %w", index, err)
+		}
+
+		var target SLATarget
+		if target.Reaction, err = time.ParseDuration(strings.TrimSpace(reaction)); err != nil {
+			return fmt.Errorf("invalid sla reaction time %q: %w", reaction, err)
+		}
+		if target.Resolution, err = time.ParseDuration(strings.TrimSpace(resolution)); err != nil {
+			return fmt.Errorf("invalid sla resolution time %q: %w", resolution, err)
+		}
+
+		targets[clusterIndex] = target
+	}
+
+	*t = targets
+	return nil
+}
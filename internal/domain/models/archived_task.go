@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ArchivedTask завершённая задача, перенесённая из tasks в архив
+type ArchivedTask struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	ClusterID   *int64    `gorm:"index" json:"cluster_id"`
+	CompletedAt time.Time `gorm:"not null;index" json:"completed_at"`
+	ArchivedAt  time.Time `gorm:"autoCreateTime;not null" json:"archived_at"`
+	Snapshot    []byte    `gorm:"type:jsonb;not null" json:"-"`
+}
+
+// TaskSnapshot содержимое архивной задачи вместе со строками, которые удаляются вместе с ней.
+// Пользователи в снимок не входят, сохраняются только их ID
+type TaskSnapshot struct {
+	Task             Task                  `json:"task"`
+	Links            []TaskLink            `json:"links"`
+	Attachments      []Attachment          `json:"attachments"`
+	Comments         []TaskComment         `json:"comments"`
+	CommentRevisions []TaskCommentRevision `json:"comment_revisions"`
+}
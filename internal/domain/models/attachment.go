@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Attachment файл, прикреплённый к задаче или к комментарию задачи
+type Attachment struct {
+	ID          int64     `gorm:"primaryKey" json:"id"`
+	TaskID      int64     `gorm:"not null;index" json:"task_id"`
+	Task        *Task     `gorm:"foreignKey:TaskID;constraint:OnDelete:CASCADE" json:"-"`
+	CommentID   *int64    `gorm:"index" json:"comment_id"`
+	FileName    string    `gorm:"not null" json:"file_name"`
+	ContentType string    `gorm:"not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	BlobKey     string    `gorm:"not null;index" json:"blob_key"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null" json:"created_at"`
+
+	UploaderID int64 `gorm:"not null" json:"uploader_id"`
+	Uploader   *User `gorm:"foreignKey:UploaderID" json:"uploader"`
+}
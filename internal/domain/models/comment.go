@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+type TaskComment struct {
+	ID         int64             `gorm:"primaryKey" json:"id"`
+	TaskID     int64             `gorm:"not null;index" json:"task_id"`
+	Body       string            `gorm:"not null" json:"body"`
+	Visibility CommentVisibility `gorm:"not null" json:"visibility"`
+	CreatedAt  time.Time         `gorm:"autoCreateTime;not null" json:"created_at"`
+	EditedAt   *time.Time        `json:"edited_at"`
+	DeletedAt  *time.Time        `json:"deleted_at"`
+	// SourceMessageID идентификатор исходного сообщения чата для импортированных комментариев
+	SourceMessageID *string `gorm:"uniqueIndex" json:"source_message_id,omitempty"`
+
+	AuthorID int64 `gorm:"not null" json:"author_id"`
+	Author   *User `gorm:"foreignKey:AuthorID" json:"author"`
+}
+
+// TaskCommentRevision предыдущая версия комментария, сохраняется при каждом изменении и удалении
+type TaskCommentRevision struct {
+	ID         int64             `gorm:"primaryKey" json:"id"`
+	CommentID  int64             `gorm:"not null;index" json:"comment_id"`
+	Action     CommentAction     `gorm:"not null" json:"action"`
+	Body       string            `gorm:"not null" json:"body"`
+	Visibility CommentVisibility `gorm:"not null" json:"visibility"`
+	CreatedAt  time.Time         `gorm:"autoCreateTime;not null" json:"created_at"`
+
+	EditorID int64 `gorm:"not null" json:"editor_id"`
+	Editor   *User `gorm:"foreignKey:EditorID" json:"editor"`
+}
+
+type CommentVisibility string
+
+const (
+	CommentVisibilityInternal CommentVisibility = "internal"
+	CommentVisibilityCustomer CommentVisibility = "customer"
+)
+
+type CommentAction string
+
+const (
+	CommentActionEdited  CommentAction = "edited"
+	CommentActionDeleted CommentAction = "deleted"
+)
@@ -0,0 +1,29 @@
+package models
+
+// EscalationLevel шаг цепочки эскалации задачи, зависшей в работе
+type EscalationLevel int32
+
+const (
+	EscalationNone EscalationLevel = iota
+	// EscalationNotified исполнителю отправлено напоминание
+	EscalationNotified
+	// EscalationReassigned задача передана другому агенту
+	EscalationReassigned
+	// EscalationEscalated задача передана администраторам и подожжена
+	EscalationEscalated
+)
+
+func (l EscalationLevel) String() string {
+	switch l {
+	case EscalationNone:
+		return "none"
+	case EscalationNotified:
+		return "notified"
+	case EscalationReassigned:
+		return "reassigned"
+	case EscalationEscalated:
+		return "escalated"
+	default:
+		return "unknown"
+	}
+}
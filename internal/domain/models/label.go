@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Label метка из общего каталога, имя хранится в нижнем регистре
+type Label struct {
+	ID          int64     `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"not null;uniqueIndex" json:"name"`
+	Color       string    `gorm:"not null" json:"color"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null" json:"created_at"`
+}
+
+// LabelCount количество задач с меткой в разрезе статусов
+type LabelCount struct {
+	Label  Label `json:"label"`
+	Total  int64 `json:"total"`
+	Active int64 `json:"active"`
+	Closed int64 `json:"closed"`
+	Fire   int64 `json:"fire"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// OutboxMessage исходящее сообщение, записанное в одной транзакции с изменением данных
+type OutboxMessage struct {
+	ID            int64        `gorm:"primaryKey" json:"id"`
+	Topic         string       `gorm:"not null" json:"topic"`
+	Payload       []byte       `gorm:"type:jsonb;not null" json:"payload"`
+	Status        OutboxStatus `gorm:"not null;index:idx_outbox_status_next_attempt" json:"status"`
+	Attempts      int          `gorm:"not null" json:"attempts"`
+	NextAttemptAt time.Time    `gorm:"not null;index:idx_outbox_status_next_attempt" json:"next_attempt_at"`
+	LastError     *string      `json:"last_error"`
+	CreatedAt     time.Time    `gorm:"autoCreateTime;not null" json:"created_at"`
+	DeliveredAt   *time.Time   `json:"delivered_at"`
+}
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+	OutboxStatusDead      OutboxStatus = "dead"
+)
+
+const (
+	OutboxTopicAssignmentNotification = "assignment_notification"
+)
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Requester клиент, обратившийся в поддержку; ExternalID - идентификатор пользователя в чате
+type Requester struct {
+	ID          int64         `gorm:"primaryKey" json:"id"`
+	ExternalID  string        `gorm:"not null;uniqueIndex" json:"external_id"`
+	Channel     string        `json:"channel"`
+	DisplayName string        `json:"display_name"`
+	Tier        RequesterTier `gorm:"not null;default:0" json:"tier"`
+	CreatedAt   time.Time     `gorm:"autoCreateTime;not null" json:"created_at"`
+	UpdatedAt   time.Time     `gorm:"autoUpdateTime;not null" json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// TaskSearchQuery полнотекстовый поиск задач с фильтрами по статусу и кластеру
+type TaskSearchQuery struct {
+	Text      string
+	Statuses  []TaskStatus
+	ClusterID *int64
+	Limit     int
+	Offset    int
+}
+
+// CaseSearchQuery полнотекстовый поиск кейсов с фильтром по кластеру
+type CaseSearchQuery struct {
+	Text      string
+	ClusterID *int64
+	Limit     int
+	Offset    int
+}
+
+// TaskSearchHit найденная задача; Snippet - фрагмент текста с экранированным HTML и найденными словами, выделенными <b></b>
+type TaskSearchHit struct {
+	Task    Task
+	Rank    float64
+	Snippet string
+}
+
+type CaseSearchHit struct {
+	Case    Case
+	Rank    float64
+	Snippet string
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type SLALevel string
+
+const (
+	SLALevelOK       SLALevel = "ok"
+	SLALevelAtRisk   SLALevel = "at_risk"
+	SLALevelBreached SLALevel = "breached"
+)
+
+type SLAKind string
+
+const (
+	SLAKindReaction   SLAKind = "reaction"
+	SLAKindResolution SLAKind = "resolution"
+)
+
+// SLAStatus состояние SLA задачи, Remaining отрицательно после нарушения
+type SLAStatus struct {
+	Level     SLALevel      `json:"level"`
+	Kind      SLAKind       `json:"kind"`
+	Deadline  time.Time     `json:"deadline"`
+	Remaining time.Duration `json:"remaining"`
+}
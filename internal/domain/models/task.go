@@ -3,25 +3,56 @@ package models
 import "time"
 
 type Task struct {
-	ID              int64      `gorm:"primaryKey" json:"id"`
-	Title           string     `gorm:"not null" json:"title`
-	Description     string     `gorm:"not null" json:"description`
-	Solution        *string    `json:"solution`
-	Status          TaskStatus `gorm:"not null" json:"status`
-	CreatedAt       time.Time  `gorm:"autoCreateTime" gorm:"not null" json:"created_at`
-	FormedAt        *time.Time `json:"formed_at`
-	CompletedAt     *time.Time `json:"completed_at`
-	AvarageDuration float32    `json:"avarage_duratation`
-	Fire            bool       `json:"fire`
-
-	CaseID *int64 `json:"case_id`
-	Case   *Case  `gorm:"foreignKey:CaseID" json:"case`
-
-	ClusterID *int64   `json:"cluster_id`
-	Cluster   *Cluster `gorm:"foreignKey:ClusterID" json:"cluster`
-
-	UserID *int64 `json:"user_id`
-	User   *User  `gorm:"foreignKey:UserID" json:"user`
+	ID              int64         `gorm:"primaryKey" json:"id"`
+	Title           string        `gorm:"not null" json:"title"`
+	Description     string        `gorm:"not null" json:"description"`
+	Solution        *string       `json:"solution"`
+	Status          TaskStatus    `gorm:"not null" json:"status"`
+	StatusReason    *string       `json:"status_reason"`
+	CreatedAt       time.Time     `gorm:"autoCreateTime;not null" json:"created_at"`
+	FormedAt        *time.Time    `json:"formed_at"`
+	CompletedAt     *time.Time    `json:"completed_at"`
+	AvarageDuration float32       `json:"avarage_duratation"`
+	Fire            bool          `json:"fire"`
+	FireReason      *string       `json:"fire_reason"`
+	Priority        TaskPriority  `gorm:"not null;default:2" json:"priority"`
+	RequesterTier   RequesterTier `gorm:"not null;default:0" json:"requester_tier"`
+	Version         int64         `gorm:"not null;default:1" json:"version"`
+
+	// EscalationLevel последний выполненный шаг эскалации с момента EscalationStartedAt, когда задачу взяли в работу
+	EscalationLevel     EscalationLevel `gorm:"not null;default:0" json:"escalation_level"`
+	EscalationStartedAt *time.Time      `json:"escalation_started_at"`
+
+	// PausedAt начало текущей паузы в статусах OnHold и WaitingForCustomer; PausedDuration - сумма завершённых пауз,
+	// на которую сдвигается срок SLA выполнения
+	PausedAt       *time.Time    `json:"paused_at"`
+	PausedDuration time.Duration `gorm:"not null;default:0" json:"paused_duration"`
+
+	CaseID *int64 `json:"case_id"`
+	Case   *Case  `gorm:"foreignKey:CaseID" json:"case"`
+
+	ClusterID *int64   `json:"cluster_id"`
+	Cluster   *Cluster `gorm:"foreignKey:ClusterID" json:"cluster"`
+
+	UserID *int64 `json:"user_id"`
+	User   *User  `gorm:"foreignKey:UserID" json:"user"`
+
+	RequesterID *int64     `gorm:"index" json:"requester_id"`
+	Requester   *Requester `gorm:"foreignKey:RequesterID" json:"requester"`
+
+	DuplicateOfID  *int64  `gorm:"index" json:"duplicate_of_id"`
+	DuplicateScore float64 `json:"duplicate_score"`
+	MergedIntoID   *int64  `gorm:"index" json:"merged_into_id"`
+
+	// SourceMessageID идентификатор исходного сообщения чата для импортированных задач
+	SourceMessageID *string `gorm:"uniqueIndex" json:"source_message_id,omitempty"`
+
+	Labels []Label `gorm:"many2many:task_labels" json:"labels"`
+
+	SLA   *SLAStatus `gorm:"-" json:"sla,omitempty"`
+	Score float64    `gorm:"-" json:"score,omitempty"`
+	// ArchivedAt задан, если задача прочитана из архива
+	ArchivedAt *time.Time `gorm:"-" json:"archived_at,omitempty"`
 }
 
 type TaskStatus int32
@@ -30,4 +61,76 @@ const (
 	TaskStatusOpen TaskStatus = iota
 	TaskStatusInProgress
 	TaskStatusClosed
+	TaskStatusOnHold
+	TaskStatusWaitingForCustomer
+	TaskStatusCancelled
+	TaskStatusReopened
+)
+
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskStatusOpen:
+		return "open"
+	case TaskStatusInProgress:
+		return "in_progress"
+	case TaskStatusClosed:
+		return "closed"
+	case TaskStatusOnHold:
+		return "on_hold"
+	case TaskStatusWaitingForCustomer:
+		return "waiting_for_customer"
+	case TaskStatusCancelled:
+		return "cancelled"
+	case TaskStatusReopened:
+		return "reopened"
+	default:
+		return "unknown"
+	}
+}
+
+// TaskPriority приоритет задачи, больше значение - важнее задача
+type TaskPriority int32
+
+const (
+	TaskPriorityLow TaskPriority = iota + 1
+	TaskPriorityNormal
+	TaskPriorityHigh
+	TaskPriorityCritical
+)
+
+func (p TaskPriority) String() string {
+	switch p {
+	case TaskPriorityLow:
+		return "low"
+	case TaskPriorityNormal:
+		return "normal"
+	case TaskPriorityHigh:
+		return "high"
+	case TaskPriorityCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// RequesterTier уровень обслуживания клиента, оставившего обращение
+type RequesterTier int32
+
+const (
+	RequesterTierStandard RequesterTier = iota
+	RequesterTierPremium
+	RequesterTierVIP
 )
+
+func (t RequesterTier) String() string {
+	switch t {
+	case RequesterTierStandard:
+		return "standard"
+	case RequesterTierPremium:
+		return "premium"
+	case RequesterTierVIP:
+		return "vip"
+	default:
+		return "unknown"
+	}
+}
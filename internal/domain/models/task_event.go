@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// TaskEvent запись в журнале изменений задачи, строки только добавляются
+type TaskEvent struct {
+	ID        int64         `gorm:"primaryKey" json:"id"`
+	TaskID    int64         `gorm:"not null;index" json:"task_id"`
+	Kind      TaskEventKind `gorm:"not null" json:"kind"`
+	OldValue  *string       `json:"old_value"`
+	NewValue  *string       `json:"new_value"`
+	Comment   *string       `json:"comment"`
+	CreatedAt time.Time     `gorm:"autoCreateTime;not null" json:"created_at"`
+
+	ActorID *int64 `json:"actor_id"`
+	Actor   *User  `gorm:"foreignKey:ActorID" json:"actor"`
+}
+
+type TaskEventKind string
+
+const (
+	TaskEventCreated         TaskEventKind = "created"
+	TaskEventStatusChanged   TaskEventKind = "status_changed"
+	TaskEventUserAppointed   TaskEventKind = "user_appointed"
+	TaskEventCaseAdded       TaskEventKind = "case_added"
+	TaskEventCaseRemoved     TaskEventKind = "case_removed"
+	TaskEventSolutionAdded   TaskEventKind = "solution_added"
+	TaskEventSolutionRemoved TaskEventKind = "solution_removed"
+	TaskEventFired           TaskEventKind = "fired"
+	TaskEventUnfired         TaskEventKind = "unfired"
+	TaskEventPriorityChanged TaskEventKind = "priority_changed"
+	TaskEventDuplicateFound  TaskEventKind = "duplicate_found"
+	TaskEventMerged          TaskEventKind = "merged"
+	TaskEventChildMerged     TaskEventKind = "child_merged"
+	TaskEventLinkAdded       TaskEventKind = "link_added"
+	TaskEventLinkRemoved     TaskEventKind = "link_removed"
+	TaskEventLabelAdded      TaskEventKind = "label_added"
+	TaskEventLabelRemoved    TaskEventKind = "label_removed"
+	TaskEventArchived        TaskEventKind = "archived"
+	TaskEventRestored        TaskEventKind = "restored"
+	TaskEventImported        TaskEventKind = "imported"
+	TaskEventEscalated       TaskEventKind = "escalated"
+)
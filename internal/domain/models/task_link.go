@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TaskLink связь между задачами; для subtask From - родитель, для blocks From блокирует To,
+// для related From.ID < To.ID
+type TaskLink struct {
+	ID         int64        `gorm:"primaryKey" json:"id"`
+	FromTaskID int64        `gorm:"not null;uniqueIndex:idx_task_links_from_to_kind" json:"from_task_id"`
+	FromTask   *Task        `gorm:"foreignKey:FromTaskID;constraint:OnDelete:CASCADE" json:"from_task"`
+	ToTaskID   int64        `gorm:"not null;index;uniqueIndex:idx_task_links_from_to_kind" json:"to_task_id"`
+	ToTask     *Task        `gorm:"foreignKey:ToTaskID;constraint:OnDelete:CASCADE" json:"to_task"`
+	Kind       TaskLinkKind `gorm:"not null;uniqueIndex:idx_task_links_from_to_kind" json:"kind"`
+	CreatedAt  time.Time    `gorm:"autoCreateTime;not null" json:"created_at"`
+
+	CreatedByID *int64 `json:"created_by_id"`
+	CreatedBy   *User  `gorm:"foreignKey:CreatedByID" json:"created_by"`
+}
+
+type TaskLinkKind string
+
+const (
+	TaskLinkSubtask TaskLinkKind = "subtask"
+	TaskLinkBlocks  TaskLinkKind = "blocks"
+	TaskLinkRelated TaskLinkKind = "related"
+)
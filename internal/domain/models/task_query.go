@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// TaskQuery фильтры, сортировка и курсор для выборки задач
+type TaskQuery struct {
+	Status        *TaskStatus
+	Statuses      []TaskStatus
+	ClusterID     *int64
+	UserID        *int64
+	RequesterID   *int64
+	Fire          *bool
+	HasCase       *bool
+	HasSolution   *bool
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	CompletedFrom *time.Time
+	CompletedTo   *time.Time
+	Merged        *bool
+	MergedIntoID  *int64
+	// Labels оставляет задачи, у которых есть все перечисленные метки
+	Labels []string
+
+	Sort   []TaskSort
+	Cursor string
+	Limit  int
+}
+
+// QueueWeights веса базовой части веса задачи в очереди (приоритет, уровень клиента, Fire, возраст),
+// по которой база данных упорядочивает кандидатов очереди
+type QueueWeights struct {
+	Now           time.Time
+	PriorityStep  float64
+	RequesterStep float64
+	Fire          float64
+	AgePerHour    float64
+	AgeMaxHours   float64
+}
+
+type TaskSort struct {
+	Field TaskSortField
+	Desc  bool
+}
+
+type TaskSortField string
+
+const (
+	TaskSortID              TaskSortField = "id"
+	TaskSortCreatedAt       TaskSortField = "created_at"
+	TaskSortStatus          TaskSortField = "status"
+	TaskSortFire            TaskSortField = "fire"
+	TaskSortAvarageDuration TaskSortField = "avarage_duration"
+	TaskSortPriority        TaskSortField = "priority"
+)
+
+// TaskPage страница задач и курсор следующей страницы, пустой если страниц больше нет
+type TaskPage struct {
+	Tasks      []Task
+	NextCursor string
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// TaskTemplate шаблон частого обращения кластера; TitlePattern и DescriptionScaffold - шаблоны text/template,
+// переменные которых подставляются при создании задачи
+type TaskTemplate struct {
+	ID                  int64        `gorm:"primaryKey" json:"id"`
+	ClusterID           int64        `gorm:"not null;uniqueIndex:idx_task_templates_cluster_name" json:"cluster_id"`
+	Cluster             *Cluster     `gorm:"foreignKey:ClusterID" json:"cluster"`
+	Name                string       `gorm:"not null;uniqueIndex:idx_task_templates_cluster_name" json:"name"`
+	TitlePattern        string       `gorm:"not null" json:"title_pattern"`
+	DescriptionScaffold string       `json:"description_scaffold"`
+	DefaultPriority     TaskPriority `gorm:"not null;default:2" json:"default_priority"`
+	DefaultCaseID       *int64       `json:"default_case_id"`
+	DefaultCase         *Case        `gorm:"foreignKey:DefaultCaseID;constraint:OnDelete:SET NULL" json:"default_case"`
+	CreatedAt           time.Time    `gorm:"autoCreateTime;not null" json:"created_at"`
+	UpdatedAt           time.Time    `gorm:"autoUpdateTime;not null" json:"updated_at"`
+}
@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/structrpc"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/outbox"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Администрирование outbox не описано в пакете protos, поэтому сервис регистрируется вручную:
+// запросы и ответы передаются как google.protobuf.Struct
+const (
+	serviceName = "outbox.OutboxService"
+
+	OutboxService_ListMessages_FullMethodName  = "/" + serviceName + "/ListMessages"
+	OutboxService_ReplayMessage_FullMethodName = "/" + serviceName + "/ReplayMessage"
+)
+
+// IdempotentMethods изменяющие методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	OutboxService_ReplayMessage_FullMethodName,
+}
+
+type OutboxService interface {
+	ListMessages(ctx context.Context, status models.OutboxStatus, limit int) ([]models.OutboxMessage, error)
+	ReplayMessage(ctx context.Context, id int64) (models.OutboxMessage, error)
+}
+
+type OutboxServiceServer interface {
+	// ListMessages возвращает сообщения outbox; поля: status (pending, delivered, dead;
+	// по умолчанию dead), limit. Ответ: messages
+	ListMessages(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// ReplayMessage возвращает сообщение в очередь отправки; поля: message_id
+	ReplayMessage(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*OutboxServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		structrpc.Unary(serviceName, "ListMessages", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(OutboxServiceServer).ListMessages(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "ReplayMessage", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(OutboxServiceServer).ReplayMessage(ctx, req)
+		}),
+	},
+}
+
+type serverAPI struct {
+	outboxService OutboxService
+}
+
+func Register(gRPC *grpc.Server, outboxService OutboxService) {
+	gRPC.RegisterService(&serviceDesc, &serverAPI{outboxService: outboxService})
+}
+
+func (s *serverAPI) ListMessages(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	statusName := r.String("status")
+	limit := r.Int("limit")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	messageStatus, err := parseStatus(statusName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	messages, err := s.outboxService.ListMessages(ctx, messageStatus, limit)
+	if err != nil {
+		return nil, mapOutboxError(err)
+	}
+	return marshalResponse(map[string]interface{}{"messages": messages})
+}
+
+func (s *serverAPI) ReplayMessage(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	messageID := r.ID("message_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	message, err := s.outboxService.ReplayMessage(ctx, messageID)
+	if err != nil {
+		return nil, mapOutboxError(err)
+	}
+	return marshalResponse(message)
+}
+
+// parseStatus разбирает статус сообщения; пустой статус означает сообщения, которые не удалось доставить
+func parseStatus(name string) (models.OutboxStatus, error) {
+	switch messageStatus := models.OutboxStatus(name); messageStatus {
+	case "":
+		return models.OutboxStatusDead, nil
+	case models.OutboxStatusPending, models.OutboxStatusDelivered, models.OutboxStatusDead:
+		return messageStatus, nil
+	default:
+		return "", errors.New("unknown outbox status " + name)
+	}
+}
+
+func mapOutboxError(err error) error {
+	switch {
+	case errors.Is(err, outbox.ErrMessageNotFound):
+		return status.Error(codes.NotFound, "outbox message not found")
+	case errors.Is(err, outbox.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, outbox.ErrInvalidUser):
+		return status.Error(codes.Unauthenticated, "invalid user")
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func marshalResponse(v interface{}) (*structpb.Struct, error) {
+	resp, err := structrpc.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
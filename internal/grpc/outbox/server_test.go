@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+// fakeOutboxService запоминает аргументы последнего вызова
+type fakeOutboxService struct {
+	messages []models.OutboxMessage
+	err      error
+
+	status    models.OutboxStatus
+	limit     int
+	messageID int64
+}
+
+func (f *fakeOutboxService) ListMessages(_ context.Context, status models.OutboxStatus, limit int) ([]models.OutboxMessage, error) {
+	f.status, f.limit = status, limit
+	return f.messages, f.err
+}
+
+func (f *fakeOutboxService) ReplayMessage(_ context.Context, id int64) (models.OutboxMessage, error) {
+	f.messageID = id
+	if f.err != nil {
+		return models.OutboxMessage{}, f.err
+	}
+	return models.OutboxMessage{ID: id, Status: models.OutboxStatusPending}, nil
+}
+
+func newRequest(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestListMessages(t *testing.T) {
+	tests := []struct {
+		name       string
+		fields     map[string]interface{}
+		wantStatus models.OutboxStatus
+		wantCode   codes.Code
+	}{
+		{name: "dead by default", fields: map[string]interface{}{}, wantStatus: models.OutboxStatusDead},
+		{name: "pending", fields: map[string]interface{}{"status": "pending", "limit": float64(10)}, wantStatus: models.OutboxStatusPending},
+		{name: "unknown status", fields: map[string]interface{}{"status": "lost"}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeOutboxService{messages: []models.OutboxMessage{{ID: 1, Topic: models.OutboxTopicAssignmentNotification}}}
+			api := &serverAPI{outboxService: service}
+
+			resp, err := api.ListMessages(context.Background(), newRequest(t, tt.fields))
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, service.status)
+			assert.Len(t, resp.GetFields()["messages"].GetListValue().GetValues(), 1)
+		})
+	}
+}
+
+func TestReplayMessage(t *testing.T) {
+	service := &fakeOutboxService{}
+	api := &serverAPI{outboxService: service}
+
+	resp, err := api.ReplayMessage(context.Background(), newRequest(t, map[string]interface{}{"message_id": float64(4)}))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), service.messageID)
+	assert.Equal(t, string(models.OutboxStatusPending), resp.GetFields()["status"].GetStringValue())
+}
+
+func TestReplayMessageErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "not admin", err: outbox.ErrPermissionDenied, wantCode: codes.PermissionDenied},
+		{name: "missing message", err: outbox.ErrMessageNotFound, wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{outboxService: &fakeOutboxService{err: tt.err}}
+
+			_, err := api.ReplayMessage(context.Background(), newRequest(t, map[string]interface{}{"message_id": float64(4)}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Package structrpc помогает регистрировать вручную методы, которых нет в пакете protos: запросы и ответы
+// передаются как google.protobuf.Struct, как в сервисе выгрузки
+package structrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"math"
+	"sort"
+	"time"
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+
+// UnaryFunc вызывает метод сервиса srv с разобранным запросом
+type UnaryFunc func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+
+// Unary описывает унарный метод; вызов проходит через перехватчики сервера так же, как у сгенерированных методов
+func Unary(serviceName, methodName string, call UnaryFunc) grpc.MethodDesc {
+	fullMethod := "/" + serviceName + "/" + methodName
+
+	return grpc.MethodDesc{
+		MethodName: methodName,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(structpb.Struct)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv, ctx, req)
+			}
+
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv, ctx, req.(*structpb.Struct))
+			}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// Marshal переводит значение в Struct через его JSON-представление; значение должно быть JSON-объектом
+func Marshal(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(structpb.Struct)
+	if err := protojson.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Reader читает поля запроса. Первая ошибка сохраняется и возвращается из Err вместе с проверкой,
+// что в запросе нет неизвестных полей
+type Reader struct {
+	fields map[string]*structpb.Value
+	known  map[string]bool
+	err    error
+}
+
+func NewReader(req *structpb.Struct) *Reader {
+	return &Reader{fields: req.GetFields(), known: make(map[string]bool)}
+}
+
+// Err возвращает первую ошибку чтения или ошибку о полях, которые метод не читает
+func (r *Reader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	var unknown []string
+	for name := range r.fields {
+		if !r.known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidRequest, unknown[0])
+	}
+
+	return nil
+}
+
+func (r *Reader) value(name string) (*structpb.Value, bool) {
+	r.known[name] = true
+	if r.err != nil {
+		return nil, false
+	}
+
+	value, ok := r.fields[name]
+	if !ok {
+		return nil, false
+	}
+	if _, null := value.GetKind().(*structpb.Value_NullValue); null {
+		return nil, false
+	}
+	return value, true
+}
+
+func (r *Reader) fail(name, want string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s must be %s", ErrInvalidRequest, name, want)
+	}
+}
+
+// String возвращает строковое поле или пустую строку, если поля нет
+func (r *Reader) String(name string) string {
+	s := r.OptionalString(name)
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// OptionalString возвращает строковое поле или nil, если поля нет
+func (r *Reader) OptionalString(name string) *string {
+	value, ok := r.value(name)
+	if !ok {
+		return nil
+	}
+
+	s, ok := value.GetKind().(*structpb.Value_StringValue)
+	if !ok {
+		r.fail(name, "a string")
+		return nil
+	}
+	return &s.StringValue
+}
+
+// ID возвращает обязательный положительный идентификатор
+func (r *Reader) ID(name string) int64 {
+	id := r.OptionalID(name)
+	if id == nil {
+		r.fail(name, "a positive integer")
+		return 0
+	}
+	return *id
+}
+
+// OptionalID возвращает положительный идентификатор или nil, если поля нет
+func (r *Reader) OptionalID(name string) *int64 {
+	value, ok := r.value(name)
+	if !ok {
+		return nil
+	}
+
+	id, ok := positiveInt(value)
+	if !ok {
+		r.fail(name, "a positive integer")
+		return nil
+	}
+	return &id
+}
+
+// IDs возвращает список положительных идентификаторов
+func (r *Reader) IDs(name string) []int64 {
+	values, ok := r.list(name, "a list of positive integers")
+	if !ok {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(values))
+	for _, value := range values {
+		id, ok := positiveInt(value)
+		if !ok {
+			r.fail(name, "a list of positive integers")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Int возвращает неотрицательное целое число или 0, если поля нет
+func (r *Reader) Int(name string) int {
+	value, ok := r.value(name)
+	if !ok {
+		return 0
+	}
+
+	n, ok := value.GetKind().(*structpb.Value_NumberValue)
+	if !ok || n.NumberValue < 0 || n.NumberValue != math.Trunc(n.NumberValue) || n.NumberValue > math.MaxInt32 {
+		r.fail(name, "a non-negative integer")
+		return 0
+	}
+	return int(n.NumberValue)
+}
+
+// Bool возвращает логическое поле или false, если поля нет
+func (r *Reader) Bool(name string) bool {
+	b := r.OptionalBool(name)
+	return b != nil && *b
+}
+
+// OptionalBool возвращает логическое поле или nil, если поля нет
+func (r *Reader) OptionalBool(name string) *bool {
+	value, ok := r.value(name)
+	if !ok {
+		return nil
+	}
+
+	b, ok := value.GetKind().(*structpb.Value_BoolValue)
+	if !ok {
+		r.fail(name, "a bool")
+		return nil
+	}
+	return &b.BoolValue
+}
+
+// Strings возвращает список строк
+func (r *Reader) Strings(name string) []string {
+	values, ok := r.list(name, "a list of strings")
+	if !ok {
+		return nil
+	}
+
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		s, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			r.fail(name, "a list of strings")
+			return nil
+		}
+		strs = append(strs, s.StringValue)
+	}
+	return strs
+}
+
+// StringMap возвращает объект со строковыми значениями
+func (r *Reader) StringMap(name string) map[string]string {
+	value, ok := r.value(name)
+	if !ok {
+		return nil
+	}
+
+	obj, ok := value.GetKind().(*structpb.Value_StructValue)
+	if !ok {
+		r.fail(name, "an object of strings")
+		return nil
+	}
+
+	m := make(map[string]string, len(obj.StructValue.GetFields()))
+	for key, item := range obj.StructValue.GetFields() {
+		s, ok := item.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			r.fail(name, "an object of strings")
+			return nil
+		}
+		m[key] = s.StringValue
+	}
+	return m
+}
+
+// Time возвращает время в формате RFC3339 или nil, если поля нет
+func (r *Reader) Time(name string) *time.Time {
+	s := r.String(name)
+	if s == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		r.fail(name, "RFC3339 time")
+		return nil
+	}
+	return &t
+}
+
+func (r *Reader) list(name, want string) ([]*structpb.Value, bool) {
+	value, ok := r.value(name)
+	if !ok {
+		return nil, false
+	}
+
+	list, ok := value.GetKind().(*structpb.Value_ListValue)
+	if !ok {
+		r.fail(name, want)
+		return nil, false
+	}
+	return list.ListValue.GetValues(), true
+}
+
+func positiveInt(value *structpb.Value) (int64, bool) {
+	n, ok := value.GetKind().(*structpb.Value_NumberValue)
+	if !ok || n.NumberValue <= 0 || n.NumberValue != math.Trunc(n.NumberValue) || n.NumberValue > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(n.NumberValue), true
+}
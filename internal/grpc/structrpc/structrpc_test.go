@@ -0,0 +1,115 @@
+package structrpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+	"time"
+)
+
+func newStruct(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestReader(t *testing.T) {
+	req := newStruct(t, map[string]interface{}{
+		"task_id":  float64(7),
+		"user_id":  nil,
+		"reason":   "spam",
+		"solution": "",
+		"task_ids": []interface{}{float64(1), float64(2)},
+		"labels":   []interface{}{"vip"},
+		"limit":    float64(20),
+		"fire":     true,
+		"vars":     map[string]interface{}{"name": "Иван"},
+		"from":     "2024-03-01T12:00:00Z",
+	})
+
+	r := NewReader(req)
+	assert.Equal(t, int64(7), r.ID("task_id"))
+	assert.Nil(t, r.OptionalID("user_id"))
+	assert.Equal(t, "spam", r.String("reason"))
+	assert.Equal(t, "", *r.OptionalString("solution"))
+	assert.Nil(t, r.OptionalString("note"))
+	assert.Equal(t, []int64{1, 2}, r.IDs("task_ids"))
+	assert.Equal(t, []string{"vip"}, r.Strings("labels"))
+	assert.Equal(t, 20, r.Int("limit"))
+	assert.True(t, r.Bool("fire"))
+	assert.Nil(t, r.OptionalBool("merged"))
+	assert.Equal(t, map[string]string{"name": "Иван"}, r.StringMap("vars"))
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), *r.Time("from"))
+	assert.NoError(t, r.Err())
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		read   func(r *Reader)
+	}{
+		{name: "missing id", read: func(r *Reader) { r.ID("task_id") }},
+		{name: "fractional id", fields: map[string]interface{}{"task_id": 1.5}, read: func(r *Reader) { r.ID("task_id") }},
+		{name: "negative id", fields: map[string]interface{}{"task_id": float64(-1)}, read: func(r *Reader) { r.ID("task_id") }},
+		{name: "string id", fields: map[string]interface{}{"task_id": "1"}, read: func(r *Reader) { r.ID("task_id") }},
+		{name: "id list item", fields: map[string]interface{}{"task_ids": []interface{}{float64(1), "2"}}, read: func(r *Reader) { r.IDs("task_ids") }},
+		{name: "negative int", fields: map[string]interface{}{"limit": float64(-1)}, read: func(r *Reader) { r.Int("limit") }},
+		{name: "bool", fields: map[string]interface{}{"fire": "yes"}, read: func(r *Reader) { r.Bool("fire") }},
+		{name: "time", fields: map[string]interface{}{"from": "yesterday"}, read: func(r *Reader) { r.Time("from") }},
+		{name: "map value", fields: map[string]interface{}{"vars": map[string]interface{}{"n": float64(1)}}, read: func(r *Reader) { r.StringMap("vars") }},
+		{name: "unknown field", fields: map[string]interface{}{"reason": "spam", "task": float64(1)}, read: func(r *Reader) { r.String("reason") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(newStruct(t, tt.fields))
+			tt.read(r)
+			assert.ErrorIs(t, r.Err(), ErrInvalidRequest)
+		})
+	}
+}
+
+func TestUnaryRunsInterceptor(t *testing.T) {
+	desc := Unary("test.Service", "Echo", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+		return req, nil
+	})
+	req := newStruct(t, map[string]interface{}{"reason": "spam"})
+
+	var fullMethod string
+	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		fullMethod = info.FullMethod
+		return handler(ctx, req)
+	}
+	dec := func(v interface{}) error {
+		v.(*structpb.Struct).Fields = req.Fields
+		return nil
+	}
+
+	resp, err := desc.Handler(nil, context.Background(), dec, interceptor)
+	require.NoError(t, err)
+	assert.Equal(t, "/test.Service/Echo", fullMethod)
+	assert.Equal(t, "spam", resp.(*structpb.Struct).GetFields()["reason"].GetStringValue())
+}
+
+func TestMarshal(t *testing.T) {
+	type item struct {
+		ID     int64   `json:"id"`
+		Secret []byte  `json:"-"`
+		Note   *string `json:"note"`
+	}
+
+	resp, err := Marshal(map[string]interface{}{"items": []item{{ID: 3, Secret: []byte("x")}}})
+	require.NoError(t, err)
+
+	items := resp.GetFields()["items"].GetListValue().GetValues()
+	require.Len(t, items, 1)
+	fields := items[0].GetStructValue().GetFields()
+	assert.Equal(t, float64(3), fields["id"].GetNumberValue())
+	assert.NotContains(t, fields, "Secret")
+	assert.IsType(t, &structpb.Value_NullValue{}, fields["note"].GetKind())
+}
@@ -0,0 +1,241 @@
+package attachments
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/structrpc"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/attachments"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+)
+
+// Вложения не описаны в пакете protos, поэтому сервис регистрируется вручную на стандартных типах:
+// описание файла передаётся как google.protobuf.Struct, содержимое - потоком google.protobuf.BytesValue
+const (
+	serviceName = "attachments.AttachmentService"
+
+	AttachmentService_UploadAttachment_FullMethodName   = "/" + serviceName + "/UploadAttachment"
+	AttachmentService_DownloadAttachment_FullMethodName = "/" + serviceName + "/DownloadAttachment"
+	AttachmentService_ListAttachments_FullMethodName    = "/" + serviceName + "/ListAttachments"
+	AttachmentService_DeleteAttachment_FullMethodName   = "/" + serviceName + "/DeleteAttachment"
+)
+
+// IdempotentMethods изменяющие унарные методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	AttachmentService_DeleteAttachment_FullMethodName,
+}
+
+type AttachmentService interface {
+	Upload(ctx context.Context, taskID int64, commentID *int64, fileName string, r io.Reader) (models.Attachment, error)
+	Download(ctx context.Context, attachmentID int64) (models.Attachment, io.ReadCloser, error)
+	ListAttachments(ctx context.Context, taskID int64, commentID *int64) ([]models.Attachment, error)
+	DeleteAttachment(ctx context.Context, attachmentID int64) error
+}
+
+type AttachmentServiceServer interface {
+	// UploadAttachment загружает файл: первое сообщение Struct с полями task_id, comment_id, file_name,
+	// следующие - BytesValue с частями файла. Ответ: вложение
+	UploadAttachment(stream grpc.ServerStream) error
+	// DownloadAttachment отдаёт файл; поля запроса: attachment_id. Первое сообщение ответа - Struct
+	// с вложением, следующие - BytesValue с частями файла не больше attachments.ChunkSize
+	DownloadAttachment(req *structpb.Struct, stream grpc.ServerStream) error
+	// ListAttachments возвращает вложения задачи или её комментария; поля: task_id, comment_id. Ответ: attachments
+	ListAttachments(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// DeleteAttachment удаляет вложение загрузившего его пользователя; поля: attachment_id. Ответ пустой
+	DeleteAttachment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*AttachmentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		structrpc.Unary(serviceName, "ListAttachments", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(AttachmentServiceServer).ListAttachments(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "DeleteAttachment", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(AttachmentServiceServer).DeleteAttachment(ctx, req)
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadAttachment",
+			Handler:       uploadAttachmentHandler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadAttachment",
+			Handler:       downloadAttachmentHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func uploadAttachmentHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AttachmentServiceServer).UploadAttachment(stream)
+}
+
+func downloadAttachmentHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(AttachmentServiceServer).DownloadAttachment(req, stream)
+}
+
+type serverAPI struct {
+	attachmentService AttachmentService
+}
+
+func Register(gRPC *grpc.Server, attachmentService AttachmentService) {
+	gRPC.RegisterService(&serviceDesc, &serverAPI{attachmentService: attachmentService})
+}
+
+func (s *serverAPI) UploadAttachment(stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	commentID := r.OptionalID("comment_id")
+	fileName := r.String("file_name")
+	if err := r.Err(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	attachment, err := s.attachmentService.Upload(stream.Context(), taskID, commentID, fileName, &chunkReader{stream: stream})
+	if err != nil {
+		return mapAttachmentError(stream.Context(), err)
+	}
+
+	resp, err := marshalResponse(attachment)
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(resp)
+}
+
+func (s *serverAPI) DownloadAttachment(req *structpb.Struct, stream grpc.ServerStream) error {
+	r := structrpc.NewReader(req)
+	attachmentID := r.ID("attachment_id")
+	if err := r.Err(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	attachment, content, err := s.attachmentService.Download(stream.Context(), attachmentID)
+	if err != nil {
+		return mapAttachmentError(stream.Context(), err)
+	}
+	defer content.Close()
+
+	resp, err := marshalResponse(attachment)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(resp); err != nil {
+		return err
+	}
+
+	buf := make([]byte, attachments.ChunkSize)
+	for {
+		n, err := io.ReadFull(content, buf)
+		if n > 0 {
+			if err := stream.SendMsg(wrapperspb.Bytes(buf[:n])); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return mapAttachmentError(stream.Context(), err)
+		}
+	}
+}
+
+func (s *serverAPI) ListAttachments(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	commentID := r.OptionalID("comment_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	list, err := s.attachmentService.ListAttachments(ctx, taskID, commentID)
+	if err != nil {
+		return nil, mapAttachmentError(ctx, err)
+	}
+	return marshalResponse(map[string]interface{}{"attachments": list})
+}
+
+func (s *serverAPI) DeleteAttachment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	attachmentID := r.ID("attachment_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.attachmentService.DeleteAttachment(ctx, attachmentID); err != nil {
+		return nil, mapAttachmentError(ctx, err)
+	}
+	return &structpb.Struct{}, nil
+}
+
+// chunkReader читает содержимое файла из сообщений потока загрузки
+type chunkReader struct {
+	stream grpc.ServerStream
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk := new(wrapperspb.BytesValue)
+		if err := r.stream.RecvMsg(chunk); err != nil {
+			return 0, err
+		}
+		r.buf = chunk.GetValue()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func mapAttachmentError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, attachments.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, "user is not authenticated")
+	case errors.Is(err, attachments.ErrTaskNotFound):
+		return status.Error(codes.NotFound, "task not found")
+	case errors.Is(err, attachments.ErrCommentNotFound):
+		return status.Error(codes.NotFound, "comment not found")
+	case errors.Is(err, attachments.ErrAttachmentNotFound):
+		return status.Error(codes.NotFound, "attachment not found")
+	case errors.Is(err, attachments.ErrNotUploader):
+		return status.Error(codes.PermissionDenied, "only the uploader can delete an attachment")
+	case errors.Is(err, attachments.ErrInvalidFileName), errors.Is(err, attachments.ErrEmptyFile), errors.Is(err, attachments.ErrContentTypeNotAllowed):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, attachments.ErrTooLarge):
+		return status.Error(codes.ResourceExhausted, "file is too large")
+	case errors.Is(err, attachments.ErrBlobReleased):
+		return status.Error(codes.Aborted, err.Error())
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func marshalResponse(v interface{}) (*structpb.Struct, error) {
+	resp, err := structrpc.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
@@ -0,0 +1,159 @@
+package attachments
+
+import (
+	"bytes"
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/attachments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"testing"
+)
+
+// fakeAttachmentService читает загрузку целиком и отдаёт content при скачивании
+type fakeAttachmentService struct {
+	AttachmentService
+
+	content []byte
+	err     error
+
+	taskID    int64
+	commentID *int64
+	fileName  string
+	uploaded  []byte
+	closed    bool
+}
+
+func (f *fakeAttachmentService) Upload(_ context.Context, taskID int64, commentID *int64, fileName string, r io.Reader) (models.Attachment, error) {
+	f.taskID, f.commentID, f.fileName = taskID, commentID, fileName
+	uploaded, err := io.ReadAll(r)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	f.uploaded = uploaded
+	if f.err != nil {
+		return models.Attachment{}, f.err
+	}
+	return models.Attachment{ID: 1, TaskID: taskID, FileName: fileName, Size: int64(len(uploaded))}, nil
+}
+
+func (f *fakeAttachmentService) Download(_ context.Context, attachmentID int64) (models.Attachment, io.ReadCloser, error) {
+	if f.err != nil {
+		return models.Attachment{}, nil, f.err
+	}
+	return models.Attachment{ID: attachmentID, FileName: "scan.pdf"}, &closeRecorder{Reader: bytes.NewReader(f.content), closed: &f.closed}, nil
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed *bool
+}
+
+func (c *closeRecorder) Close() error {
+	*c.closed = true
+	return nil
+}
+
+// fakeStream отдаёт сообщения received и собирает отправленные
+type fakeStream struct {
+	grpc.ServerStream
+
+	received []proto.Message
+	sent     []proto.Message
+}
+
+func (f *fakeStream) Context() context.Context { return context.Background() }
+
+func (f *fakeStream) RecvMsg(m interface{}) error {
+	if len(f.received) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), f.received[0])
+	f.received = f.received[1:]
+	return nil
+}
+
+func (f *fakeStream) SendMsg(m interface{}) error {
+	f.sent = append(f.sent, m.(proto.Message))
+	return nil
+}
+
+func newRequest(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestUploadAttachment(t *testing.T) {
+	service := &fakeAttachmentService{}
+	api := &serverAPI{attachmentService: service}
+	stream := &fakeStream{received: []proto.Message{
+		newRequest(t, map[string]interface{}{"task_id": float64(5), "comment_id": float64(8), "file_name": "scan.pdf"}),
+		wrapperspb.Bytes([]byte("%PDF-")),
+		wrapperspb.Bytes([]byte("1.7")),
+	}}
+
+	require.NoError(t, api.UploadAttachment(stream))
+
+	assert.Equal(t, int64(5), service.taskID)
+	require.NotNil(t, service.commentID)
+	assert.Equal(t, int64(8), *service.commentID)
+	assert.Equal(t, "scan.pdf", service.fileName)
+	assert.Equal(t, []byte("%PDF-1.7"), service.uploaded)
+
+	require.Len(t, stream.sent, 1)
+	assert.Equal(t, float64(8), stream.sent[0].(*structpb.Struct).GetFields()["size"].GetNumberValue())
+}
+
+func TestUploadAttachmentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "missing task", fields: map[string]interface{}{"file_name": "scan.pdf"}, wantCode: codes.InvalidArgument},
+		{name: "too large", fields: map[string]interface{}{"task_id": float64(5)}, err: attachments.ErrTooLarge, wantCode: codes.ResourceExhausted},
+		{name: "not allowed", fields: map[string]interface{}{"task_id": float64(5)}, err: attachments.ErrContentTypeNotAllowed, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{attachmentService: &fakeAttachmentService{err: tt.err}}
+			stream := &fakeStream{received: []proto.Message{newRequest(t, tt.fields), wrapperspb.Bytes([]byte("data"))}}
+
+			err := api.UploadAttachment(stream)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), attachments.ChunkSize+10)
+	service := &fakeAttachmentService{content: content}
+	api := &serverAPI{attachmentService: service}
+	stream := &fakeStream{}
+
+	require.NoError(t, api.DownloadAttachment(newRequest(t, map[string]interface{}{"attachment_id": float64(3)}), stream))
+
+	require.Len(t, stream.sent, 3)
+	assert.Equal(t, "scan.pdf", stream.sent[0].(*structpb.Struct).GetFields()["file_name"].GetStringValue())
+	assert.Len(t, stream.sent[1].(*wrapperspb.BytesValue).GetValue(), attachments.ChunkSize)
+	assert.Len(t, stream.sent[2].(*wrapperspb.BytesValue).GetValue(), 10)
+	assert.True(t, service.closed)
+}
+
+func TestDownloadMissingAttachment(t *testing.T) {
+	api := &serverAPI{attachmentService: &fakeAttachmentService{err: attachments.ErrAttachmentNotFound}}
+
+	err := api.DownloadAttachment(newRequest(t, map[string]interface{}{"attachment_id": float64(3)}), &fakeStream{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
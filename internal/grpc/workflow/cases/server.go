@@ -19,6 +19,14 @@ type CaseService interface {
 	UpdateClusterName(ctx context.Context, clusterID int64, clusterName string) (models.Cluster, error)
 }
 
+// IdempotentMethods изменяющие методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	casesv1.CaseService_CreateCase_FullMethodName,
+	casesv1.CaseService_UpdateCase_FullMethodName,
+	casesv1.CaseService_DeleteCase_FullMethodName,
+	casesv1.CaseService_UpdateClusterName_FullMethodName,
+}
+
 type serverAPI struct {
 	casesv1.UnimplementedCaseServiceServer
 	caseService CaseService
@@ -0,0 +1,152 @@
+package comments
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/structrpc"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/comments"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Комментарии не описаны в пакете protos, поэтому сервис регистрируется вручную:
+// запросы и ответы передаются как google.protobuf.Struct, комментарии - в их JSON-представлении
+const (
+	serviceName = "comments.CommentService"
+
+	CommentService_AddComment_FullMethodName   = "/" + serviceName + "/AddComment"
+	CommentService_ListComments_FullMethodName = "/" + serviceName + "/ListComments"
+	CommentService_EditComment_FullMethodName  = "/" + serviceName + "/EditComment"
+)
+
+// IdempotentMethods изменяющие методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	CommentService_AddComment_FullMethodName,
+	CommentService_EditComment_FullMethodName,
+}
+
+type CommentService interface {
+	AddComment(ctx context.Context, taskID int64, body string, visibility models.CommentVisibility) (models.TaskComment, error)
+	ListComments(ctx context.Context, taskID int64, includeInternal bool, pageToken string, pageSize int) (comments.CommentPage, error)
+	EditComment(ctx context.Context, commentID int64, body string, visibility models.CommentVisibility) (models.TaskComment, error)
+	CanSeeInternal(ctx context.Context) (bool, error)
+}
+
+type CommentServiceServer interface {
+	// AddComment добавляет комментарий от имени пользователя из контекста; поля: task_id, body,
+	// visibility (internal, customer)
+	AddComment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// ListComments возвращает страницу комментариев задачи; внутренние заметки видят только агенты и администраторы.
+	// Поля: task_id, page_token, page_size. Ответ: comments, next_page_token
+	ListComments(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// EditComment изменяет комментарий автора; поля: comment_id, body, visibility
+	EditComment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*CommentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		structrpc.Unary(serviceName, "AddComment", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(CommentServiceServer).AddComment(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "ListComments", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(CommentServiceServer).ListComments(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "EditComment", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(CommentServiceServer).EditComment(ctx, req)
+		}),
+	},
+}
+
+type serverAPI struct {
+	commentService CommentService
+}
+
+func Register(gRPC *grpc.Server, commentService CommentService) {
+	gRPC.RegisterService(&serviceDesc, &serverAPI{commentService: commentService})
+}
+
+func (s *serverAPI) AddComment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	body := r.String("body")
+	visibility := models.CommentVisibility(r.String("visibility"))
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	comment, err := s.commentService.AddComment(ctx, taskID, body, visibility)
+	if err != nil {
+		return nil, mapCommentError(err)
+	}
+	return marshalResponse(comment)
+}
+
+func (s *serverAPI) ListComments(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	pageToken := r.String("page_token")
+	pageSize := r.Int("page_size")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	includeInternal, err := s.commentService.CanSeeInternal(ctx)
+	if err != nil {
+		return nil, mapCommentError(err)
+	}
+
+	page, err := s.commentService.ListComments(ctx, taskID, includeInternal, pageToken, pageSize)
+	if err != nil {
+		return nil, mapCommentError(err)
+	}
+	return marshalResponse(map[string]interface{}{
+		"comments":        page.Comments,
+		"next_page_token": page.NextPageToken,
+	})
+}
+
+func (s *serverAPI) EditComment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	commentID := r.ID("comment_id")
+	body := r.String("body")
+	visibility := models.CommentVisibility(r.String("visibility"))
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	comment, err := s.commentService.EditComment(ctx, commentID, body, visibility)
+	if err != nil {
+		return nil, mapCommentError(err)
+	}
+	return marshalResponse(comment)
+}
+
+func mapCommentError(err error) error {
+	switch {
+	case errors.Is(err, comments.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, "user is not authenticated")
+	case errors.Is(err, comments.ErrTaskNotFound):
+		return status.Error(codes.NotFound, "task not found")
+	case errors.Is(err, comments.ErrCommentNotFound):
+		return status.Error(codes.NotFound, "comment not found")
+	case errors.Is(err, comments.ErrNotAuthor):
+		return status.Error(codes.PermissionDenied, "only the author can change a comment")
+	case errors.Is(err, comments.ErrEmptyBody), errors.Is(err, comments.ErrInvalidVisibility), errors.Is(err, comments.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func marshalResponse(v interface{}) (*structpb.Struct, error) {
+	resp, err := structrpc.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
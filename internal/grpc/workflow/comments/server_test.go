@@ -0,0 +1,123 @@
+package comments
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/comments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+// fakeCommentService запоминает аргументы последнего вызова
+type fakeCommentService struct {
+	CommentService
+
+	staff bool
+	page  comments.CommentPage
+	err   error
+
+	taskID          int64
+	includeInternal bool
+	pageToken       string
+	pageSize        int
+	body            string
+	visibility      models.CommentVisibility
+}
+
+func (f *fakeCommentService) CanSeeInternal(context.Context) (bool, error) {
+	return f.staff, nil
+}
+
+func (f *fakeCommentService) ListComments(_ context.Context, taskID int64, includeInternal bool, pageToken string, pageSize int) (comments.CommentPage, error) {
+	f.taskID, f.includeInternal, f.pageToken, f.pageSize = taskID, includeInternal, pageToken, pageSize
+	return f.page, f.err
+}
+
+func (f *fakeCommentService) AddComment(_ context.Context, taskID int64, body string, visibility models.CommentVisibility) (models.TaskComment, error) {
+	f.taskID, f.body, f.visibility = taskID, body, visibility
+	if f.err != nil {
+		return models.TaskComment{}, f.err
+	}
+	return models.TaskComment{ID: 1, TaskID: taskID, Body: body, Visibility: visibility}, nil
+}
+
+func newRequest(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestListCommentsUsesCallerRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		staff bool
+	}{
+		{name: "agent sees internal notes", staff: true},
+		{name: "other callers see customer comments", staff: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeCommentService{
+				staff: tt.staff,
+				page:  comments.CommentPage{Comments: []models.TaskComment{{ID: 3}}, NextPageToken: "3"},
+			}
+			api := &serverAPI{commentService: service}
+
+			resp, err := api.ListComments(context.Background(), newRequest(t, map[string]interface{}{
+				"task_id":    float64(5),
+				"page_token": "1",
+				"page_size":  float64(2),
+			}))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.staff, service.includeInternal)
+			assert.Equal(t, int64(5), service.taskID)
+			assert.Equal(t, "1", service.pageToken)
+			assert.Equal(t, 2, service.pageSize)
+			assert.Len(t, resp.GetFields()["comments"].GetListValue().GetValues(), 1)
+			assert.Equal(t, "3", resp.GetFields()["next_page_token"].GetStringValue())
+		})
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	service := &fakeCommentService{}
+	api := &serverAPI{commentService: service}
+
+	resp, err := api.AddComment(context.Background(), newRequest(t, map[string]interface{}{
+		"task_id":    float64(5),
+		"body":       "called the client",
+		"visibility": "internal",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, models.CommentVisibilityInternal, service.visibility)
+	assert.Equal(t, "called the client", resp.GetFields()["body"].GetStringValue())
+}
+
+func TestAddCommentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "empty body", err: comments.ErrEmptyBody, wantCode: codes.InvalidArgument},
+		{name: "missing task", err: comments.ErrTaskNotFound, wantCode: codes.NotFound},
+		{name: "no user", err: comments.ErrUnauthenticated, wantCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{commentService: &fakeCommentService{err: tt.err}}
+
+			_, err := api.AddComment(context.Background(), newRequest(t, map[string]interface{}{"task_id": float64(5)}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/export"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"math"
+	"time"
+)
+
+// Сервис выгрузки не описан в пакете protos, поэтому он регистрируется вручную на стандартных типах:
+// запрос google.protobuf.Struct с фильтрами, ответ поток google.protobuf.BytesValue с частями файла
+const (
+	serviceName = "export.ExportService"
+
+	ExportService_ExportTasks_FullMethodName = "/" + serviceName + "/ExportTasks"
+
+	// chunkSize максимальный размер части выгрузки в одном сообщении потока
+	chunkSize = 64 * 1024
+)
+
+type Exporter interface {
+	Export(ctx context.Context, query models.TaskQuery, format export.Format, w io.Writer) (int, error)
+}
+
+type ExportServiceServer interface {
+	// ExportTasks выгружает задачи; поля запроса: format (csv, json, ndjson), status, cluster_id, user_id,
+	// requester_id, fire, has_case, has_solution, merged, labels, created_from, created_to,
+	// completed_from и completed_to (RFC3339)
+	ExportTasks(req *structpb.Struct, stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*ExportServiceServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportTasks",
+			Handler:       exportTasksHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func exportTasksHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(ExportServiceServer).ExportTasks(req, stream)
+}
+
+type serverAPI struct {
+	exporter Exporter
+}
+
+func Register(gRPC *grpc.Server, exporter Exporter) {
+	gRPC.RegisterService(&serviceDesc, &serverAPI{exporter: exporter})
+}
+
+func (s *serverAPI) ExportTasks(req *structpb.Struct, stream grpc.ServerStream) error {
+	format, query, err := parseRequest(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	w := &chunkWriter{stream: stream}
+	if _, err := s.exporter.Export(stream.Context(), query, format, w); err != nil {
+		return mapExportError(stream.Context(), err)
+	}
+	if err := w.Flush(); err != nil {
+		return mapExportError(stream.Context(), err)
+	}
+
+	return nil
+}
+
+func mapExportError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, export.ErrInvalidFormat), errors.Is(err, export.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// chunkWriter копит выгрузку и отправляет её в поток частями не больше chunkSize
+type chunkWriter struct {
+	stream grpc.ServerStream
+	buf    []byte
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+
+		if len(w.buf) == chunkSize {
+			if err := w.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush отправляет накопленную часть выгрузки
+func (w *chunkWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.stream.SendMsg(wrapperspb.Bytes(w.buf)); err != nil {
+		return err
+	}
+	w.buf = make([]byte, 0, chunkSize)
+	return nil
+}
+
+func parseRequest(req *structpb.Struct) (export.Format, models.TaskQuery, error) {
+	format := export.FormatCSV
+	var query models.TaskQuery
+
+	for name, value := range req.GetFields() {
+		var err error
+		switch name {
+		case "format":
+			var formatName string
+			if formatName, err = stringField(name, value); err == nil {
+				format, err = export.ParseFormat(formatName)
+			}
+		case "status":
+			var statusName string
+			if statusName, err = stringField(name, value); err == nil {
+				var taskStatus models.TaskStatus
+				taskStatus, err = export.ParseStatus(statusName)
+				query.Status = &taskStatus
+			}
+		case "cluster_id":
+			query.ClusterID, err = idField(name, value)
+		case "user_id":
+			query.UserID, err = idField(name, value)
+		case "requester_id":
+			query.RequesterID, err = idField(name, value)
+		case "fire":
+			query.Fire, err = boolField(name, value)
+		case "has_case":
+			query.HasCase, err = boolField(name, value)
+		case "has_solution":
+			query.HasSolution, err = boolField(name, value)
+		case "merged":
+			query.Merged, err = boolField(name, value)
+		case "labels":
+			query.Labels, err = stringsField(name, value)
+		case "created_from":
+			query.CreatedFrom, err = timeField(name, value)
+		case "created_to":
+			query.CreatedTo, err = timeField(name, value)
+		case "completed_from":
+			query.CompletedFrom, err = timeField(name, value)
+		case "completed_to":
+			query.CompletedTo, err = timeField(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %q", export.ErrInvalidQuery, name)
+		}
+		if err != nil {
+			return "", models.TaskQuery{}, err
+		}
+	}
+
+	return format, query, nil
+}
+
+func stringField(name string, value *structpb.Value) (string, error) {
+	s, ok := value.GetKind().(*structpb.Value_StringValue)
+	if !ok {
+		return "", fmt.Errorf("%w: %s must be a string", export.ErrInvalidQuery, name)
+	}
+	return s.StringValue, nil
+}
+
+func idField(name string, value *structpb.Value) (*int64, error) {
+	n, ok := value.GetKind().(*structpb.Value_NumberValue)
+	if !ok || n.NumberValue <= 0 || n.NumberValue != math.Trunc(n.NumberValue) || n.NumberValue > math.MaxInt64 {
+		return nil, fmt.Errorf("%w: %s must be a positive integer", export.ErrInvalidQuery, name)
+	}
+	id := int64(n.NumberValue)
+	return &id, nil
+}
+
+func boolField(name string, value *structpb.Value) (*bool, error) {
+	b, ok := value.GetKind().(*structpb.Value_BoolValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a bool", export.ErrInvalidQuery, name)
+	}
+	return &b.BoolValue, nil
+}
+
+func stringsField(name string, value *structpb.Value) ([]string, error) {
+	list, ok := value.GetKind().(*structpb.Value_ListValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a list of strings", export.ErrInvalidQuery, name)
+	}
+
+	values := make([]string, 0, len(list.ListValue.GetValues()))
+	for _, item := range list.ListValue.GetValues() {
+		s, err := stringField(name, item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+func timeField(name string, value *structpb.Value) (*time.Time, error) {
+	s, err := stringField(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be RFC3339 time", export.ErrInvalidQuery, name)
+	}
+	return &t, nil
+}
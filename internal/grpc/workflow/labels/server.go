@@ -0,0 +1,157 @@
+package labels
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/structrpc"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Каталог меток не описан в пакете protos, поэтому сервис регистрируется вручную:
+// запросы и ответы передаются как google.protobuf.Struct, метки - в их JSON-представлении
+const (
+	serviceName = "labels.LabelService"
+
+	LabelService_CreateLabel_FullMethodName = "/" + serviceName + "/CreateLabel"
+	LabelService_UpdateLabel_FullMethodName = "/" + serviceName + "/UpdateLabel"
+	LabelService_DeleteLabel_FullMethodName = "/" + serviceName + "/DeleteLabel"
+	LabelService_ListLabels_FullMethodName  = "/" + serviceName + "/ListLabels"
+)
+
+// IdempotentMethods изменяющие методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	LabelService_CreateLabel_FullMethodName,
+	LabelService_UpdateLabel_FullMethodName,
+	LabelService_DeleteLabel_FullMethodName,
+}
+
+type LabelService interface {
+	CreateLabel(ctx context.Context, name, color, description string) (models.Label, error)
+	UpdateLabel(ctx context.Context, labelID int64, name, color, description string) (models.Label, error)
+	DeleteLabel(ctx context.Context, labelID int64) error
+	ListLabels(ctx context.Context) ([]models.Label, error)
+}
+
+type LabelServiceServer interface {
+	// CreateLabel добавляет метку в каталог; поля: name, color (#rrggbb), description
+	CreateLabel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// UpdateLabel изменяет метку; поля: label_id, name, color, description
+	UpdateLabel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// DeleteLabel удаляет метку из каталога и со всех задач; поля: label_id. Ответ пустой
+	DeleteLabel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// ListLabels возвращает каталог меток. Ответ: labels
+	ListLabels(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*LabelServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		structrpc.Unary(serviceName, "CreateLabel", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(LabelServiceServer).CreateLabel(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "UpdateLabel", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(LabelServiceServer).UpdateLabel(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "DeleteLabel", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(LabelServiceServer).DeleteLabel(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "ListLabels", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(LabelServiceServer).ListLabels(ctx, req)
+		}),
+	},
+}
+
+type serverAPI struct {
+	labelService LabelService
+}
+
+func Register(gRPC *grpc.Server, labelService LabelService) {
+	gRPC.RegisterService(&serviceDesc, &serverAPI{labelService: labelService})
+}
+
+func (s *serverAPI) CreateLabel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	name := r.String("name")
+	color := r.String("color")
+	description := r.String("description")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	label, err := s.labelService.CreateLabel(ctx, name, color, description)
+	if err != nil {
+		return nil, mapLabelError(err)
+	}
+	return marshalResponse(label)
+}
+
+func (s *serverAPI) UpdateLabel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	labelID := r.ID("label_id")
+	name := r.String("name")
+	color := r.String("color")
+	description := r.String("description")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	label, err := s.labelService.UpdateLabel(ctx, labelID, name, color, description)
+	if err != nil {
+		return nil, mapLabelError(err)
+	}
+	return marshalResponse(label)
+}
+
+func (s *serverAPI) DeleteLabel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	labelID := r.ID("label_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.labelService.DeleteLabel(ctx, labelID); err != nil {
+		return nil, mapLabelError(err)
+	}
+	return &structpb.Struct{}, nil
+}
+
+func (s *serverAPI) ListLabels(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	if err := structrpc.NewReader(req).Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	list, err := s.labelService.ListLabels(ctx)
+	if err != nil {
+		return nil, mapLabelError(err)
+	}
+	return marshalResponse(map[string]interface{}{"labels": list})
+}
+
+func mapLabelError(err error) error {
+	switch {
+	case errors.Is(err, labels.ErrInvalidName):
+		return status.Error(codes.InvalidArgument, "invalid label name")
+	case errors.Is(err, labels.ErrInvalidColor):
+		return status.Error(codes.InvalidArgument, "invalid label color")
+	case errors.Is(err, labels.ErrLabelNotFound):
+		return status.Error(codes.NotFound, "label not found")
+	case errors.Is(err, labels.ErrLabelExists):
+		return status.Error(codes.AlreadyExists, "label already exists")
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func marshalResponse(v interface{}) (*structpb.Struct, error) {
+	resp, err := structrpc.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
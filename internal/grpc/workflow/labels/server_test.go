@@ -0,0 +1,97 @@
+package labels
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+// fakeLabelService запоминает аргументы последнего вызова
+type fakeLabelService struct {
+	LabelService
+
+	labels []models.Label
+	err    error
+
+	labelID int64
+	name    string
+	color   string
+}
+
+func (f *fakeLabelService) CreateLabel(_ context.Context, name, color, description string) (models.Label, error) {
+	f.name, f.color = name, color
+	if f.err != nil {
+		return models.Label{}, f.err
+	}
+	return models.Label{ID: 1, Name: name, Color: color, Description: description}, nil
+}
+
+func (f *fakeLabelService) DeleteLabel(_ context.Context, labelID int64) error {
+	f.labelID = labelID
+	return f.err
+}
+
+func (f *fakeLabelService) ListLabels(context.Context) ([]models.Label, error) {
+	return f.labels, f.err
+}
+
+func newRequest(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestCreateLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "created", wantCode: codes.OK},
+		{name: "bad color", err: labels.ErrInvalidColor, wantCode: codes.InvalidArgument},
+		{name: "duplicate", err: labels.ErrLabelExists, wantCode: codes.AlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeLabelService{err: tt.err}
+			api := &serverAPI{labelService: service}
+
+			resp, err := api.CreateLabel(context.Background(), newRequest(t, map[string]interface{}{
+				"name":  "vip",
+				"color": "#ff0000",
+			}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, "vip", service.name)
+			assert.Equal(t, "#ff0000", service.color)
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "vip", resp.GetFields()["name"].GetStringValue())
+			}
+		})
+	}
+}
+
+func TestDeleteLabel(t *testing.T) {
+	service := &fakeLabelService{err: labels.ErrLabelNotFound}
+	api := &serverAPI{labelService: service}
+
+	_, err := api.DeleteLabel(context.Background(), newRequest(t, map[string]interface{}{"label_id": float64(3)}))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, int64(3), service.labelID)
+}
+
+func TestListLabels(t *testing.T) {
+	api := &serverAPI{labelService: &fakeLabelService{labels: []models.Label{{ID: 1, Name: "vip"}, {ID: 2, Name: "fraud"}}}}
+
+	resp, err := api.ListLabels(context.Background(), newRequest(t, map[string]interface{}{}))
+	require.NoError(t, err)
+
+	assert.Len(t, resp.GetFields()["labels"].GetListValue().GetValues(), 2)
+}
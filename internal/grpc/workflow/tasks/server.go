@@ -5,17 +5,24 @@ import (
 	"errors"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/templates"
 	tasksv1 "github.com/markgregr/bestHack_support_protos/gen/go/workflow/tasks"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
 )
 
 type TaskService interface {
 	CreateTask(ctx context.Context, title, description string, clusterIndex int64, clusterName string, frequency int64, avarage_duration float32) (models.Task, error)
 	GetTask(ctx context.Context, taskID int64) (models.Task, error)
-	ListTasks(ctx context.Context, status models.TaskStatus) ([]models.Task, error)
+	ListTasks(ctx context.Context, status models.TaskStatus, labels ...string) ([]models.Task, error)
 	ChangeTaskStatus(ctx context.Context, taskID int64) (models.Task, error)
 	AddCaseToTask(ctx context.Context, taskID, caseID int64) (models.Task, error)
 	AddSolutionToTask(ctx context.Context, taskID int64, solution string) (models.Task, error)
@@ -23,8 +30,59 @@ type TaskService interface {
 	RemoveSolutionFromTask(ctx context.Context, taskID int64) (models.Task, error)
 	AppointUserToTask(ctx context.Context, taskID int64) (models.Task, error)
 	FireTask(ctx context.Context, taskID int64) (models.Task, error)
-	ListTasksByUserID(ctx context.Context, userID int64, status models.TaskStatus) ([]models.Task, error)
+	ListTasksByUserID(ctx context.Context, userID int64, status models.TaskStatus, labels ...string) ([]models.Task, error)
 	ListUsers(ctx context.Context, empty *empty.Empty) ([]models.User, error)
+	TransitionTask(ctx context.Context, taskID int64, target models.TaskStatus, reason string) (models.Task, error)
+	GetTaskHistory(ctx context.Context, taskID int64) ([]models.TaskEvent, error)
+	WatchTasks(ctx context.Context, filter taskfeed.Filter, resumeToken string) (<-chan taskfeed.Event, error)
+	ExplainAssignment(ctx context.Context, taskID int64) (assignment.Decision, error)
+	BulkCloseTasks(ctx context.Context, taskIDs []int64, caseID *int64, solution *string, reason string, mode tasks.BulkMode) ([]tasks.BulkResult, error)
+	BulkAssignTasks(ctx context.Context, taskIDs []int64, userID int64, mode tasks.BulkMode) ([]tasks.BulkResult, error)
+	BulkFireTasks(ctx context.Context, taskIDs []int64, fire bool, reason *string, mode tasks.BulkMode) ([]tasks.BulkResult, error)
+	ListQueue(ctx context.Context, clusterID *int64, limit int) ([]models.Task, error)
+	UnassignTask(ctx context.Context, taskID int64, note string) (models.Task, error)
+	ReassignTask(ctx context.Context, taskID, userID int64, note string) (models.Task, error)
+	MergeTasks(ctx context.Context, parentID int64, childIDs []int64, reason string) (models.Task, error)
+	LinkTasks(ctx context.Context, fromID, toID int64, kind models.TaskLinkKind) (models.TaskLink, error)
+	UnlinkTasks(ctx context.Context, linkID int64) error
+	AddLabelsToTask(ctx context.Context, taskID int64, names []string) (models.Task, error)
+	RemoveLabelsFromTask(ctx context.Context, taskID int64, names []string) (models.Task, error)
+	SearchTasks(ctx context.Context, query models.TaskSearchQuery) ([]models.TaskSearchHit, error)
+	RestoreTask(ctx context.Context, taskID int64) (models.Task, error)
+	RequesterHistory(ctx context.Context, requesterID int64, cursor string, limit int) (models.TaskPage, error)
+	RequesterOpenTasks(ctx context.Context, requesterID int64, cursor string, limit int) (models.TaskPage, error)
+	CreateTaskFromTemplate(ctx context.Context, templateID int64, vars map[string]string, requester *models.Requester) (models.Task, error)
+}
+
+// taskVersionHeader заголовок ответа с текущей версией задачи
+const taskVersionHeader = "task_version"
+
+// labelsMetadataKey заголовок запроса со списком меток через запятую: в запросах списков задач нет поля для меток
+const labelsMetadataKey = "labels"
+
+// IdempotentMethods изменяющие методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	tasksv1.TaskService_CreateTask_FullMethodName,
+	tasksv1.TaskService_ChangeTaskStatus_FullMethodName,
+	tasksv1.TaskService_AddCaseToTask_FullMethodName,
+	tasksv1.TaskService_AddSolutionToTask_FullMethodName,
+	tasksv1.TaskService_RemoveSolutionFromTask_FullMethodName,
+	tasksv1.TaskService_RemoveCaseFromTask_FullMethodName,
+	tasksv1.TaskService_AppointUserToTask_FullMethodName,
+	tasksv1.TaskService_FireTask_FullMethodName,
+	TaskWorkflowService_TransitionTask_FullMethodName,
+	TaskWorkflowService_BulkCloseTasks_FullMethodName,
+	TaskWorkflowService_BulkAssignTasks_FullMethodName,
+	TaskWorkflowService_BulkFireTasks_FullMethodName,
+	TaskWorkflowService_UnassignTask_FullMethodName,
+	TaskWorkflowService_ReassignTask_FullMethodName,
+	TaskWorkflowService_MergeTasks_FullMethodName,
+	TaskWorkflowService_LinkTasks_FullMethodName,
+	TaskWorkflowService_UnlinkTasks_FullMethodName,
+	TaskWorkflowService_AddLabelsToTask_FullMethodName,
+	TaskWorkflowService_RemoveLabelsFromTask_FullMethodName,
+	TaskWorkflowService_RestoreTask_FullMethodName,
+	TaskWorkflowService_CreateTaskFromTemplate_FullMethodName,
 }
 
 type serverAPI struct {
@@ -33,7 +91,9 @@ type serverAPI struct {
 }
 
 func Register(gRPC *grpc.Server, taskService TaskService) {
-	tasksv1.RegisterTaskServiceServer(gRPC, &serverAPI{taskService: taskService})
+	api := &serverAPI{taskService: taskService}
+	tasksv1.RegisterTaskServiceServer(gRPC, api)
+	gRPC.RegisterService(&workflowServiceDesc, api)
 }
 
 func (s *serverAPI) CreateTask(ctx context.Context, req *tasksv1.CreateTaskRequest) (*tasksv1.Task, error) {
@@ -47,16 +107,14 @@ func (s *serverAPI) CreateTask(ctx context.Context, req *tasksv1.CreateTaskReque
 func (s *serverAPI) GetTask(ctx context.Context, req *tasksv1.GetTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.GetTask(ctx, req.GetTaskId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) ListTasks(ctx context.Context, req *tasksv1.ListTasksRequest) (*tasksv1.ListTasksResponse, error) {
-	tasks, err := s.taskService.ListTasks(ctx, models.TaskStatus(req.GetStatus()))
+	tasks, err := s.taskService.ListTasks(ctx, models.TaskStatus(req.GetStatus()), labelsFromMetadata(ctx)...)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -66,82 +124,68 @@ func (s *serverAPI) ListTasks(ctx context.Context, req *tasksv1.ListTasksRequest
 func (s *serverAPI) ChangeTaskStatus(ctx context.Context, req *tasksv1.ChangeTaskStatusRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.ChangeTaskStatus(ctx, req.GetTaskId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) AddCaseToTask(ctx context.Context, req *tasksv1.AddCaseToTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.AddCaseToTask(ctx, req.GetTaskId(), req.GetCaseId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) AddSolutionToTask(ctx context.Context, req *tasksv1.AddSolutionToTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.AddSolutionToTask(ctx, req.GetTaskId(), req.GetSolution())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) RemoveCaseFromTask(ctx context.Context, req *tasksv1.RemoveCaseFromTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.RemoveCaseFromTask(ctx, req.GetTaskId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) RemoveSolutionFromTask(ctx context.Context, req *tasksv1.RemoveSolutionFromTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.RemoveSolutionFromTask(ctx, req.GetTaskId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) AppointUserToTask(ctx context.Context, req *tasksv1.AppointUserToTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.AppointUserToTask(ctx, req.GetTaskId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) FireTask(ctx context.Context, req *tasksv1.FireTaskRequest) (*tasksv1.Task, error) {
 	task, err := s.taskService.FireTask(ctx, req.GetTaskId())
 	if err != nil {
-		if errors.Is(err, tasks.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, mapTaskError(err)
 	}
+	setTaskVersionHeader(ctx, task)
 	return ConvertTaskToProto(task), nil
 }
 
 func (s *serverAPI) ListTasksByUserID(ctx context.Context, req *tasksv1.ListTasksByUserIDRequest) (*tasksv1.ListTasksResponse, error) {
-	tasks, err := s.taskService.ListTasksByUserID(ctx, req.GetUserId(), models.TaskStatus(req.GetStatus()))
+	tasks, err := s.taskService.ListTasksByUserID(ctx, req.GetUserId(), models.TaskStatus(req.GetStatus()), labelsFromMetadata(ctx)...)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -155,3 +199,71 @@ func (s *serverAPI) ListUsers(ctx context.Context, req *empty.Empty) (*tasksv1.L
 	}
 	return &tasksv1.ListUsersResponse{Users: ConvertUserListToProto(users)}, nil
 }
+
+// labelsFromMetadata возвращает метки фильтра из заголовков запроса
+func labelsFromMetadata(ctx context.Context) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var labels []string
+	for _, value := range md.Get(labelsMetadataKey) {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				labels = append(labels, name)
+			}
+		}
+	}
+	return labels
+}
+
+// setTaskVersionHeader передаёт версию задачи в заголовке ответа, чтобы клиент мог отправить её
+// в следующем изменяющем запросе
+func setTaskVersionHeader(ctx context.Context, task models.Task) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(taskVersionHeader, strconv.FormatInt(task.Version, 10)))
+}
+
+// mapTaskError переводит ошибки сервиса задач в статусы gRPC
+func mapTaskError(err error) error {
+	switch {
+	case errors.Is(err, tasks.ErrInvalidCredentials):
+		return status.Error(codes.InvalidArgument, "invalid credentials")
+	case errors.Is(err, templates.ErrTemplateNotFound):
+		return status.Error(codes.NotFound, "task template not found")
+	case errors.Is(err, templates.ErrMissingVariable), errors.Is(err, templates.ErrInvalidTemplate):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, tasks.ErrInvalidRequester):
+		return status.Error(codes.InvalidArgument, "invalid requester")
+	case errors.Is(err, tasks.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, tasks.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, "invalid task query")
+	case errors.Is(err, tasks.ErrInvalidBulk):
+		return status.Error(codes.InvalidArgument, "invalid bulk request")
+	case errors.Is(err, tasks.ErrVersionMismatch):
+		return status.Error(codes.FailedPrecondition, "task version mismatch")
+	case errors.Is(err, tasks.ErrVersionConflict):
+		return status.Error(codes.Aborted, "task was modified concurrently")
+	case errors.Is(err, tasks.ErrOpenSubtasks), errors.Is(err, tasks.ErrTaskBlocked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, tasks.ErrTransitionNotAllowed), errors.Is(err, tasks.ErrReasonRequired), errors.Is(err, tasks.ErrAssigneeRequired):
+		return status.Error(codes.FailedPrecondition, "task status transition not allowed")
+	case errors.Is(err, labels.ErrInvalidName):
+		return status.Error(codes.InvalidArgument, "invalid label name")
+	case errors.Is(err, tasks.ErrInvalidLink):
+		return status.Error(codes.InvalidArgument, "invalid task link")
+	case errors.Is(err, tasks.ErrLinkExists):
+		return status.Error(codes.AlreadyExists, "task link already exists")
+	case errors.Is(err, tasks.ErrLinkCycle):
+		return status.Error(codes.FailedPrecondition, "task link creates a cycle")
+	case errors.Is(err, tasks.ErrMergeNotAllowed):
+		return status.Error(codes.FailedPrecondition, "task merge not allowed")
+	case errors.Is(err, tasks.ErrInvalidAssignee):
+		return status.Error(codes.FailedPrecondition, "user can not be assigned tasks")
+	case errors.Is(err, assignment.ErrNoCandidates):
+		return status.Error(codes.Unavailable, "no agents available")
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestMapTaskError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "not found", err: tasks.ErrInvalidCredentials, want: codes.InvalidArgument},
+		{name: "version mismatch", err: tasks.ErrVersionMismatch, want: codes.FailedPrecondition},
+		{name: "version conflict", err: tasks.ErrVersionConflict, want: codes.Aborted},
+		{name: "open subtasks", err: fmt.Errorf("%w: [2 3]", tasks.ErrOpenSubtasks), want: codes.FailedPrecondition},
+		{name: "blocked", err: tasks.ErrTaskBlocked, want: codes.FailedPrecondition},
+		{name: "wrapped transition", err: fmt.Errorf("op: closed -> in_progress: %w", tasks.ErrTransitionNotAllowed), want: codes.FailedPrecondition},
+		{name: "reason required", err: tasks.ErrReasonRequired, want: codes.FailedPrecondition},
+		{name: "assignee required", err: tasks.ErrAssigneeRequired, want: codes.FailedPrecondition},
+		{name: "invalid assignee", err: tasks.ErrInvalidAssignee, want: codes.FailedPrecondition},
+		{name: "no candidates", err: fmt.Errorf("choose: %w", assignment.ErrNoCandidates), want: codes.Unavailable},
+		{name: "unknown", err: errors.New("connection refused"), want: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status.Code(mapTaskError(tt.err)))
+		})
+	}
+}
+
+func TestMapTaskErrorHidesInternalDetails(t *testing.T) {
+	err := mapTaskError(errors.New("pq: password authentication failed"))
+
+	assert.Equal(t, "internal error", status.Convert(err).Message())
+}
@@ -0,0 +1,639 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/structrpc"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/export"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"time"
+)
+
+// Методы задач, которых нет в пакете protos, регистрируются вручную отдельным сервисом:
+// запросы и ответы передаются как google.protobuf.Struct, задачи - в их JSON-представлении
+const (
+	workflowServiceName = "tasks.TaskWorkflowService"
+
+	TaskWorkflowService_TransitionTask_FullMethodName         = "/" + workflowServiceName + "/TransitionTask"
+	TaskWorkflowService_GetTaskHistory_FullMethodName         = "/" + workflowServiceName + "/GetTaskHistory"
+	TaskWorkflowService_ExplainAssignment_FullMethodName      = "/" + workflowServiceName + "/ExplainAssignment"
+	TaskWorkflowService_AddLabelsToTask_FullMethodName        = "/" + workflowServiceName + "/AddLabelsToTask"
+	TaskWorkflowService_RemoveLabelsFromTask_FullMethodName   = "/" + workflowServiceName + "/RemoveLabelsFromTask"
+	TaskWorkflowService_CreateTaskFromTemplate_FullMethodName = "/" + workflowServiceName + "/CreateTaskFromTemplate"
+	TaskWorkflowService_RequesterHistory_FullMethodName       = "/" + workflowServiceName + "/RequesterHistory"
+	TaskWorkflowService_RequesterOpenTasks_FullMethodName     = "/" + workflowServiceName + "/RequesterOpenTasks"
+	TaskWorkflowService_RestoreTask_FullMethodName            = "/" + workflowServiceName + "/RestoreTask"
+	TaskWorkflowService_SearchTasks_FullMethodName            = "/" + workflowServiceName + "/SearchTasks"
+	TaskWorkflowService_LinkTasks_FullMethodName              = "/" + workflowServiceName + "/LinkTasks"
+	TaskWorkflowService_UnlinkTasks_FullMethodName            = "/" + workflowServiceName + "/UnlinkTasks"
+	TaskWorkflowService_MergeTasks_FullMethodName             = "/" + workflowServiceName + "/MergeTasks"
+	TaskWorkflowService_UnassignTask_FullMethodName           = "/" + workflowServiceName + "/UnassignTask"
+	TaskWorkflowService_ReassignTask_FullMethodName           = "/" + workflowServiceName + "/ReassignTask"
+	TaskWorkflowService_ListQueue_FullMethodName              = "/" + workflowServiceName + "/ListQueue"
+	TaskWorkflowService_BulkCloseTasks_FullMethodName         = "/" + workflowServiceName + "/BulkCloseTasks"
+	TaskWorkflowService_BulkAssignTasks_FullMethodName        = "/" + workflowServiceName + "/BulkAssignTasks"
+	TaskWorkflowService_BulkFireTasks_FullMethodName          = "/" + workflowServiceName + "/BulkFireTasks"
+	TaskWorkflowService_WatchTasks_FullMethodName             = "/" + workflowServiceName + "/WatchTasks"
+)
+
+type TaskWorkflowServer interface {
+	// TransitionTask переводит задачу в статус по таблице переходов; поля: task_id, status (open, in_progress,
+	// closed, on_hold, waiting_for_customer, cancelled, reopened), reason
+	TransitionTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// GetTaskHistory возвращает журнал изменений задачи, в том числе заархивированной; поля: task_id.
+	// Ответ: events
+	GetTaskHistory(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// WatchTasks передаёт поток событий задач; поля: cluster_id, assignee_id, resume_token последнего
+	// полученного события. Событие: type, resume_token, task, previous_user_id, at
+	WatchTasks(req *structpb.Struct, stream grpc.ServerStream) error
+	// ExplainAssignment показывает, кому была бы назначена задача и почему, ничего не изменяя; поля: task_id.
+	// Ответ: user, strategy, reason, scores
+	ExplainAssignment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// BulkCloseTasks закрывает задачи с общим кейсом и/или решением; поля: task_ids, case_id, solution, reason,
+	// mode (atomic, best_effort). Ответ: results с task_id, code, task и error по каждой задаче
+	BulkCloseTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// BulkAssignTasks назначает задачи агенту и берёт их в работу; поля: task_ids, user_id, mode. Ответ: results
+	BulkAssignTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// BulkFireTasks поджигает или гасит задачи; поля: task_ids, fire, reason, mode. Ответ: results
+	BulkFireTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// ListQueue возвращает открытые задачи по убыванию веса в очереди; поля: cluster_id, limit. Ответ: tasks,
+	// у каждой задачи вес в поле score
+	ListQueue(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// UnassignTask снимает исполнителя и возвращает задачу в очередь; поля: task_id, note
+	UnassignTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// ReassignTask передаёт задачу другому агенту; поля: task_id, user_id, note
+	ReassignTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// MergeTasks присоединяет дубликаты к родительской задаче; поля: parent_id, child_ids, reason.
+	// Ответ: родительская задача
+	MergeTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// LinkTasks связывает задачи; поля: from_task_id, to_task_id, kind (subtask, blocks, related). Ответ: связь
+	LinkTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// UnlinkTasks удаляет связь между задачами; поля: link_id. Ответ пустой
+	UnlinkTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// AddLabelsToTask добавляет задаче метки, создавая отсутствующие в каталоге; поля: task_id, labels
+	AddLabelsToTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// RemoveLabelsFromTask снимает с задачи метки; поля: task_id, labels
+	RemoveLabelsFromTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// SearchTasks ищет задачи по тексту; поля: text, statuses, cluster_id, limit, offset.
+	// Ответ: hits с task, rank и snippet
+	SearchTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// RestoreTask возвращает задачу из архива вместе с комментариями и вложениями; доступно только
+	// администратору. Поля: task_id
+	RestoreTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// RequesterHistory возвращает все задачи клиента, начиная с новых; поля: requester_id, cursor, limit.
+	// Ответ: tasks, next_cursor
+	RequesterHistory(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// RequesterOpenTasks возвращает незавершённые задачи клиента, начиная с новых; поля: requester_id, cursor,
+	// limit. Ответ: tasks, next_cursor
+	RequesterOpenTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// CreateTaskFromTemplate создает задачу по шаблону; поля: template_id, vars (объект строк),
+	// requester_external_id, requester_channel, requester_display_name - клиент, к которому привязывается задача
+	CreateTaskFromTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var workflowServiceDesc = grpc.ServiceDesc{
+	ServiceName: workflowServiceName,
+	HandlerType: (*TaskWorkflowServer)(nil),
+	Methods: []grpc.MethodDesc{
+		structrpc.Unary(workflowServiceName, "TransitionTask", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).TransitionTask(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "GetTaskHistory", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).GetTaskHistory(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "ExplainAssignment", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).ExplainAssignment(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "BulkCloseTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).BulkCloseTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "BulkAssignTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).BulkAssignTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "BulkFireTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).BulkFireTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "ListQueue", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).ListQueue(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "UnassignTask", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).UnassignTask(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "ReassignTask", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).ReassignTask(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "MergeTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).MergeTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "LinkTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).LinkTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "UnlinkTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).UnlinkTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "AddLabelsToTask", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).AddLabelsToTask(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "RemoveLabelsFromTask", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).RemoveLabelsFromTask(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "SearchTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).SearchTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "RestoreTask", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).RestoreTask(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "RequesterHistory", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).RequesterHistory(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "RequesterOpenTasks", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).RequesterOpenTasks(ctx, req)
+		}),
+		structrpc.Unary(workflowServiceName, "CreateTaskFromTemplate", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TaskWorkflowServer).CreateTaskFromTemplate(ctx, req)
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTasks",
+			Handler:       watchTasksHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func watchTasksHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(TaskWorkflowServer).WatchTasks(req, stream)
+}
+
+func (s *serverAPI) TransitionTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	statusName := r.String("status")
+	reason := r.String("reason")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	target, err := export.ParseStatus(statusName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	task, err := s.taskService.TransitionTask(ctx, taskID, target, reason)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+func (s *serverAPI) GetTaskHistory(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	events, err := s.taskService.GetTaskHistory(ctx, taskID)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return marshalResponse(map[string]interface{}{"events": events})
+}
+
+func (s *serverAPI) ExplainAssignment(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	decision, err := s.taskService.ExplainAssignment(ctx, taskID)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return marshalResponse(decision)
+}
+
+func (s *serverAPI) BulkCloseTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskIDs := r.IDs("task_ids")
+	caseID := r.OptionalID("case_id")
+	solution := r.OptionalString("solution")
+	reason := r.String("reason")
+	modeName := r.String("mode")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	mode, err := parseBulkMode(modeName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.taskService.BulkCloseTasks(ctx, taskIDs, caseID, solution, reason, mode)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return bulkResponse(results)
+}
+
+func (s *serverAPI) BulkAssignTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskIDs := r.IDs("task_ids")
+	userID := r.ID("user_id")
+	modeName := r.String("mode")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	mode, err := parseBulkMode(modeName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.taskService.BulkAssignTasks(ctx, taskIDs, userID, mode)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return bulkResponse(results)
+}
+
+func (s *serverAPI) BulkFireTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskIDs := r.IDs("task_ids")
+	fire := r.Bool("fire")
+	reason := r.OptionalString("reason")
+	modeName := r.String("mode")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	mode, err := parseBulkMode(modeName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.taskService.BulkFireTasks(ctx, taskIDs, fire, reason, mode)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return bulkResponse(results)
+}
+
+func (s *serverAPI) ListQueue(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	clusterID := r.OptionalID("cluster_id")
+	limit := r.Int("limit")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	queue, err := s.taskService.ListQueue(ctx, clusterID, limit)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return marshalResponse(map[string]interface{}{"tasks": queue})
+}
+
+func (s *serverAPI) UnassignTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	note := r.String("note")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	task, err := s.taskService.UnassignTask(ctx, taskID, note)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+func (s *serverAPI) ReassignTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	userID := r.ID("user_id")
+	note := r.String("note")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	task, err := s.taskService.ReassignTask(ctx, taskID, userID, note)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+func (s *serverAPI) MergeTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	parentID := r.ID("parent_id")
+	childIDs := r.IDs("child_ids")
+	reason := r.String("reason")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	parent, err := s.taskService.MergeTasks(ctx, parentID, childIDs, reason)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, parent)
+}
+
+func (s *serverAPI) LinkTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	fromID := r.ID("from_task_id")
+	toID := r.ID("to_task_id")
+	kind := models.TaskLinkKind(r.String("kind"))
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	link, err := s.taskService.LinkTasks(ctx, fromID, toID, kind)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return marshalResponse(link)
+}
+
+func (s *serverAPI) UnlinkTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	linkID := r.ID("link_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.taskService.UnlinkTasks(ctx, linkID); err != nil {
+		return nil, mapTaskError(err)
+	}
+	return &structpb.Struct{}, nil
+}
+
+func (s *serverAPI) AddLabelsToTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	names := r.Strings("labels")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	task, err := s.taskService.AddLabelsToTask(ctx, taskID, names)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+func (s *serverAPI) RemoveLabelsFromTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	names := r.Strings("labels")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	task, err := s.taskService.RemoveLabelsFromTask(ctx, taskID, names)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+func (s *serverAPI) SearchTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	query := models.TaskSearchQuery{
+		Text:      r.String("text"),
+		ClusterID: r.OptionalID("cluster_id"),
+		Limit:     r.Int("limit"),
+		Offset:    r.Int("offset"),
+	}
+	statusNames := r.Strings("statuses")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for _, name := range statusNames {
+		taskStatus, err := export.ParseStatus(name)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		query.Statuses = append(query.Statuses, taskStatus)
+	}
+
+	hits, err := s.taskService.SearchTasks(ctx, query)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+
+	out := make([]searchHit, 0, len(hits))
+	for _, hit := range hits {
+		out = append(out, searchHit{Task: hit.Task, Rank: hit.Rank, Snippet: hit.Snippet})
+	}
+	return marshalResponse(map[string]interface{}{"hits": out})
+}
+
+// searchHit найденная задача в ответе SearchTasks
+type searchHit struct {
+	Task    models.Task `json:"task"`
+	Rank    float64     `json:"rank"`
+	Snippet string      `json:"snippet"`
+}
+
+func (s *serverAPI) RestoreTask(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	taskID := r.ID("task_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	task, err := s.taskService.RestoreTask(ctx, taskID)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+func (s *serverAPI) RequesterHistory(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	requesterID := r.ID("requester_id")
+	cursor := r.String("cursor")
+	limit := r.Int("limit")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := s.taskService.RequesterHistory(ctx, requesterID, cursor, limit)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskPageResponse(page)
+}
+
+func (s *serverAPI) RequesterOpenTasks(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	requesterID := r.ID("requester_id")
+	cursor := r.String("cursor")
+	limit := r.Int("limit")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := s.taskService.RequesterOpenTasks(ctx, requesterID, cursor, limit)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskPageResponse(page)
+}
+
+func taskPageResponse(page models.TaskPage) (*structpb.Struct, error) {
+	return marshalResponse(map[string]interface{}{
+		"tasks":       page.Tasks,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func (s *serverAPI) CreateTaskFromTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	templateID := r.ID("template_id")
+	vars := r.StringMap("vars")
+	externalID := r.OptionalString("requester_external_id")
+	channel := r.String("requester_channel")
+	displayName := r.String("requester_display_name")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var requester *models.Requester
+	if externalID != nil {
+		requester = &models.Requester{ExternalID: *externalID, Channel: channel, DisplayName: displayName}
+	}
+
+	task, err := s.taskService.CreateTaskFromTemplate(ctx, templateID, vars, requester)
+	if err != nil {
+		return nil, mapTaskError(err)
+	}
+	return taskResponse(ctx, task)
+}
+
+// bulkResult результат массовой операции по одной задаче в ответе
+type bulkResult struct {
+	TaskID int64          `json:"task_id"`
+	Code   tasks.BulkCode `json:"code"`
+	Task   *models.Task   `json:"task,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+func bulkResponse(results []tasks.BulkResult) (*structpb.Struct, error) {
+	out := make([]bulkResult, 0, len(results))
+	for _, result := range results {
+		item := bulkResult{TaskID: result.TaskID, Code: result.Code}
+		if result.Err != nil {
+			// клиент получает то же сообщение, что и при ошибке одиночного метода
+			item.Error = status.Convert(mapTaskError(result.Err)).Message()
+		} else {
+			task := result.Task
+			item.Task = &task
+		}
+		out = append(out, item)
+	}
+	return marshalResponse(map[string]interface{}{"results": out})
+}
+
+func parseBulkMode(name string) (tasks.BulkMode, error) {
+	switch name {
+	case "", "atomic":
+		return tasks.BulkModeAtomic, nil
+	case "best_effort":
+		return tasks.BulkModeBestEffort, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown bulk mode %q", structrpc.ErrInvalidRequest, name)
+	}
+}
+
+// watchEvent событие ленты задач в ответе WatchTasks
+type watchEvent struct {
+	Type           taskfeed.EventType `json:"type"`
+	ResumeToken    string             `json:"resume_token"`
+	Task           models.Task        `json:"task"`
+	PreviousUserID *int64             `json:"previous_user_id"`
+	At             time.Time          `json:"at"`
+}
+
+func (s *serverAPI) WatchTasks(req *structpb.Struct, stream grpc.ServerStream) error {
+	r := structrpc.NewReader(req)
+	filter := taskfeed.Filter{
+		ClusterID: r.OptionalID("cluster_id"),
+		UserID:    r.OptionalID("assignee_id"),
+	}
+	resumeToken := r.String("resume_token")
+	if err := r.Err(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx := stream.Context()
+	events, err := s.taskService.WatchTasks(ctx, filter, resumeToken)
+	if err != nil {
+		return mapWatchError(err)
+	}
+
+	for event := range events {
+		resp, err := marshalResponse(watchEvent{
+			Type:           event.Type,
+			ResumeToken:    event.ResumeToken(),
+			Task:           event.Task,
+			PreviousUserID: event.PreviousUserID,
+			At:             event.At,
+		})
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	// лента закрывает подписку, которая не успевает читать события
+	return status.Error(codes.ResourceExhausted, "subscriber is too slow, resume with the last token")
+}
+
+func mapWatchError(err error) error {
+	switch {
+	case errors.Is(err, taskfeed.ErrInvalidResumeToken):
+		return status.Error(codes.InvalidArgument, "invalid resume token")
+	case errors.Is(err, taskfeed.ErrSequenceExpired):
+		return status.Error(codes.OutOfRange, "resume token expired, reload tasks")
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// taskResponse возвращает задачу вместе с заголовком её версии
+func taskResponse(ctx context.Context, task models.Task) (*structpb.Struct, error) {
+	setTaskVersionHeader(ctx, task)
+	return marshalResponse(task)
+}
+
+func marshalResponse(v interface{}) (*structpb.Struct, error) {
+	resp, err := structrpc.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
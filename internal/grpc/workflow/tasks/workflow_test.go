@@ -0,0 +1,625 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/templates"
+	tasksv1 "github.com/markgregr/bestHack_support_protos/gen/go/workflow/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+// fakeTaskService запоминает аргументы последнего вызова и возвращает задачу task
+type fakeTaskService struct {
+	TaskService
+
+	task   models.Task
+	err    error
+	taskID int64
+	target models.TaskStatus
+	reason string
+
+	events      chan taskfeed.Event
+	filter      taskfeed.Filter
+	resumeToken string
+
+	decision assignment.Decision
+
+	results  []tasks.BulkResult
+	taskIDs  []int64
+	caseID   *int64
+	solution *string
+	mode     tasks.BulkMode
+
+	queue     []models.Task
+	clusterID *int64
+	limit     int
+
+	userID int64
+
+	link models.TaskLink
+	kind models.TaskLinkKind
+
+	status models.TaskStatus
+	labels []string
+
+	hits        []models.TaskSearchHit
+	searchQuery models.TaskSearchQuery
+
+	page   models.TaskPage
+	cursor string
+
+	vars      map[string]string
+	requester *models.Requester
+}
+
+func (f *fakeTaskService) TransitionTask(_ context.Context, taskID int64, target models.TaskStatus, reason string) (models.Task, error) {
+	f.taskID, f.target, f.reason = taskID, target, reason
+	return f.task, f.err
+}
+
+func (f *fakeTaskService) WatchTasks(_ context.Context, filter taskfeed.Filter, resumeToken string) (<-chan taskfeed.Event, error) {
+	f.filter, f.resumeToken = filter, resumeToken
+	return f.events, f.err
+}
+
+func (f *fakeTaskService) ExplainAssignment(_ context.Context, taskID int64) (assignment.Decision, error) {
+	f.taskID = taskID
+	return f.decision, f.err
+}
+
+func (f *fakeTaskService) BulkCloseTasks(_ context.Context, taskIDs []int64, caseID *int64, solution *string, reason string, mode tasks.BulkMode) ([]tasks.BulkResult, error) {
+	f.taskIDs, f.caseID, f.solution, f.reason, f.mode = taskIDs, caseID, solution, reason, mode
+	return f.results, f.err
+}
+
+func (f *fakeTaskService) ListQueue(_ context.Context, clusterID *int64, limit int) ([]models.Task, error) {
+	f.clusterID, f.limit = clusterID, limit
+	return f.queue, f.err
+}
+
+func (f *fakeTaskService) ReassignTask(_ context.Context, taskID, userID int64, note string) (models.Task, error) {
+	f.taskID, f.userID, f.reason = taskID, userID, note
+	return f.task, f.err
+}
+
+func (f *fakeTaskService) MergeTasks(_ context.Context, parentID int64, childIDs []int64, reason string) (models.Task, error) {
+	f.taskID, f.taskIDs, f.reason = parentID, childIDs, reason
+	return f.task, f.err
+}
+
+func (f *fakeTaskService) LinkTasks(_ context.Context, fromID, toID int64, kind models.TaskLinkKind) (models.TaskLink, error) {
+	f.taskIDs, f.kind = []int64{fromID, toID}, kind
+	return f.link, f.err
+}
+
+func (f *fakeTaskService) ListTasks(_ context.Context, status models.TaskStatus, labels ...string) ([]models.Task, error) {
+	f.status, f.labels = status, labels
+	return nil, f.err
+}
+
+func (f *fakeTaskService) AddLabelsToTask(_ context.Context, taskID int64, names []string) (models.Task, error) {
+	f.taskID, f.labels = taskID, names
+	return f.task, f.err
+}
+
+func (f *fakeTaskService) SearchTasks(_ context.Context, query models.TaskSearchQuery) ([]models.TaskSearchHit, error) {
+	f.searchQuery = query
+	return f.hits, f.err
+}
+
+func (f *fakeTaskService) RestoreTask(_ context.Context, taskID int64) (models.Task, error) {
+	f.taskID = taskID
+	return f.task, f.err
+}
+
+func (f *fakeTaskService) RequesterOpenTasks(_ context.Context, requesterID int64, cursor string, limit int) (models.TaskPage, error) {
+	f.taskID, f.cursor, f.limit = requesterID, cursor, limit
+	return f.page, f.err
+}
+
+func (f *fakeTaskService) CreateTaskFromTemplate(_ context.Context, templateID int64, vars map[string]string, requester *models.Requester) (models.Task, error) {
+	f.taskID, f.vars, f.requester = templateID, vars, requester
+	return f.task, f.err
+}
+
+// fakeStream собирает отправленные сообщения серверного потока
+type fakeStream struct {
+	grpc.ServerStream
+
+	ctx  context.Context
+	sent []*structpb.Struct
+}
+
+func (f *fakeStream) Context() context.Context { return f.ctx }
+
+func (f *fakeStream) SendMsg(m interface{}) error {
+	f.sent = append(f.sent, m.(*structpb.Struct))
+	return nil
+}
+
+func newRequest(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestTransitionTask(t *testing.T) {
+	service := &fakeTaskService{task: models.Task{ID: 5, Status: models.TaskStatusOnHold, Version: 3}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.TransitionTask(context.Background(), newRequest(t, map[string]interface{}{
+		"task_id": float64(5),
+		"status":  "on_hold",
+		"reason":  "waiting for the bank",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), service.taskID)
+	assert.Equal(t, models.TaskStatusOnHold, service.target)
+	assert.Equal(t, "waiting for the bank", service.reason)
+	assert.Equal(t, float64(5), resp.GetFields()["id"].GetNumberValue())
+	assert.Equal(t, float64(3), resp.GetFields()["version"].GetNumberValue())
+}
+
+func TestTransitionTaskRejectsInvalidRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+	}{
+		{name: "missing task", fields: map[string]interface{}{"status": "closed"}},
+		{name: "unknown status", fields: map[string]interface{}{"task_id": float64(5), "status": "done"}},
+		{name: "unknown field", fields: map[string]interface{}{"task_id": float64(5), "status": "closed", "comment": "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{taskService: &fakeTaskService{}}
+
+			_, err := api.TransitionTask(context.Background(), newRequest(t, tt.fields))
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestWatchTasks(t *testing.T) {
+	clusterID := int64(2)
+	events := make(chan taskfeed.Event, 1)
+	events <- taskfeed.Event{Epoch: "e1", Seq: 7, Type: taskfeed.EventUpdated, Task: models.Task{ID: 5}}
+	close(events)
+
+	service := &fakeTaskService{events: events}
+	api := &serverAPI{taskService: service}
+	stream := &fakeStream{ctx: context.Background()}
+
+	err := api.WatchTasks(newRequest(t, map[string]interface{}{
+		"cluster_id":   float64(2),
+		"resume_token": "e1-6",
+	}), stream)
+
+	// закрытая лента при живом контексте означает, что подписчик отстал
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, taskfeed.Filter{ClusterID: &clusterID}, service.filter)
+	assert.Equal(t, "e1-6", service.resumeToken)
+	require.Len(t, stream.sent, 1)
+	assert.Equal(t, float64(5), stream.sent[0].GetFields()["task"].GetStructValue().GetFields()["id"].GetNumberValue())
+	assert.Equal(t, "e1-7", stream.sent[0].GetFields()["resume_token"].GetStringValue())
+}
+
+func TestWatchTasksErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "invalid token", err: taskfeed.ErrInvalidResumeToken, wantCode: codes.InvalidArgument},
+		{name: "expired token", err: taskfeed.ErrSequenceExpired, wantCode: codes.OutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{taskService: &fakeTaskService{err: tt.err}}
+
+			err := api.WatchTasks(newRequest(t, map[string]interface{}{"resume_token": "x"}), &fakeStream{ctx: context.Background()})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestExplainAssignment(t *testing.T) {
+	service := &fakeTaskService{decision: assignment.Decision{
+		User:     models.User{ID: 3, Email: "agent@bank.ru", PassHash: []byte("secret")},
+		Strategy: assignment.StrategyLeastLoad,
+		Reason:   "lowest workload",
+		Scores:   []assignment.Score{{UserID: 3, Email: "agent@bank.ru", Score: 1.5}},
+	}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.ExplainAssignment(context.Background(), newRequest(t, map[string]interface{}{"task_id": float64(5)}))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), service.taskID)
+	user := resp.GetFields()["user"].GetStructValue().GetFields()
+	assert.Equal(t, float64(3), user["id"].GetNumberValue())
+	assert.NotContains(t, user, "pass_hash")
+	assert.Equal(t, assignment.StrategyLeastLoad, resp.GetFields()["strategy"].GetStringValue())
+	assert.Len(t, resp.GetFields()["scores"].GetListValue().GetValues(), 1)
+}
+
+func TestExplainAssignmentWithoutCandidates(t *testing.T) {
+	api := &serverAPI{taskService: &fakeTaskService{err: assignment.ErrNoCandidates}}
+
+	_, err := api.ExplainAssignment(context.Background(), newRequest(t, map[string]interface{}{"task_id": float64(5)}))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestBulkCloseTasks(t *testing.T) {
+	service := &fakeTaskService{results: []tasks.BulkResult{
+		{TaskID: 1, Code: tasks.BulkCodeOK, Task: models.Task{ID: 1, Status: models.TaskStatusClosed}},
+		{TaskID: 2, Code: tasks.BulkCodeFailedPrecondition, Err: fmt.Errorf("op: %w", tasks.ErrTransitionNotAllowed)},
+		{TaskID: 3, Code: tasks.BulkCodeInternal, Err: errors.New("connection refused")},
+	}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.BulkCloseTasks(context.Background(), newRequest(t, map[string]interface{}{
+		"task_ids": []interface{}{float64(1), float64(2), float64(3)},
+		"solution": "reissued the card",
+		"reason":   "mass incident",
+		"mode":     "best_effort",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{1, 2, 3}, service.taskIDs)
+	assert.Nil(t, service.caseID)
+	require.NotNil(t, service.solution)
+	assert.Equal(t, "reissued the card", *service.solution)
+	assert.Equal(t, tasks.BulkModeBestEffort, service.mode)
+
+	results := resp.GetFields()["results"].GetListValue().GetValues()
+	require.Len(t, results, 3)
+
+	ok := results[0].GetStructValue().GetFields()
+	assert.Equal(t, "ok", ok["code"].GetStringValue())
+	assert.Equal(t, float64(1), ok["task"].GetStructValue().GetFields()["id"].GetNumberValue())
+	assert.NotContains(t, ok, "error")
+
+	failed := results[1].GetStructValue().GetFields()
+	assert.Equal(t, "failed_precondition", failed["code"].GetStringValue())
+	assert.Equal(t, "task status transition not allowed", failed["error"].GetStringValue())
+	assert.NotContains(t, failed, "task")
+
+	// внутренние ошибки не раскрываются клиенту
+	assert.Equal(t, "internal error", results[2].GetStructValue().GetFields()["error"].GetStringValue())
+}
+
+func TestBulkCloseTasksRejectsInvalidRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		err    error
+	}{
+		{name: "unknown mode", fields: map[string]interface{}{"task_ids": []interface{}{float64(1)}, "mode": "partial"}},
+		{name: "bad id", fields: map[string]interface{}{"task_ids": []interface{}{"1"}}},
+		{name: "too many tasks", fields: map[string]interface{}{"task_ids": []interface{}{float64(1)}}, err: tasks.ErrInvalidBulk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{taskService: &fakeTaskService{err: tt.err}}
+
+			_, err := api.BulkCloseTasks(context.Background(), newRequest(t, tt.fields))
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestListQueue(t *testing.T) {
+	service := &fakeTaskService{queue: []models.Task{{ID: 7, Score: 12.5}, {ID: 3, Score: 4}}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.ListQueue(context.Background(), newRequest(t, map[string]interface{}{
+		"cluster_id": float64(2),
+		"limit":      float64(10),
+	}))
+	require.NoError(t, err)
+
+	require.NotNil(t, service.clusterID)
+	assert.Equal(t, int64(2), *service.clusterID)
+	assert.Equal(t, 10, service.limit)
+
+	queue := resp.GetFields()["tasks"].GetListValue().GetValues()
+	require.Len(t, queue, 2)
+	assert.Equal(t, float64(7), queue[0].GetStructValue().GetFields()["id"].GetNumberValue())
+	assert.Equal(t, 12.5, queue[0].GetStructValue().GetFields()["score"].GetNumberValue())
+}
+
+func TestReassignTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "reassigned", wantCode: codes.OK},
+		{name: "assignee is not an agent", err: tasks.ErrInvalidAssignee, wantCode: codes.FailedPrecondition},
+		{name: "concurrent update", err: tasks.ErrVersionConflict, wantCode: codes.Aborted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeTaskService{task: models.Task{ID: 5, Version: 4}, err: tt.err}
+			api := &serverAPI{taskService: service}
+
+			resp, err := api.ReassignTask(context.Background(), newRequest(t, map[string]interface{}{
+				"task_id": float64(5),
+				"user_id": float64(9),
+				"note":    "vacation",
+			}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, int64(9), service.userID)
+			assert.Equal(t, "vacation", service.reason)
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, float64(4), resp.GetFields()["version"].GetNumberValue())
+			}
+		})
+	}
+}
+
+func TestMergeTasks(t *testing.T) {
+	service := &fakeTaskService{task: models.Task{ID: 1, Version: 2}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.MergeTasks(context.Background(), newRequest(t, map[string]interface{}{
+		"parent_id": float64(1),
+		"child_ids": []interface{}{float64(2), float64(3)},
+		"reason":    "same outage",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), service.taskID)
+	assert.Equal(t, []int64{2, 3}, service.taskIDs)
+	assert.Equal(t, "same outage", service.reason)
+	assert.Equal(t, float64(2), resp.GetFields()["version"].GetNumberValue())
+}
+
+func TestMergeTasksNotAllowed(t *testing.T) {
+	api := &serverAPI{taskService: &fakeTaskService{err: fmt.Errorf("op: %w: other cluster", tasks.ErrMergeNotAllowed)}}
+
+	_, err := api.MergeTasks(context.Background(), newRequest(t, map[string]interface{}{
+		"parent_id": float64(1),
+		"child_ids": []interface{}{float64(2)},
+	}))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestLinkTasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "linked", wantCode: codes.OK},
+		{name: "unknown kind", err: fmt.Errorf("op: %w", tasks.ErrInvalidLink), wantCode: codes.InvalidArgument},
+		{name: "duplicate", err: tasks.ErrLinkExists, wantCode: codes.AlreadyExists},
+		{name: "cycle", err: tasks.ErrLinkCycle, wantCode: codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeTaskService{link: models.TaskLink{ID: 4, FromTaskID: 1, ToTaskID: 2, Kind: models.TaskLinkBlocks}, err: tt.err}
+			api := &serverAPI{taskService: service}
+
+			resp, err := api.LinkTasks(context.Background(), newRequest(t, map[string]interface{}{
+				"from_task_id": float64(1),
+				"to_task_id":   float64(2),
+				"kind":         "blocks",
+			}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, []int64{1, 2}, service.taskIDs)
+			assert.Equal(t, models.TaskLinkBlocks, service.kind)
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, float64(4), resp.GetFields()["id"].GetNumberValue())
+			}
+		})
+	}
+}
+
+func TestListTasksPassesLabelsFromMetadata(t *testing.T) {
+	service := &fakeTaskService{}
+	api := &serverAPI{taskService: service}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("labels", "vip, fraud", "labels", "card"))
+	_, err := api.ListTasks(ctx, &tasksv1.ListTasksRequest{Status: int64(models.TaskStatusInProgress)})
+	require.NoError(t, err)
+
+	assert.Equal(t, models.TaskStatusInProgress, service.status)
+	assert.Equal(t, []string{"vip", "fraud", "card"}, service.labels)
+}
+
+func TestAddLabelsToTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "added", wantCode: codes.OK},
+		{name: "invalid name", err: fmt.Errorf("%w: %q", labels.ErrInvalidName, "a b"), wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeTaskService{task: models.Task{ID: 5, Labels: []models.Label{{Name: "vip"}}}, err: tt.err}
+			api := &serverAPI{taskService: service}
+
+			resp, err := api.AddLabelsToTask(context.Background(), newRequest(t, map[string]interface{}{
+				"task_id": float64(5),
+				"labels":  []interface{}{"vip"},
+			}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, []string{"vip"}, service.labels)
+			if tt.wantCode == codes.OK {
+				assert.Len(t, resp.GetFields()["labels"].GetListValue().GetValues(), 1)
+			}
+		})
+	}
+}
+
+func TestSearchTasks(t *testing.T) {
+	service := &fakeTaskService{hits: []models.TaskSearchHit{{Task: models.Task{ID: 4}, Rank: 0.5, Snippet: "<b>карта</b> заблокирована"}}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.SearchTasks(context.Background(), newRequest(t, map[string]interface{}{
+		"text":     "карта",
+		"statuses": []interface{}{"open", "reopened"},
+		"limit":    float64(5),
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, models.TaskSearchQuery{
+		Text:     "карта",
+		Statuses: []models.TaskStatus{models.TaskStatusOpen, models.TaskStatusReopened},
+		Limit:    5,
+	}, service.searchQuery)
+
+	hits := resp.GetFields()["hits"].GetListValue().GetValues()
+	require.Len(t, hits, 1)
+	hit := hits[0].GetStructValue().GetFields()
+	assert.Equal(t, float64(4), hit["task"].GetStructValue().GetFields()["id"].GetNumberValue())
+	assert.Equal(t, "<b>карта</b> заблокирована", hit["snippet"].GetStringValue())
+}
+
+func TestSearchTasksRejectsInvalidQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		err    error
+	}{
+		{name: "unknown status", fields: map[string]interface{}{"text": "карта", "statuses": []interface{}{"done"}}},
+		{name: "empty text", fields: map[string]interface{}{}, err: fmt.Errorf("op: %w", tasks.ErrInvalidQuery)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{taskService: &fakeTaskService{err: tt.err}}
+
+			_, err := api.SearchTasks(context.Background(), newRequest(t, tt.fields))
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestRestoreTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "restored", wantCode: codes.OK},
+		{name: "not admin", err: tasks.ErrPermissionDenied, wantCode: codes.PermissionDenied},
+		{name: "not archived", err: tasks.ErrInvalidCredentials, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeTaskService{task: models.Task{ID: 5, Version: 6}, err: tt.err}
+			api := &serverAPI{taskService: service}
+
+			resp, err := api.RestoreTask(context.Background(), newRequest(t, map[string]interface{}{"task_id": float64(5)}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, int64(5), service.taskID)
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, float64(6), resp.GetFields()["version"].GetNumberValue())
+			}
+		})
+	}
+}
+
+func TestRequesterOpenTasks(t *testing.T) {
+	service := &fakeTaskService{page: models.TaskPage{Tasks: []models.Task{{ID: 9}, {ID: 8}}, NextCursor: "next"}}
+	api := &serverAPI{taskService: service}
+
+	resp, err := api.RequesterOpenTasks(context.Background(), newRequest(t, map[string]interface{}{
+		"requester_id": float64(3),
+		"cursor":       "prev",
+		"limit":        float64(2),
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), service.taskID)
+	assert.Equal(t, "prev", service.cursor)
+	assert.Equal(t, 2, service.limit)
+	assert.Len(t, resp.GetFields()["tasks"].GetListValue().GetValues(), 2)
+	assert.Equal(t, "next", resp.GetFields()["next_cursor"].GetStringValue())
+}
+
+func TestCreateTaskFromTemplate(t *testing.T) {
+	tests := []struct {
+		name          string
+		fields        map[string]interface{}
+		wantRequester *models.Requester
+	}{
+		{
+			name:   "without requester",
+			fields: map[string]interface{}{"template_id": float64(4), "vars": map[string]interface{}{"card": "*1234"}},
+		},
+		{
+			name: "with requester",
+			fields: map[string]interface{}{
+				"template_id":            float64(4),
+				"vars":                   map[string]interface{}{"card": "*1234"},
+				"requester_external_id":  "tg:42",
+				"requester_channel":      "telegram",
+				"requester_display_name": "Иван",
+			},
+			wantRequester: &models.Requester{ExternalID: "tg:42", Channel: "telegram", DisplayName: "Иван"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeTaskService{task: models.Task{ID: 10, Version: 1}}
+			api := &serverAPI{taskService: service}
+
+			resp, err := api.CreateTaskFromTemplate(context.Background(), newRequest(t, tt.fields))
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(4), service.taskID)
+			assert.Equal(t, map[string]string{"card": "*1234"}, service.vars)
+			assert.Equal(t, tt.wantRequester, service.requester)
+			assert.Equal(t, float64(10), resp.GetFields()["id"].GetNumberValue())
+		})
+	}
+}
+
+func TestCreateTaskFromTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "missing template", err: templates.ErrTemplateNotFound, wantCode: codes.NotFound},
+		{name: "missing variable", err: fmt.Errorf("%w: card", templates.ErrMissingVariable), wantCode: codes.InvalidArgument},
+		{name: "empty requester id", err: tasks.ErrInvalidRequester, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &serverAPI{taskService: &fakeTaskService{err: tt.err}}
+
+			_, err := api.CreateTaskFromTemplate(context.Background(), newRequest(t, map[string]interface{}{"template_id": float64(4)}))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
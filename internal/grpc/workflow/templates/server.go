@@ -0,0 +1,192 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/grpc/structrpc"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/templates"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Шаблоны задач не описаны в пакете protos, поэтому сервис регистрируется вручную:
+// запросы и ответы передаются как google.protobuf.Struct, шаблоны - в их JSON-представлении
+const (
+	serviceName = "templates.TemplateService"
+
+	TemplateService_CreateTemplate_FullMethodName = "/" + serviceName + "/CreateTemplate"
+	TemplateService_UpdateTemplate_FullMethodName = "/" + serviceName + "/UpdateTemplate"
+	TemplateService_DeleteTemplate_FullMethodName = "/" + serviceName + "/DeleteTemplate"
+	TemplateService_GetTemplate_FullMethodName    = "/" + serviceName + "/GetTemplate"
+	TemplateService_ListTemplates_FullMethodName  = "/" + serviceName + "/ListTemplates"
+)
+
+// IdempotentMethods изменяющие методы, повтор которых с тем же ключом идемпотентности возвращает сохранённый ответ
+var IdempotentMethods = []string{
+	TemplateService_CreateTemplate_FullMethodName,
+	TemplateService_UpdateTemplate_FullMethodName,
+	TemplateService_DeleteTemplate_FullMethodName,
+}
+
+type TemplateService interface {
+	CreateTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error)
+	UpdateTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error)
+	DeleteTemplate(ctx context.Context, id int64) error
+	GetTemplate(ctx context.Context, id int64) (models.TaskTemplate, error)
+	ListTemplates(ctx context.Context, clusterID *int64) ([]models.TaskTemplate, error)
+}
+
+type TemplateServiceServer interface {
+	// CreateTemplate создает шаблон; поля: cluster_id, name, title_pattern, description_scaffold,
+	// default_priority (1-4, по умолчанию 2), default_case_id
+	CreateTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// UpdateTemplate заменяет все поля шаблона; поля: template_id и поля CreateTemplate
+	UpdateTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// DeleteTemplate удаляет шаблон; поля: template_id. Ответ пустой
+	DeleteTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// GetTemplate возвращает шаблон; поля: template_id
+	GetTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	// ListTemplates возвращает шаблоны, при заданном cluster_id - только шаблоны кластера. Ответ: templates
+	ListTemplates(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*TemplateServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		structrpc.Unary(serviceName, "CreateTemplate", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TemplateServiceServer).CreateTemplate(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "UpdateTemplate", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TemplateServiceServer).UpdateTemplate(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "DeleteTemplate", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TemplateServiceServer).DeleteTemplate(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "GetTemplate", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TemplateServiceServer).GetTemplate(ctx, req)
+		}),
+		structrpc.Unary(serviceName, "ListTemplates", func(srv interface{}, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+			return srv.(TemplateServiceServer).ListTemplates(ctx, req)
+		}),
+	},
+}
+
+type serverAPI struct {
+	templateService TemplateService
+}
+
+func Register(gRPC *grpc.Server, templateService TemplateService) {
+	gRPC.RegisterService(&serviceDesc, &serverAPI{templateService: templateService})
+}
+
+func (s *serverAPI) CreateTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	template := readTemplate(r)
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	template, err := s.templateService.CreateTemplate(ctx, template)
+	if err != nil {
+		return nil, mapTemplateError(err)
+	}
+	return marshalResponse(template)
+}
+
+func (s *serverAPI) UpdateTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	templateID := r.ID("template_id")
+	template := readTemplate(r)
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	template.ID = templateID
+
+	template, err := s.templateService.UpdateTemplate(ctx, template)
+	if err != nil {
+		return nil, mapTemplateError(err)
+	}
+	return marshalResponse(template)
+}
+
+func (s *serverAPI) DeleteTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	templateID := r.ID("template_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.templateService.DeleteTemplate(ctx, templateID); err != nil {
+		return nil, mapTemplateError(err)
+	}
+	return &structpb.Struct{}, nil
+}
+
+func (s *serverAPI) GetTemplate(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	templateID := r.ID("template_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	template, err := s.templateService.GetTemplate(ctx, templateID)
+	if err != nil {
+		return nil, mapTemplateError(err)
+	}
+	return marshalResponse(template)
+}
+
+func (s *serverAPI) ListTemplates(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := structrpc.NewReader(req)
+	clusterID := r.OptionalID("cluster_id")
+	if err := r.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	list, err := s.templateService.ListTemplates(ctx, clusterID)
+	if err != nil {
+		return nil, mapTemplateError(err)
+	}
+	return marshalResponse(map[string]interface{}{"templates": list})
+}
+
+// readTemplate читает поля шаблона, общие для создания и изменения
+func readTemplate(r *structrpc.Reader) models.TaskTemplate {
+	return models.TaskTemplate{
+		ClusterID:           r.ID("cluster_id"),
+		Name:                r.String("name"),
+		TitlePattern:        r.String("title_pattern"),
+		DescriptionScaffold: r.String("description_scaffold"),
+		DefaultPriority:     models.TaskPriority(r.Int("default_priority")),
+		DefaultCaseID:       r.OptionalID("default_case_id"),
+	}
+}
+
+func mapTemplateError(err error) error {
+	switch {
+	case errors.Is(err, templates.ErrInvalidTemplate):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, templates.ErrInvalidPriority):
+		return status.Error(codes.InvalidArgument, "invalid template priority")
+	case errors.Is(err, templates.ErrTemplateNotFound):
+		return status.Error(codes.NotFound, "task template not found")
+	case errors.Is(err, templates.ErrTemplateExists):
+		return status.Error(codes.AlreadyExists, "task template already exists")
+	case errors.Is(err, templates.ErrClusterNotFound), errors.Is(err, templates.ErrCaseNotFound):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func marshalResponse(v interface{}) (*structpb.Struct, error) {
+	resp, err := structrpc.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return resp, nil
+}
@@ -0,0 +1,105 @@
+package templates
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+// fakeTemplateService запоминает шаблон последнего вызова
+type fakeTemplateService struct {
+	TemplateService
+
+	err       error
+	template  models.TaskTemplate
+	clusterID *int64
+}
+
+func (f *fakeTemplateService) UpdateTemplate(_ context.Context, template models.TaskTemplate) (models.TaskTemplate, error) {
+	f.template = template
+	return template, f.err
+}
+
+func (f *fakeTemplateService) ListTemplates(_ context.Context, clusterID *int64) ([]models.TaskTemplate, error) {
+	f.clusterID = clusterID
+	return []models.TaskTemplate{{ID: 1}}, f.err
+}
+
+func newRequest(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	req, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return req
+}
+
+func TestUpdateTemplate(t *testing.T) {
+	service := &fakeTemplateService{}
+	api := &serverAPI{templateService: service}
+
+	resp, err := api.UpdateTemplate(context.Background(), newRequest(t, map[string]interface{}{
+		"template_id":      float64(4),
+		"cluster_id":       float64(2),
+		"name":             "card blocked",
+		"title_pattern":    "Карта {{.card}} заблокирована",
+		"default_priority": float64(3),
+		"default_case_id":  float64(7),
+	}))
+	require.NoError(t, err)
+
+	caseID := int64(7)
+	assert.Equal(t, models.TaskTemplate{
+		ID:              4,
+		ClusterID:       2,
+		Name:            "card blocked",
+		TitlePattern:    "Карта {{.card}} заблокирована",
+		DefaultPriority: models.TaskPriorityHigh,
+		DefaultCaseID:   &caseID,
+	}, service.template)
+	assert.Equal(t, float64(4), resp.GetFields()["id"].GetNumberValue())
+}
+
+func TestUpdateTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "missing template", fields: map[string]interface{}{"cluster_id": float64(2)}, wantCode: codes.InvalidArgument},
+		{name: "invalid pattern", err: templates.ErrInvalidTemplate, wantCode: codes.InvalidArgument},
+		{name: "not found", err: templates.ErrTemplateNotFound, wantCode: codes.NotFound},
+		{name: "duplicate name", err: templates.ErrTemplateExists, wantCode: codes.AlreadyExists},
+		{name: "case from another cluster", err: templates.ErrCaseNotFound, wantCode: codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.fields
+			if fields == nil {
+				fields = map[string]interface{}{"template_id": float64(4), "cluster_id": float64(2)}
+			}
+			api := &serverAPI{templateService: &fakeTemplateService{err: tt.err}}
+
+			_, err := api.UpdateTemplate(context.Background(), newRequest(t, fields))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	service := &fakeTemplateService{}
+	api := &serverAPI{templateService: service}
+
+	resp, err := api.ListTemplates(context.Background(), newRequest(t, map[string]interface{}{"cluster_id": float64(2)}))
+	require.NoError(t, err)
+
+	require.NotNil(t, service.clusterID)
+	assert.Equal(t, int64(2), *service.clusterID)
+	assert.Len(t, resp.GetFields()["templates"].GetListValue().GetValues(), 1)
+}
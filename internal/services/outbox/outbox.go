@@ -0,0 +1,237 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrMessageNotFound  = errors.New("outbox message not found")
+	ErrUnknownTopic     = errors.New("unknown outbox topic")
+	ErrInvalidUser      = errors.New("invalid user")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+type MessageStore interface {
+	ClaimOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxMessage, error)
+	UpdateOutboxMessage(ctx context.Context, message models.OutboxMessage) error
+	OutboxMessageByID(ctx context.Context, id int64) (models.OutboxMessage, error)
+	ListOutboxMessages(ctx context.Context, status models.OutboxStatus, limit int) ([]models.OutboxMessage, error)
+}
+
+// Service доставляет сообщения outbox с повторами и позволяет администраторам переотправлять недоставленные
+type Service struct {
+	log          *logrus.Logger
+	messageStore MessageStore
+	client       *http.Client
+	endpoints    map[string]string
+	cfg          config.OutboxConfig
+	userService  user.UserService
+}
+
+// New создает Service; endpoints задает URL получателя по теме сообщения
+func New(log *logrus.Logger, messageStore MessageStore, endpoints map[string]string, cfg config.OutboxConfig, userService user.UserService) *Service {
+	return &Service{
+		log:          log,
+		messageStore: messageStore,
+		client:       &http.Client{Timeout: cfg.Timeout},
+		endpoints:    endpoints,
+		cfg:          cfg,
+		userService:  userService,
+	}
+}
+
+func (s *Service) Run(ctx context.Context) {
+	const op = "outbox.Service.Run"
+	log := s.log.WithField("op", op)
+
+	log.Info("outbox delivery started")
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("outbox delivery stopped")
+			return
+		case <-ticker.C:
+			if err := s.DeliverPending(ctx); err != nil {
+				log.WithError(err).Error("failed to deliver outbox messages")
+			}
+		}
+	}
+}
+
+func (s *Service) DeliverPending(ctx context.Context) error {
+	const op = "outbox.Service.DeliverPending"
+	log := s.log.WithField("op", op)
+
+	messages, err := s.messageStore.ClaimOutboxMessages(ctx, time.Now(), s.lease(), s.cfg.BatchSize)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, message := range messages {
+		message.Attempts++
+
+		if err := s.deliver(ctx, message); err != nil {
+			lastError := err.Error()
+			message.LastError = &lastError
+
+			if message.Attempts >= s.cfg.MaxAttempts {
+				message.Status = models.OutboxStatusDead
+				log.WithError(err).WithField("messageID", message.ID).Error("outbox message moved to dead letter")
+			} else {
+				message.NextAttemptAt = time.Now().Add(s.backoff(message.Attempts))
+				log.WithError(err).WithField("messageID", message.ID).WithField("attempt", message.Attempts).Warn("failed to deliver outbox message")
+			}
+		} else {
+			deliveredAt := time.Now()
+			message.Status = models.OutboxStatusDelivered
+			message.DeliveredAt = &deliveredAt
+			message.LastError = nil
+		}
+
+		if err := s.messageStore.UpdateOutboxMessage(ctx, message); err != nil {
+			log.WithError(err).WithField("messageID", message.ID).Error("failed to update outbox message")
+		}
+	}
+
+	return nil
+}
+
+// lease время, на которое захватывается пачка: сообщения доставляются по очереди, поэтому аренда должна
+// покрывать доставку всей пачки с запасом в одну попытку, иначе другой экземпляр повторит ещё не отправленные
+func (s *Service) lease() time.Duration {
+	return time.Duration(s.cfg.BatchSize+1) * s.cfg.Timeout
+}
+
+func (s *Service) deliver(ctx context.Context, message models.OutboxMessage) error {
+	url, ok := s.endpoints[message.Topic]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTopic, message.Topic)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(message.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *Service) backoff(attempt int) time.Duration {
+	delay := s.cfg.RetryInterval
+	for i := 1; i < attempt && delay < s.cfg.MaxRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.MaxRetryInterval {
+		delay = s.cfg.MaxRetryInterval
+	}
+	return delay
+}
+
+// ListMessages возвращает сообщения outbox в статусе status; доступно только администратору
+func (s *Service) ListMessages(ctx context.Context, status models.OutboxStatus, limit int) ([]models.OutboxMessage, error) {
+	const op = "outbox.Service.ListMessages"
+	log := s.log.WithField("op", op).WithField("status", status)
+
+	if err := s.requireAdmin(ctx, log); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	log.Info("list outbox messages")
+	messages, err := s.messageStore.ListOutboxMessages(ctx, status, limit)
+	if err != nil {
+		log.WithError(err).Error("failed to list outbox messages")
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// ReplayMessage возвращает сообщение в очередь отправки со сброшенным счетчиком попыток; доступно только администратору
+func (s *Service) ReplayMessage(ctx context.Context, id int64) (models.OutboxMessage, error) {
+	const op = "outbox.Service.ReplayMessage"
+	log := s.log.WithField("op", op).WithField("messageID", id)
+
+	if err := s.requireAdmin(ctx, log); err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	message, err := s.messageStore.OutboxMessageByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrOutboxMessageNotFound) {
+			log.Warn("outbox message not found", err)
+			return models.OutboxMessage{}, ErrMessageNotFound
+		}
+
+		log.WithError(err).Error("failed to get outbox message")
+		return models.OutboxMessage{}, err
+	}
+
+	message.Status = models.OutboxStatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = time.Now()
+	message.DeliveredAt = nil
+
+	log.Info("replay outbox message")
+	if err := s.messageStore.UpdateOutboxMessage(ctx, message); err != nil {
+		log.WithError(err).Error("failed to update outbox message")
+		return models.OutboxMessage{}, err
+	}
+
+	return message, nil
+}
+
+// requireAdmin проверяет, что запрос выполняет администратор
+func (s *Service) requireAdmin(ctx context.Context, log *logrus.Entry) error {
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		log.Warn("user is not admin")
+		return ErrPermissionDenied
+	}
+
+	actor, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return ErrInvalidUser
+		}
+		log.WithError(err).Error("failed to get user")
+		return err
+	}
+	if actor.Role != postgresql.RoleAdmin {
+		log.Warn("user is not admin")
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
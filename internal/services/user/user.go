@@ -19,6 +19,7 @@ type UserProvider interface {
 	UserByID(ctx context.Context, userID int64) (models.User, error)
 	UserList(ctx context.Context) ([]models.User, error)
 	UpdateUser(ctx context.Context, user models.User) error
+	AddUserAvarageDuration(ctx context.Context, userID int64, delta float32) error
 }
 
 var (
@@ -106,3 +107,21 @@ func (s *UserService) UpdateUserAvarageDuration(ctx context.Context, userID int6
 	}
 	return nil
 }
+
+// AddUserAvarageDuration изменяет нагрузку пользователя на delta одним запросом, без чтения текущего значения
+func (s *UserService) AddUserAvarageDuration(ctx context.Context, userID int64, delta float32) error {
+	const op = "UserService.AddUserAvarageDuration"
+	log := s.log.WithField("op", op).WithField("userID", userID)
+
+	log.Info("changing user avarage duration")
+	if err := s.userProvider.AddUserAvarageDuration(ctx, userID, delta); err != nil {
+		if errors.Is(err, postgresql.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
+		log.WithError(err).Error("failed to change user avarage duration")
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package archive
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type TaskService interface {
+	ArchiveClosedTasks(ctx context.Context, completedBefore time.Time, limit int) (found int, archived int, err error)
+}
+
+// Archiver периодически переносит в архив задачи, завершённые дольше cfg.After назад
+type Archiver struct {
+	log         *logrus.Logger
+	taskService TaskService
+	cfg         config.ArchiveConfig
+}
+
+func New(log *logrus.Logger, taskService TaskService, cfg config.ArchiveConfig) *Archiver {
+	return &Archiver{
+		log:         log,
+		taskService: taskService,
+		cfg:         cfg,
+	}
+}
+
+func (a *Archiver) Run(ctx context.Context) {
+	const op = "archive.Archiver.Run"
+	log := a.log.WithField("op", op)
+
+	log.WithField("interval", a.cfg.Interval).WithField("after", a.cfg.After).Info("archiver started")
+
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("archiver stopped")
+			return
+		case <-ticker.C:
+			if err := a.Archive(ctx, time.Now()); err != nil {
+				log.WithError(err).Error("failed to archive tasks")
+			}
+		}
+	}
+}
+
+// Archive архивирует задачи пачками по cfg.BatchSize, пока находятся полные пачки
+func (a *Archiver) Archive(ctx context.Context, now time.Time) error {
+	const op = "archive.Archiver.Archive"
+	log := a.log.WithField("op", op)
+
+	completedBefore := now.Add(-a.cfg.After)
+	total := 0
+	for ctx.Err() == nil {
+		found, archived, err := a.taskService.ArchiveClosedTasks(ctx, completedBefore, a.cfg.BatchSize)
+		total += archived
+		if err != nil {
+			return err
+		}
+		if found < a.cfg.BatchSize || archived == 0 {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.WithField("count", total).Info("archive pass completed")
+	}
+
+	return nil
+}
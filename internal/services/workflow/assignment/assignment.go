@@ -0,0 +1,145 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+)
+
+type CandidateProvider interface {
+	ListAgents(ctx context.Context) ([]models.User, error)
+	ClosedTaskCountsByCluster(ctx context.Context, clusterID int64) (map[int64]int64, error)
+	ActiveTaskCounts(ctx context.Context) (map[int64]int64, error)
+}
+
+// Assigner выбирает исполнителя задачи стратегией, заданной для её кластера
+type Assigner struct {
+	log               *logrus.Logger
+	candidateProvider CandidateProvider
+	defaultStrategy   Strategy
+	clusterStrategies map[int64]Strategy
+	agentCapacity     int64
+}
+
+// New создает Assigner; clusterStrategies задает имя стратегии по индексу кластера,
+// agentCapacity ограничивает число активных задач агента, 0 без ограничения
+func New(log *logrus.Logger, candidateProvider CandidateProvider, defaultStrategy string, clusterStrategies map[int64]string, agentCapacity int64) (*Assigner, error) {
+	const op = "assignment.New"
+
+	def, err := NewStrategy(defaultStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	strategies := make(map[int64]Strategy, len(clusterStrategies))
+	for clusterIndex, name := range clusterStrategies {
+		strategy, err := NewStrategy(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cluster %d: %w", op, clusterIndex, err)
+		}
+		strategies[clusterIndex] = strategy
+	}
+
+	return &Assigner{
+		log:               log,
+		candidateProvider: candidateProvider,
+		defaultStrategy:   def,
+		clusterStrategies: strategies,
+		agentCapacity:     agentCapacity,
+	}, nil
+}
+
+// Choose выбирает исполнителя задачи; агенты из exclude не рассматриваются
+func (a *Assigner) Choose(ctx context.Context, task models.Task, exclude ...int64) (Decision, error) {
+	const op = "assignment.Assigner.Choose"
+	log := a.log.WithField("op", op).WithField("taskID", task.ID)
+
+	candidates, err := a.Candidates(ctx, task, exclude...)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	strategy := a.strategyFor(task)
+	decision, err := strategy.Choose(ctx, task, candidates)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.WithField("strategy", decision.Strategy).WithField("userID", decision.User.ID).Info(decision.Reason)
+
+	return decision, nil
+}
+
+// Peek показывает, кого выбрал бы Choose, не меняя состояние стратегии (очередь round robin и т.п.)
+func (a *Assigner) Peek(ctx context.Context, task models.Task, exclude ...int64) (Decision, error) {
+	const op = "assignment.Assigner.Peek"
+
+	candidates, err := a.Candidates(ctx, task, exclude...)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	decision, err := a.strategyFor(task).Peek(ctx, task, candidates)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return decision, nil
+}
+
+// Candidates возвращает активных агентов (не администраторов и не удаленных) со свободной емкостью
+// и их опытом в кластере задачи, кроме агентов из exclude
+func (a *Assigner) Candidates(ctx context.Context, task models.Task, exclude ...int64) ([]Candidate, error) {
+	const op = "assignment.Assigner.Candidates"
+
+	agents, err := a.candidateProvider.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	closed := map[int64]int64{}
+	if task.ClusterID != nil {
+		closed, err = a.candidateProvider.ClosedTaskCountsByCluster(ctx, *task.ClusterID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	active, err := a.candidateProvider.ActiveTaskCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	excluded := make(map[int64]bool, len(exclude))
+	for _, userID := range exclude {
+		excluded[userID] = true
+	}
+
+	candidates := make([]Candidate, 0, len(agents))
+	for _, agent := range agents {
+		if excluded[agent.ID] {
+			continue
+		}
+		if a.agentCapacity > 0 && active[agent.ID] >= a.agentCapacity {
+			continue
+		}
+
+		candidates = append(candidates, Candidate{
+			User:          agent,
+			ClusterClosed: closed[agent.ID],
+			ActiveTasks:   active[agent.ID],
+		})
+	}
+
+	return candidates, nil
+}
+
+func (a *Assigner) strategyFor(task models.Task) Strategy {
+	if task.Cluster != nil {
+		if strategy, ok := a.clusterStrategies[task.Cluster.ClusterIndex]; ok {
+			return strategy
+		}
+	}
+	return a.defaultStrategy
+}
@@ -0,0 +1,276 @@
+package assignment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StrategyLeastLoad       = "least_load"
+	StrategyRoundRobin      = "round_robin"
+	StrategyClusterAffinity = "cluster_affinity"
+	StrategyRandomWeighted  = "random_weighted"
+)
+
+var (
+	ErrNoCandidates    = errors.New("no agents available for assignment")
+	ErrUnknownStrategy = errors.New("unknown assignment strategy")
+)
+
+// Candidate агент, которому можно назначить задачу
+type Candidate struct {
+	User models.User
+	// ClusterClosed количество закрытых агентом задач в кластере задачи
+	ClusterClosed int64
+	// ActiveTasks количество задач агента в работе, на паузе или в ожидании клиента
+	ActiveTasks int64
+}
+
+type Score struct {
+	UserID int64   `json:"user_id"`
+	Email  string  `json:"email"`
+	Score  float64 `json:"score"`
+}
+
+// Decision выбранный агент и объяснение выбора
+type Decision struct {
+	User     models.User `json:"user"`
+	Strategy string      `json:"strategy"`
+	Reason   string      `json:"reason"`
+	Scores   []Score     `json:"scores"`
+}
+
+type Strategy interface {
+	Name() string
+	Choose(ctx context.Context, task models.Task, candidates []Candidate) (Decision, error)
+	// Peek возвращает решение, которое принял бы Choose, не меняя состояние стратегии
+	Peek(ctx context.Context, task models.Task, candidates []Candidate) (Decision, error)
+}
+
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case StrategyLeastLoad:
+		return LeastLoad{}, nil
+	case StrategyRoundRobin:
+		return &RoundRobin{}, nil
+	case StrategyClusterAffinity:
+		return ClusterAffinity{}, nil
+	case StrategyRandomWeighted:
+		return NewRandomWeighted(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+}
+
+// LeastLoad выбирает агента с минимальной суммарной ожидаемой длительностью задач
+type LeastLoad struct{}
+
+func (LeastLoad) Name() string {
+	return StrategyLeastLoad
+}
+
+func (s LeastLoad) Choose(_ context.Context, _ models.Task, candidates []Candidate) (Decision, error) {
+	if len(candidates) == 0 {
+		return Decision{}, ErrNoCandidates
+	}
+
+	scores := make([]Score, 0, len(candidates))
+	best := candidates[0]
+	for _, c := range candidates {
+		scores = append(scores, Score{UserID: c.User.ID, Email: c.User.Email, Score: float64(c.User.AvarageDuration)})
+		if c.User.AvarageDuration < best.User.AvarageDuration {
+			best = c
+		}
+	}
+
+	return Decision{
+		User:     best.User,
+		Strategy: s.Name(),
+		Reason:   fmt.Sprintf("lowest predicted load %.2f", best.User.AvarageDuration),
+		Scores:   scores,
+	}, nil
+}
+
+func (s LeastLoad) Peek(ctx context.Context, task models.Task, candidates []Candidate) (Decision, error) {
+	return s.Choose(ctx, task, candidates)
+}
+
+// RoundRobin назначает агентов по очереди в порядке их ID
+type RoundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (*RoundRobin) Name() string {
+	return StrategyRoundRobin
+}
+
+func (s *RoundRobin) Choose(_ context.Context, _ models.Task, candidates []Candidate) (Decision, error) {
+	if len(candidates) == 0 {
+		return Decision{}, ErrNoCandidates
+	}
+
+	s.mu.Lock()
+	position := s.next % len(candidates)
+	s.next = position + 1
+	s.mu.Unlock()
+
+	return s.decide(candidates, position), nil
+}
+
+// Peek возвращает следующего по очереди агента, не сдвигая очередь
+func (s *RoundRobin) Peek(_ context.Context, _ models.Task, candidates []Candidate) (Decision, error) {
+	if len(candidates) == 0 {
+		return Decision{}, ErrNoCandidates
+	}
+
+	s.mu.Lock()
+	position := s.next % len(candidates)
+	s.mu.Unlock()
+
+	return s.decide(candidates, position), nil
+}
+
+func (s *RoundRobin) decide(candidates []Candidate, position int) Decision {
+	ordered := append([]Candidate(nil), candidates...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].User.ID < ordered[j].User.ID })
+
+	scores := make([]Score, 0, len(ordered))
+	for i, c := range ordered {
+		scores = append(scores, Score{UserID: c.User.ID, Email: c.User.Email, Score: float64((i - position + len(ordered)) % len(ordered))})
+	}
+
+	return Decision{
+		User:     ordered[position].User,
+		Strategy: s.Name(),
+		Reason:   fmt.Sprintf("next in rotation (position %d of %d)", position+1, len(ordered)),
+		Scores:   scores,
+	}
+}
+
+// ClusterAffinity выбирает агента с наибольшим опытом в кластере задачи, при равенстве менее загруженного
+type ClusterAffinity struct{}
+
+func (ClusterAffinity) Name() string {
+	return StrategyClusterAffinity
+}
+
+func (s ClusterAffinity) Choose(_ context.Context, _ models.Task, candidates []Candidate) (Decision, error) {
+	if len(candidates) == 0 {
+		return Decision{}, ErrNoCandidates
+	}
+
+	scores := make([]Score, 0, len(candidates))
+	best := candidates[0]
+	for _, c := range candidates {
+		scores = append(scores, Score{UserID: c.User.ID, Email: c.User.Email, Score: float64(c.ClusterClosed)})
+		if c.ClusterClosed > best.ClusterClosed ||
+			c.ClusterClosed == best.ClusterClosed && c.User.AvarageDuration < best.User.AvarageDuration {
+			best = c
+		}
+	}
+
+	return Decision{
+		User:     best.User,
+		Strategy: s.Name(),
+		Reason:   fmt.Sprintf("closed %d tasks in this cluster, load %.2f", best.ClusterClosed, best.User.AvarageDuration),
+		Scores:   scores,
+	}, nil
+}
+
+func (s ClusterAffinity) Peek(ctx context.Context, task models.Task, candidates []Candidate) (Decision, error) {
+	return s.Choose(ctx, task, candidates)
+}
+
+// RandomWeighted выбирает агента случайно с весом, обратным его нагрузке
+type RandomWeighted struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func NewRandomWeighted() *RandomWeighted {
+	return &RandomWeighted{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (*RandomWeighted) Name() string {
+	return StrategyRandomWeighted
+}
+
+func (s *RandomWeighted) Choose(_ context.Context, _ models.Task, candidates []Candidate) (Decision, error) {
+	if len(candidates) == 0 {
+		return Decision{}, ErrNoCandidates
+	}
+
+	scores, total := weights(candidates)
+
+	s.mu.Lock()
+	point := s.rnd.Float64() * total
+	s.mu.Unlock()
+
+	chosen := len(candidates) - 1
+	for i, score := range scores {
+		point -= score.Score
+		if point < 0 {
+			chosen = i
+			break
+		}
+	}
+
+	for i := range scores {
+		scores[i].Score /= total
+	}
+
+	return Decision{
+		User:     candidates[chosen].User,
+		Strategy: s.Name(),
+		Reason:   fmt.Sprintf("drawn with probability %.2f", scores[chosen].Score),
+		Scores:   scores,
+	}, nil
+}
+
+// Peek не тянет случайное число и возвращает наиболее вероятного агента с вероятностями всех кандидатов
+func (s *RandomWeighted) Peek(_ context.Context, _ models.Task, candidates []Candidate) (Decision, error) {
+	if len(candidates) == 0 {
+		return Decision{}, ErrNoCandidates
+	}
+
+	scores, total := weights(candidates)
+
+	likely := 0
+	for i := range scores {
+		scores[i].Score /= total
+		if scores[i].Score > scores[likely].Score {
+			likely = i
+		}
+	}
+
+	return Decision{
+		User:     candidates[likely].User,
+		Strategy: s.Name(),
+		Reason:   fmt.Sprintf("most likely draw with probability %.2f", scores[likely].Score),
+		Scores:   scores,
+	}, nil
+}
+
+// weights веса кандидатов, обратные их нагрузке, и их сумма
+func weights(candidates []Candidate) ([]Score, float64) {
+	scores := make([]Score, 0, len(candidates))
+	var total float64
+	for _, c := range candidates {
+		load := float64(c.User.AvarageDuration)
+		if load < 0 {
+			load = 0
+		}
+		weight := 1 / (1 + load)
+		total += weight
+		scores = append(scores, Score{UserID: c.User.ID, Email: c.User.Email, Score: weight})
+	}
+
+	return scores, total
+}
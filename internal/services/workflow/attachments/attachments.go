@@ -0,0 +1,348 @@
+package attachments
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/blobstore"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChunkSize размер части файла в потоковых RPC загрузки и скачивания
+const ChunkSize = 64 * 1024
+
+// sniffSize столько первых байт использует http.DetectContentType
+const sniffSize = 512
+
+const maxFileNameLength = 255
+
+var (
+	ErrUnauthenticated       = errors.New("user is not authenticated")
+	ErrTaskNotFound          = errors.New("task not found")
+	ErrCommentNotFound       = errors.New("comment not found")
+	ErrAttachmentNotFound    = errors.New("attachment not found")
+	ErrNotUploader           = errors.New("only the uploader can delete an attachment")
+	ErrInvalidFileName       = errors.New("invalid file name")
+	ErrEmptyFile             = errors.New("file is empty")
+	ErrTooLarge              = errors.New("file is too large")
+	ErrContentTypeNotAllowed = errors.New("content type is not allowed")
+	ErrBlobReleased          = errors.New("file content was removed concurrently, retry the upload")
+)
+
+// BlobStore хранилище содержимого вложений
+type BlobStore interface {
+	Put(ctx context.Context, r io.Reader) (blobstore.Blob, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type AttachmentStore interface {
+	SaveAttachment(ctx context.Context, attachment models.Attachment) (models.Attachment, error)
+	AttachmentByID(ctx context.Context, id int64) (models.Attachment, error)
+	ListAttachments(ctx context.Context, taskID int64, commentID *int64) ([]models.Attachment, error)
+	DeleteAttachment(ctx context.Context, id int64) error
+	CountAttachmentsByBlob(ctx context.Context, blobKey string) (int64, error)
+	// LockBlob сериализует привязку и освобождение содержимого blobKey до конца транзакции
+	LockBlob(ctx context.Context, blobKey string) error
+}
+
+type TxManager interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type TaskProvider interface {
+	TaskByID(ctx context.Context, taskID int64) (models.Task, error)
+}
+
+type CommentProvider interface {
+	CommentByID(ctx context.Context, id int64) (models.TaskComment, error)
+}
+
+type AttachmentService struct {
+	log             *logrus.Logger
+	blobStore       BlobStore
+	attachmentStore AttachmentStore
+	taskProvider    TaskProvider
+	commentProvider CommentProvider
+	txManager       TxManager
+	allowedTypes    map[string]bool
+}
+
+// New создает AttachmentService; allowedTypes перечисляет допустимые MIME-типы, пустой список разрешает любые
+func New(log *logrus.Logger, blobStore BlobStore, attachmentStore AttachmentStore, taskProvider TaskProvider, commentProvider CommentProvider, txManager TxManager, allowedTypes []string) *AttachmentService {
+	allowed := make(map[string]bool, len(allowedTypes))
+	for _, contentType := range allowedTypes {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			allowed[contentType] = true
+		}
+	}
+
+	return &AttachmentService{
+		log:             log,
+		blobStore:       blobStore,
+		attachmentStore: attachmentStore,
+		taskProvider:    taskProvider,
+		commentProvider: commentProvider,
+		txManager:       txManager,
+		allowedTypes:    allowed,
+	}
+}
+
+// Upload сохраняет файл из r и прикрепляет его к задаче или, если commentID задан, к её комментарию.
+// MIME-тип определяется по содержимому, а не по имени файла
+func (s *AttachmentService) Upload(ctx context.Context, taskID int64, commentID *int64, fileName string, r io.Reader) (models.Attachment, error) {
+	const op = "AttachmentService.Upload"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	uploaderID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		log.Error("failed to get userID from context")
+		return models.Attachment{}, ErrUnauthenticated
+	}
+
+	fileName, err := cleanFileName(fileName)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	if err := s.ensureTarget(ctx, taskID, commentID); err != nil {
+		log.WithError(err).Warn("failed to get attachment target")
+		return models.Attachment{}, err
+	}
+
+	reader := bufio.NewReaderSize(r, sniffSize)
+	head, err := reader.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		log.WithError(err).Error("failed to read upload")
+		return models.Attachment{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(head) == 0 {
+		return models.Attachment{}, ErrEmptyFile
+	}
+
+	contentType := http.DetectContentType(head)
+	if !s.allowed(contentType) {
+		log.WithField("contentType", contentType).Warn("content type is not allowed")
+		return models.Attachment{}, fmt.Errorf("%w: %s", ErrContentTypeNotAllowed, contentType)
+	}
+
+	blob, err := s.blobStore.Put(ctx, reader)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrBlobTooLarge) {
+			log.Warn("file is too large")
+			return models.Attachment{}, ErrTooLarge
+		}
+
+		log.WithError(err).Error("failed to store file")
+		return models.Attachment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.WithField("blob", blob.Key).WithField("size", blob.Size).Info("save attachment")
+	var attachment models.Attachment
+	err = s.txManager.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.attachmentStore.LockBlob(ctx, blob.Key); err != nil {
+			return err
+		}
+
+		// releaseBlob другого вложения с тем же содержимым мог удалить его между Put и блокировкой
+		exists, err := s.blobStore.Exists(ctx, blob.Key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrBlobReleased
+		}
+
+		attachment, err = s.attachmentStore.SaveAttachment(ctx, models.Attachment{
+			TaskID:      taskID,
+			CommentID:   commentID,
+			FileName:    fileName,
+			ContentType: contentType,
+			Size:        blob.Size,
+			BlobKey:     blob.Key,
+			UploaderID:  uploaderID,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrBlobReleased) {
+			log.Warn("file content was removed concurrently")
+			return models.Attachment{}, err
+		}
+
+		log.WithError(err).Error("failed to save attachment")
+		s.releaseBlob(ctx, blob.Key)
+		return models.Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// Download возвращает вложение и поток его содержимого, который вызывающий должен закрыть
+func (s *AttachmentService) Download(ctx context.Context, attachmentID int64) (models.Attachment, io.ReadCloser, error) {
+	const op = "AttachmentService.Download"
+	log := s.log.WithField("op", op).WithField("attachmentID", attachmentID)
+
+	attachment, err := s.attachmentByID(ctx, attachmentID)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+
+	content, err := s.blobStore.Open(ctx, attachment.BlobKey)
+	if err != nil {
+		log.WithError(err).Error("failed to open attachment content")
+		return models.Attachment{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attachment, content, nil
+}
+
+// ListAttachments возвращает вложения задачи; commentID != nil оставляет только вложения комментария
+func (s *AttachmentService) ListAttachments(ctx context.Context, taskID int64, commentID *int64) ([]models.Attachment, error) {
+	const op = "AttachmentService.ListAttachments"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	if err := s.ensureTarget(ctx, taskID, commentID); err != nil {
+		log.WithError(err).Warn("failed to get attachment target")
+		return nil, err
+	}
+
+	attachments, err := s.attachmentStore.ListAttachments(ctx, taskID, commentID)
+	if err != nil {
+		log.WithError(err).Error("failed to list attachments")
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// DeleteAttachment удаляет вложение; содержимое удаляется, когда на него больше не ссылается ни одно вложение
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, attachmentID int64) error {
+	const op = "AttachmentService.DeleteAttachment"
+	log := s.log.WithField("op", op).WithField("attachmentID", attachmentID)
+
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		log.Error("failed to get userID from context")
+		return ErrUnauthenticated
+	}
+
+	attachment, err := s.attachmentByID(ctx, attachmentID)
+	if err != nil {
+		return err
+	}
+	if attachment.UploaderID != userID {
+		return ErrNotUploader
+	}
+
+	log.Info("delete attachment")
+	if err := s.attachmentStore.DeleteAttachment(ctx, attachmentID); err != nil {
+		if errors.Is(err, postgresql.ErrAttachmentNotFound) {
+			return ErrAttachmentNotFound
+		}
+
+		log.WithError(err).Error("failed to delete attachment")
+		return err
+	}
+	s.releaseBlob(ctx, attachment.BlobKey)
+
+	return nil
+}
+
+func (s *AttachmentService) attachmentByID(ctx context.Context, attachmentID int64) (models.Attachment, error) {
+	attachment, err := s.attachmentStore.AttachmentByID(ctx, attachmentID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrAttachmentNotFound) {
+			s.log.Warn("attachment not found", err)
+			return models.Attachment{}, ErrAttachmentNotFound
+		}
+
+		s.log.WithError(err).Error("failed to get attachment")
+		return models.Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// releaseBlob удаляет содержимое, на которое не ссылается ни одно вложение; ошибки только логируются.
+// Подсчёт ссылок и удаление выполняются под блокировкой содержимого, чтобы не удалить файл,
+// к которому в это время привязывается новое вложение
+func (s *AttachmentService) releaseBlob(ctx context.Context, key string) {
+	log := s.log.WithField("blob", key)
+
+	err := s.txManager.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.attachmentStore.LockBlob(ctx, key); err != nil {
+			return err
+		}
+
+		count, err := s.attachmentStore.CountAttachmentsByBlob(ctx, key)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		return s.blobStore.Delete(ctx, key)
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to release blob")
+	}
+}
+
+func (s *AttachmentService) ensureTarget(ctx context.Context, taskID int64, commentID *int64) error {
+	if _, err := s.taskProvider.TaskByID(ctx, taskID); err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+
+	if commentID == nil {
+		return nil
+	}
+
+	comment, err := s.commentProvider.CommentByID(ctx, *commentID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrCommentNotFound) {
+			return ErrCommentNotFound
+		}
+		return err
+	}
+	if comment.TaskID != taskID || comment.DeletedAt != nil {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
+
+func (s *AttachmentService) allowed(contentType string) bool {
+	if len(s.allowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return s.allowedTypes[mediaType]
+}
+
+// cleanFileName оставляет только имя файла без пути
+func cleanFileName(name string) (string, error) {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" || !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxFileNameLength {
+		return "", ErrInvalidFileName
+	}
+	return name, nil
+}
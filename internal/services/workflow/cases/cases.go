@@ -27,6 +27,7 @@ type CaseSaver interface {
 type CaseProvider interface {
 	CaseByID(ctx context.Context, caseID int64) (models.Case, error)
 	ListCasesByClusterID(ctx context.Context, clusterID int64) ([]models.Case, error)
+	SearchCases(ctx context.Context, query models.CaseSearchQuery) ([]models.CaseSearchHit, error)
 }
 
 type ClusterProvider interface {
@@ -37,6 +38,7 @@ type ClusterProvider interface {
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidQuery       = errors.New("invalid search query")
 )
 
 func New(log *logrus.Logger, caseSaver CaseSaver, caseProvider CaseProvider, clusterProvider ClusterProvider, userService user.UserService) *CaseService {
@@ -0,0 +1,41 @@
+package cases
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 100
+	maxSearchTextLength = 256
+)
+
+// SearchCases ищет кейсы по заголовку и решению с учётом морфологии русского языка
+func (s *CaseService) SearchCases(ctx context.Context, query models.CaseSearchQuery) ([]models.CaseSearchHit, error) {
+	const op = "CaseService.SearchCases"
+	log := s.log.WithField("op", op)
+
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" || utf8.RuneCountInString(query.Text) > maxSearchTextLength || query.Offset < 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidQuery)
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit > maxSearchLimit {
+		query.Limit = maxSearchLimit
+	}
+
+	log.WithField("text", query.Text).Info("search cases")
+	hits, err := s.caseProvider.SearchCases(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("failed to search cases")
+		return nil, err
+	}
+
+	return hits, nil
+}
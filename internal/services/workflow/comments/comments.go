@@ -0,0 +1,302 @@
+package comments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CommentService struct {
+	log             *logrus.Logger
+	commentSaver    CommentSaver
+	commentProvider CommentProvider
+	taskProvider    TaskProvider
+	userProvider    UserProvider
+}
+
+type CommentSaver interface {
+	SaveComment(ctx context.Context, comment models.TaskComment) (models.TaskComment, error)
+	UpdateComment(ctx context.Context, comment models.TaskComment, revision models.TaskCommentRevision) error
+}
+
+type CommentProvider interface {
+	CommentByID(ctx context.Context, id int64) (models.TaskComment, error)
+	ListComments(ctx context.Context, taskID int64, includeInternal bool, afterID int64, limit int) ([]models.TaskComment, error)
+	ListCommentRevisions(ctx context.Context, commentID int64) ([]models.TaskCommentRevision, error)
+}
+
+type TaskProvider interface {
+	TaskByID(ctx context.Context, taskID int64) (models.Task, error)
+}
+
+type UserProvider interface {
+	GetUserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+var (
+	ErrUnauthenticated   = errors.New("user is not authenticated")
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrCommentNotFound   = errors.New("comment not found")
+	ErrNotAuthor         = errors.New("only the author can change a comment")
+	ErrEmptyBody         = errors.New("comment body is empty")
+	ErrInvalidVisibility = errors.New("invalid comment visibility")
+	ErrInvalidPageToken  = errors.New("invalid page token")
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// CommentPage страница комментариев и токен следующей страницы, пустой на последней странице
+type CommentPage struct {
+	Comments      []models.TaskComment
+	NextPageToken string
+}
+
+func New(log *logrus.Logger, commentSaver CommentSaver, commentProvider CommentProvider, taskProvider TaskProvider, userProvider UserProvider) *CommentService {
+	return &CommentService{
+		log:             log,
+		commentSaver:    commentSaver,
+		commentProvider: commentProvider,
+		taskProvider:    taskProvider,
+		userProvider:    userProvider,
+	}
+}
+
+func (s *CommentService) AddComment(ctx context.Context, taskID int64, body string, visibility models.CommentVisibility) (models.TaskComment, error) {
+	const op = "CommentService.AddComment"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	authorID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		log.Error("failed to get userID from context")
+		return models.TaskComment{}, ErrUnauthenticated
+	}
+
+	body, err := validate(body, visibility)
+	if err != nil {
+		return models.TaskComment{}, err
+	}
+
+	if err := s.ensureTask(ctx, taskID); err != nil {
+		log.WithError(err).Warn("failed to get task")
+		return models.TaskComment{}, err
+	}
+
+	log.Info("add comment")
+	comment, err := s.commentSaver.SaveComment(ctx, models.TaskComment{
+		TaskID:     taskID,
+		AuthorID:   authorID,
+		Body:       body,
+		Visibility: visibility,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to save comment")
+		return models.TaskComment{}, err
+	}
+
+	return comment, nil
+}
+
+// ListComments возвращает комментарии задачи по возрастанию времени; внутренние заметки только при includeInternal
+func (s *CommentService) ListComments(ctx context.Context, taskID int64, includeInternal bool, pageToken string, pageSize int) (CommentPage, error) {
+	const op = "CommentService.ListComments"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	var afterID int64
+	if pageToken != "" {
+		id, err := strconv.ParseInt(pageToken, 10, 64)
+		if err != nil || id < 0 {
+			return CommentPage{}, ErrInvalidPageToken
+		}
+		afterID = id
+	}
+
+	if err := s.ensureTask(ctx, taskID); err != nil {
+		log.WithError(err).Warn("failed to get task")
+		return CommentPage{}, err
+	}
+
+	log.Info("list comments")
+	comments, err := s.commentProvider.ListComments(ctx, taskID, includeInternal, afterID, pageSize+1)
+	if err != nil {
+		log.WithError(err).Error("failed to list comments")
+		return CommentPage{}, err
+	}
+
+	page := CommentPage{Comments: comments}
+	if len(comments) > pageSize {
+		page.Comments = comments[:pageSize]
+		page.NextPageToken = strconv.FormatInt(page.Comments[pageSize-1].ID, 10)
+	}
+
+	return page, nil
+}
+
+func (s *CommentService) EditComment(ctx context.Context, commentID int64, body string, visibility models.CommentVisibility) (models.TaskComment, error) {
+	const op = "CommentService.EditComment"
+	log := s.log.WithField("op", op).WithField("commentID", commentID)
+
+	body, err := validate(body, visibility)
+	if err != nil {
+		return models.TaskComment{}, err
+	}
+
+	comment, editorID, err := s.authoredComment(ctx, commentID)
+	if err != nil {
+		log.WithError(err).Warn("failed to get comment")
+		return models.TaskComment{}, err
+	}
+
+	revision := revisionOf(comment, models.CommentActionEdited, editorID)
+	editedAt := time.Now()
+	comment.Body = body
+	comment.Visibility = visibility
+	comment.EditedAt = &editedAt
+
+	log.Info("edit comment")
+	if err := s.commentSaver.UpdateComment(ctx, comment, revision); err != nil {
+		log.WithError(err).Error("failed to update comment")
+		return models.TaskComment{}, err
+	}
+
+	return comment, nil
+}
+
+func (s *CommentService) DeleteComment(ctx context.Context, commentID int64) error {
+	const op = "CommentService.DeleteComment"
+	log := s.log.WithField("op", op).WithField("commentID", commentID)
+
+	comment, editorID, err := s.authoredComment(ctx, commentID)
+	if err != nil {
+		log.WithError(err).Warn("failed to get comment")
+		return err
+	}
+
+	revision := revisionOf(comment, models.CommentActionDeleted, editorID)
+	deletedAt := time.Now()
+	comment.DeletedAt = &deletedAt
+
+	log.Info("delete comment")
+	if err := s.commentSaver.UpdateComment(ctx, comment, revision); err != nil {
+		log.WithError(err).Error("failed to delete comment")
+		return err
+	}
+
+	return nil
+}
+
+func (s *CommentService) ListCommentRevisions(ctx context.Context, commentID int64) ([]models.TaskCommentRevision, error) {
+	const op = "CommentService.ListCommentRevisions"
+	log := s.log.WithField("op", op).WithField("commentID", commentID)
+
+	if _, err := s.commentProvider.CommentByID(ctx, commentID); err != nil {
+		if errors.Is(err, postgresql.ErrCommentNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		log.WithError(err).Error("failed to get comment")
+		return nil, err
+	}
+
+	revisions, err := s.commentProvider.ListCommentRevisions(ctx, commentID)
+	if err != nil {
+		log.WithError(err).Error("failed to list comment revisions")
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// CanSeeInternal сообщает, видит ли пользователь из контекста внутренние заметки: их видят только агенты и администраторы
+func (s *CommentService) CanSeeInternal(ctx context.Context) (bool, error) {
+	const op = "CommentService.CanSeeInternal"
+	log := s.log.WithField("op", op)
+
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		return false, nil
+	}
+
+	actor, err := s.userProvider.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return false, ErrUnauthenticated
+		}
+		log.WithError(err).Error("failed to get user")
+		return false, err
+	}
+
+	return actor.Role == postgresql.RoleUser || actor.Role == postgresql.RoleAdmin, nil
+}
+
+// authoredComment возвращает комментарий, если пользователь из контекста его автор
+func (s *CommentService) authoredComment(ctx context.Context, commentID int64) (models.TaskComment, int64, error) {
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		return models.TaskComment{}, 0, ErrUnauthenticated
+	}
+
+	comment, err := s.commentProvider.CommentByID(ctx, commentID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrCommentNotFound) {
+			return models.TaskComment{}, 0, ErrCommentNotFound
+		}
+		return models.TaskComment{}, 0, err
+	}
+
+	if comment.AuthorID != userID {
+		return models.TaskComment{}, 0, ErrNotAuthor
+	}
+
+	return comment, userID, nil
+}
+
+func (s *CommentService) ensureTask(ctx context.Context, taskID int64) error {
+	if _, err := s.taskProvider.TaskByID(ctx, taskID); err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			return ErrTaskNotFound
+		}
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+	return nil
+}
+
+func validate(body string, visibility models.CommentVisibility) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", ErrEmptyBody
+	}
+
+	switch visibility {
+	case models.CommentVisibilityInternal, models.CommentVisibilityCustomer:
+		return body, nil
+	default:
+		return "", ErrInvalidVisibility
+	}
+}
+
+func revisionOf(comment models.TaskComment, action models.CommentAction, editorID int64) models.TaskCommentRevision {
+	return models.TaskCommentRevision{
+		CommentID:  comment.ID,
+		Action:     action,
+		Body:       comment.Body,
+		Visibility: comment.Visibility,
+		EditorID:   editorID,
+	}
+}
@@ -0,0 +1,181 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// Queue очередь задач на автоматическое назначение
+type Queue struct {
+	ch chan int64
+}
+
+func NewQueue(size int) *Queue {
+	return &Queue{ch: make(chan int64, size)}
+}
+
+// Enqueue ставит задачу в очередь без блокировки; при переполнении задачу подберет периодический обход
+func (q *Queue) Enqueue(taskID int64) bool {
+	select {
+	case q.ch <- taskID:
+		return true
+	default:
+		return false
+	}
+}
+
+type TaskService interface {
+	QueryTasks(ctx context.Context, query models.TaskQuery) (models.TaskPage, error)
+	AppointUserToTask(ctx context.Context, taskID int64) (models.Task, error)
+}
+
+// Dispatcher назначает задачи из очереди и повторяет попытки, пока нет свободных агентов
+type Dispatcher struct {
+	log         *logrus.Logger
+	queue       *Queue
+	taskService TaskService
+	cfg         config.DispatchConfig
+
+	mu      sync.Mutex
+	pending map[int64]struct{}
+}
+
+func New(log *logrus.Logger, queue *Queue, taskService TaskService, cfg config.DispatchConfig) *Dispatcher {
+	return &Dispatcher{
+		log:         log,
+		queue:       queue,
+		taskService: taskService,
+		cfg:         cfg,
+		pending:     make(map[int64]struct{}),
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	const op = "dispatch.Dispatcher.Run"
+	log := d.log.WithField("op", op)
+
+	log.Info("dispatcher started")
+
+	sweep := time.NewTicker(d.cfg.SweepInterval)
+	defer sweep.Stop()
+
+	d.sweep(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("dispatcher stopped")
+			return
+		case <-sweep.C:
+			d.sweep(ctx)
+		case taskID := <-d.queue.ch:
+			d.dispatch(ctx, taskID, 0)
+		}
+	}
+}
+
+// sweep ставит в очередь все открытые задачи, например созданные до запуска или выпавшие из очереди
+func (d *Dispatcher) sweep(ctx context.Context) {
+	const op = "dispatch.Dispatcher.sweep"
+	log := d.log.WithField("op", op)
+
+	status := models.TaskStatusOpen
+	merged := false
+	query := models.TaskQuery{Status: &status, Merged: &merged, Limit: 100}
+	for {
+		page, err := d.taskService.QueryTasks(ctx, query)
+		if err != nil {
+			log.WithError(err).Error("failed to list open tasks")
+			return
+		}
+
+		for _, task := range page.Tasks {
+			if task.UserID != nil || d.isPending(task.ID) {
+				continue
+			}
+			if !d.queue.Enqueue(task.ID) {
+				log.Warn("dispatch queue is full")
+				return
+			}
+		}
+
+		if page.NextCursor == "" {
+			return
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, taskID int64, attempt int) {
+	const op = "dispatch.Dispatcher.dispatch"
+	log := d.log.WithField("op", op).WithField("taskID", taskID).WithField("attempt", attempt)
+
+	task, err := d.taskService.AppointUserToTask(ctx, taskID)
+	if err == nil {
+		d.done(taskID)
+		log.WithField("userID", task.UserID).Info("task dispatched")
+		return
+	}
+
+	switch {
+	case errors.Is(err, tasks.ErrAlreadyAppointed),
+		errors.Is(err, tasks.ErrInvalidCredentials),
+		errors.Is(err, tasks.ErrTransitionNotAllowed):
+		d.done(taskID)
+		log.WithError(err).Info("task does not need dispatching")
+		return
+	case errors.Is(err, assignment.ErrNoCandidates):
+		log.Info("no agents available, retry later")
+	default:
+		log.WithError(err).Error("failed to dispatch task")
+	}
+
+	d.retry(ctx, taskID, attempt+1)
+}
+
+// retry повторяет назначение с экспоненциальной задержкой
+func (d *Dispatcher) retry(ctx context.Context, taskID int64, attempt int) {
+	d.mu.Lock()
+	d.pending[taskID] = struct{}{}
+	d.mu.Unlock()
+
+	delay := backoff(d.cfg.RetryInterval, d.cfg.MaxRetryInterval, attempt)
+	time.AfterFunc(delay, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		d.dispatch(ctx, taskID, attempt)
+	})
+}
+
+func (d *Dispatcher) done(taskID int64) {
+	d.mu.Lock()
+	delete(d.pending, taskID)
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) isPending(taskID int64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.pending[taskID]
+	return ok
+}
+
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
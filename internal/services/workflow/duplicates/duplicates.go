@@ -0,0 +1,112 @@
+package duplicates
+
+import (
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// shingleSize длина символьного шингла; символьные шинглы устойчивы к окончаниям русских слов
+	shingleSize = 3
+	// DefaultMaxCandidates число кандидатов, если maxCandidates не задан
+	DefaultMaxCandidates = 200
+)
+
+// Match задача-кандидат в дубликаты и её похожесть на проверяемую задачу от 0 до 1
+type Match struct {
+	Task  models.Task
+	Score float64
+}
+
+// Detector ищет похожие задачи по коэффициенту Жаккара множеств шинглов заголовка и описания
+type Detector struct {
+	threshold     float64
+	maxCandidates int
+}
+
+// New создает Detector; maxCandidates ограничивает число последних задач кластера для сравнения,
+// maxCandidates <= 0 заменяется на DefaultMaxCandidates
+func New(threshold float64, maxCandidates int) *Detector {
+	if maxCandidates <= 0 {
+		maxCandidates = DefaultMaxCandidates
+	}
+	return &Detector{threshold: threshold, maxCandidates: maxCandidates}
+}
+
+func (d *Detector) MaxCandidates() int {
+	return d.maxCandidates
+}
+
+// Match возвращает кандидатов с похожестью не ниже порога по убыванию похожести
+func (d *Detector) Match(task models.Task, candidates []models.Task) []Match {
+	shingles := taskShingles(task)
+
+	var matches []Match
+	for _, candidate := range candidates {
+		if candidate.ID == task.ID {
+			continue
+		}
+
+		score := jaccard(shingles, taskShingles(candidate))
+		if score >= d.threshold {
+			matches = append(matches, Match{Task: candidate, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches
+}
+
+// Similarity возвращает похожесть двух текстов от 0 до 1
+func Similarity(a, b string) float64 {
+	return jaccard(shingles(a), shingles(b))
+}
+
+func taskShingles(task models.Task) map[string]struct{} {
+	return shingles(task.Title + " " + task.Description)
+}
+
+func shingles(text string) map[string]struct{} {
+	runes := []rune(normalize(text))
+	set := make(map[string]struct{})
+	if len(runes) == 0 {
+		return set
+	}
+	if len(runes) < shingleSize {
+		set[string(runes)] = struct{}{}
+		return set
+	}
+
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		set[string(runes[i:i+shingleSize])] = struct{}{}
+	}
+	return set
+}
+
+// normalize приводит текст к нижнему регистру и оставляет только буквы и цифры, разделённые пробелом
+func normalize(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for shingle := range a {
+		if _, ok := b[shingle]; ok {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
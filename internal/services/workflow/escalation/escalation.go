@@ -0,0 +1,157 @@
+package escalation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/tasks"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type TaskService interface {
+	QueryTasks(ctx context.Context, query models.TaskQuery) (models.TaskPage, error)
+	EscalateTask(ctx context.Context, taskID int64, level models.EscalationLevel, reason string) (models.Task, error)
+}
+
+// Policy политики эскалации по индексу кластера
+type Policy struct {
+	def      config.EscalationPolicy
+	clusters config.EscalationClusterPolicies
+}
+
+func NewPolicy(cfg config.EscalationConfig) *Policy {
+	return &Policy{
+		def: config.EscalationPolicy{
+			NotifyAfter:   cfg.NotifyAfter,
+			ReassignAfter: cfg.ReassignAfter,
+			EscalateAfter: cfg.EscalateAfter,
+		},
+		clusters: cfg.ClusterPolicies,
+	}
+}
+
+func (p *Policy) For(clusterIndex int64) config.EscalationPolicy {
+	if policy, ok := p.clusters[clusterIndex]; ok {
+		return policy
+	}
+	return p.def
+}
+
+// Due возвращает старший шаг эскалации, срок которого для задачи уже наступил
+func (p *Policy) Due(task models.Task, now time.Time) (models.EscalationLevel, time.Duration) {
+	// у задач, взятых в работу до появления эскалации, отсчёт идёт от FormedAt
+	startedAt := task.EscalationStartedAt
+	if startedAt == nil {
+		startedAt = task.FormedAt
+	}
+	if task.Status != models.TaskStatusInProgress || startedAt == nil {
+		return models.EscalationNone, 0
+	}
+
+	policy := p.def
+	if task.Cluster != nil {
+		policy = p.For(task.Cluster.ClusterIndex)
+	}
+
+	elapsed := now.Sub(*startedAt)
+	steps := []struct {
+		level models.EscalationLevel
+		after time.Duration
+	}{
+		{models.EscalationEscalated, policy.EscalateAfter},
+		{models.EscalationReassigned, policy.ReassignAfter},
+		{models.EscalationNotified, policy.NotifyAfter},
+	}
+	for _, step := range steps {
+		if step.after > 0 && elapsed >= step.after {
+			return step.level, step.after
+		}
+	}
+
+	return models.EscalationNone, 0
+}
+
+// Scheduler периодически проверяет задачи в работе и выполняет наступившие шаги эскалации.
+// Если пропущено несколько шагов, выполняется только старший
+type Scheduler struct {
+	log         *logrus.Logger
+	policy      *Policy
+	taskService TaskService
+	interval    time.Duration
+}
+
+func New(log *logrus.Logger, policy *Policy, taskService TaskService, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		log:         log,
+		policy:      policy,
+		taskService: taskService,
+		interval:    interval,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	const op = "escalation.Scheduler.Run"
+	log := s.log.WithField("op", op)
+
+	log.WithField("interval", s.interval).Info("escalation scheduler started")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("escalation scheduler stopped")
+			return
+		case <-ticker.C:
+			if err := s.Evaluate(ctx, time.Now()); err != nil {
+				log.WithError(err).Error("failed to evaluate escalations")
+			}
+		}
+	}
+}
+
+func (s *Scheduler) Evaluate(ctx context.Context, now time.Time) error {
+	const op = "escalation.Scheduler.Evaluate"
+	log := s.log.WithField("op", op)
+
+	status := models.TaskStatusInProgress
+	query := models.TaskQuery{
+		Status: &status,
+		Limit:  100,
+	}
+
+	for {
+		page, err := s.taskService.QueryTasks(ctx, query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, task := range page.Tasks {
+			level, after := s.policy.Due(task, now)
+			if level <= task.EscalationLevel {
+				continue
+			}
+
+			reason := fmt.Sprintf("task is in progress for more than %s", after)
+			if _, err := s.taskService.EscalateTask(ctx, task.ID, level, reason); err != nil {
+				// задачу могли изменить после выборки, она будет проверена на следующем проходе
+				if errors.Is(err, tasks.ErrVersionConflict) || errors.Is(err, tasks.ErrTransitionNotAllowed) {
+					log.WithField("taskID", task.ID).Warn("task changed before escalation", err)
+					continue
+				}
+				log.WithError(err).WithField("taskID", task.ID).Error("failed to escalate task")
+				continue
+			}
+			log.WithField("taskID", task.ID).WithField("level", level).Info(reason)
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,346 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PageSize столько задач читается из базы и записывается за раз
+const PageSize = 500
+
+var (
+	ErrInvalidFormat = errors.New("invalid export format")
+	ErrInvalidQuery  = errors.New("invalid export query")
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat разбирает формат выгрузки без учёта регистра
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(value))); format {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidFormat, value)
+	}
+}
+
+// ParseStatus разбирает название статуса задачи без учёта регистра
+func ParseStatus(value string) (models.TaskStatus, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	for status := models.TaskStatusOpen; status <= models.TaskStatusReopened; status++ {
+		if status.String() == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown status %q", ErrInvalidQuery, value)
+}
+
+type TaskProvider interface {
+	QueryTasks(ctx context.Context, query models.TaskQuery) (models.TaskPage, error)
+}
+
+type Exporter struct {
+	log          *logrus.Logger
+	taskProvider TaskProvider
+}
+
+func New(log *logrus.Logger, taskProvider TaskProvider) *Exporter {
+	return &Exporter{
+		log:          log,
+		taskProvider: taskProvider,
+	}
+}
+
+// Row строка выгрузки; длительности в секундах, пустые, если этап ещё не пройден.
+// ReactionSeconds от создания до взятия в работу, ResolutionSeconds от взятия в работу до завершения
+type Row struct {
+	ID                int64      `json:"id"`
+	Title             string     `json:"title"`
+	Description       string     `json:"description"`
+	Status            string     `json:"status"`
+	Priority          string     `json:"priority"`
+	Fire              bool       `json:"fire"`
+	ClusterID         *int64     `json:"cluster_id"`
+	ClusterIndex      *int64     `json:"cluster_index"`
+	ClusterName       string     `json:"cluster_name"`
+	CaseID            *int64     `json:"case_id"`
+	CaseTitle         string     `json:"case_title"`
+	Solution          string     `json:"solution"`
+	AssigneeID        *int64     `json:"assignee_id"`
+	AssigneeEmail     string     `json:"assignee_email"`
+	Labels            []string   `json:"labels"`
+	CreatedAt         time.Time  `json:"created_at"`
+	FormedAt          *time.Time `json:"formed_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	ReactionSeconds   *int64     `json:"reaction_seconds"`
+	ResolutionSeconds *int64     `json:"resolution_seconds"`
+	AvarageDuration   float32    `json:"avarage_duration"`
+}
+
+var csvHeader = []string{
+	"id", "title", "description", "status", "priority", "fire",
+	"cluster_id", "cluster_index", "cluster_name", "case_id", "case_title", "solution",
+	"assignee_id", "assignee_email", "labels",
+	"created_at", "formed_at", "completed_at", "reaction_seconds", "resolution_seconds", "avarage_duration",
+}
+
+// Export пишет в w задачи, подходящие под фильтры query, страницами по PageSize, не держа выгрузку в памяти.
+// Сортировка и курсор query игнорируются: задачи выгружаются по возрастанию id. Возвращает количество задач
+func (e *Exporter) Export(ctx context.Context, query models.TaskQuery, format Format, w io.Writer) (int, error) {
+	const op = "Exporter.Export"
+	log := e.log.WithField("op", op).WithField("format", format)
+
+	var encoder rowEncoder
+	buffered := bufio.NewWriter(w)
+	switch format {
+	case FormatCSV:
+		encoder = &csvEncoder{w: csv.NewWriter(buffered)}
+	case FormatJSON:
+		encoder = &jsonEncoder{w: buffered}
+	case FormatNDJSON:
+		encoder = &ndjsonEncoder{w: buffered}
+	default:
+		return 0, fmt.Errorf("%s: %w: %q", op, ErrInvalidFormat, format)
+	}
+
+	query.Labels = labels.NormalizeNames(query.Labels)
+	query.Sort = []models.TaskSort{{Field: models.TaskSortID}}
+	query.Cursor = ""
+	query.Limit = PageSize
+
+	if err := encoder.begin(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count := 0
+	for {
+		page, err := e.taskProvider.QueryTasks(ctx, query)
+		if err != nil {
+			if errors.Is(err, postgresql.ErrInvalidCursor) || errors.Is(err, postgresql.ErrInvalidSortField) {
+				return count, fmt.Errorf("%s: %w", op, ErrInvalidQuery)
+			}
+			log.WithError(err).Error("failed to query tasks")
+			return count, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, task := range page.Tasks {
+			if err := encoder.encode(newRow(task)); err != nil {
+				return count, fmt.Errorf("%s: %w", op, err)
+			}
+			count++
+		}
+		if err := encoder.flush(); err != nil {
+			return count, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := buffered.Flush(); err != nil {
+			return count, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if err := encoder.end(); err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.WithField("count", count).Info("tasks exported")
+	return count, nil
+}
+
+func newRow(task models.Task) Row {
+	row := Row{
+		ID:              task.ID,
+		Title:           task.Title,
+		Description:     task.Description,
+		Status:          task.Status.String(),
+		Priority:        task.Priority.String(),
+		Fire:            task.Fire,
+		ClusterID:       task.ClusterID,
+		CaseID:          task.CaseID,
+		AssigneeID:      task.UserID,
+		Labels:          make([]string, 0, len(task.Labels)),
+		CreatedAt:       task.CreatedAt,
+		FormedAt:        task.FormedAt,
+		CompletedAt:     task.CompletedAt,
+		AvarageDuration: task.AvarageDuration,
+	}
+
+	if task.Cluster != nil {
+		row.ClusterIndex = &task.Cluster.ClusterIndex
+		row.ClusterName = task.Cluster.Name
+	}
+	if task.Case != nil {
+		row.CaseTitle = task.Case.Title
+	}
+	if task.Solution != nil {
+		row.Solution = *task.Solution
+	}
+	if task.User != nil {
+		row.AssigneeEmail = task.User.Email
+	}
+	for _, label := range task.Labels {
+		row.Labels = append(row.Labels, label.Name)
+	}
+	if task.FormedAt != nil {
+		row.ReactionSeconds = seconds(task.FormedAt.Sub(task.CreatedAt))
+	}
+	// Время выполнения считается от взятия в работу, как DurationTime в статистике кластеров
+	if task.FormedAt != nil && task.CompletedAt != nil {
+		row.ResolutionSeconds = seconds(task.CompletedAt.Sub(*task.FormedAt))
+	}
+
+	return row
+}
+
+func seconds(d time.Duration) *int64 {
+	value := int64(d / time.Second)
+	return &value
+}
+
+type rowEncoder interface {
+	begin() error
+	encode(row Row) error
+	flush() error
+	end() error
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) encode(row Row) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(row.ID, 10),
+		row.Title,
+		row.Description,
+		row.Status,
+		row.Priority,
+		strconv.FormatBool(row.Fire),
+		formatID(row.ClusterID),
+		formatID(row.ClusterIndex),
+		row.ClusterName,
+		formatID(row.CaseID),
+		row.CaseTitle,
+		row.Solution,
+		formatID(row.AssigneeID),
+		row.AssigneeEmail,
+		strings.Join(row.Labels, ";"),
+		row.CreatedAt.Format(time.RFC3339),
+		formatTime(row.FormedAt),
+		formatTime(row.CompletedAt),
+		formatID(row.ReactionSeconds),
+		formatID(row.ResolutionSeconds),
+		strconv.FormatFloat(float64(row.AvarageDuration), 'f', -1, 32),
+	})
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	return e.flush()
+}
+
+// jsonEncoder пишет один JSON-массив, элементы которого выводятся по мере чтения страниц
+type jsonEncoder struct {
+	w       *bufio.Writer
+	written bool
+}
+
+func (e *jsonEncoder) begin() error {
+	_, err := e.w.WriteString("[")
+	return err
+}
+
+func (e *jsonEncoder) encode(row Row) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	separator := "\n"
+	if e.written {
+		separator = ",\n"
+	}
+	e.written = true
+
+	if _, err := e.w.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) flush() error {
+	return nil
+}
+
+func (e *jsonEncoder) end() error {
+	_, err := e.w.WriteString("\n]\n")
+	return err
+}
+
+type ndjsonEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *ndjsonEncoder) begin() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) encode(row Row) error {
+	return json.NewEncoder(e.w).Encode(row)
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) end() error {
+	return nil
+}
+
+func formatID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,293 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagedProvider отдаёт задачи страницами по query.Limit, курсор - индекс следующей задачи
+type pagedProvider struct {
+	tasks   []models.Task
+	queries []models.TaskQuery
+}
+
+func (p *pagedProvider) QueryTasks(_ context.Context, query models.TaskQuery) (models.TaskPage, error) {
+	p.queries = append(p.queries, query)
+
+	start := 0
+	if query.Cursor != "" {
+		start, _ = strconv.Atoi(query.Cursor)
+	}
+	end := min(start+query.Limit, len(p.tasks))
+
+	page := models.TaskPage{Tasks: p.tasks[start:end]}
+	if end < len(p.tasks) {
+		page.NextCursor = strconv.Itoa(end)
+	}
+	return page, nil
+}
+
+func newTestExporter(tasks []models.Task) (*Exporter, *pagedProvider) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	provider := &pagedProvider{tasks: tasks}
+	return New(log, provider), provider
+}
+
+func testTasks(n int) []models.Task {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	formedAt := createdAt.Add(10 * time.Minute)
+	completedAt := formedAt.Add(time.Hour)
+	clusterID, caseID, userID := int64(3), int64(4), int64(5)
+	solution := "перезагрузить"
+
+	tasks := make([]models.Task, 0, n)
+	for i := 1; i <= n; i++ {
+		tasks = append(tasks, models.Task{
+			ID: int64(i), Title: "Задача, \"в кавычках\"", Description: "строка 1\nстрока 2",
+			Status: models.TaskStatusOpen, Priority: models.TaskPriorityHigh, CreatedAt: createdAt,
+		})
+	}
+	if n > 0 {
+		tasks[0].Status = models.TaskStatusClosed
+		tasks[0].Fire = true
+		tasks[0].FormedAt = &formedAt
+		tasks[0].CompletedAt = &completedAt
+		tasks[0].AvarageDuration = 1.5
+		tasks[0].ClusterID, tasks[0].Cluster = &clusterID, &models.Cluster{ID: clusterID, ClusterIndex: 2, Name: "Оплата"}
+		tasks[0].CaseID, tasks[0].Case = &caseID, &models.Case{ID: caseID, Title: "Кейс"}
+		tasks[0].Solution = &solution
+		tasks[0].UserID, tasks[0].User = &userID, &models.User{ID: userID, Email: "agent@example.com"}
+		tasks[0].Labels = []models.Label{{Name: "vip"}, {Name: "sms"}}
+	}
+	return tasks
+}
+
+func TestNewRowDurations(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	formedAt := createdAt.Add(10 * time.Minute)
+	completedAt := formedAt.Add(time.Hour)
+	seconds := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name           string
+		task           models.Task
+		wantReaction   *int64
+		wantResolution *int64
+	}{
+		{name: "open", task: models.Task{CreatedAt: createdAt}},
+		{name: "in progress", task: models.Task{CreatedAt: createdAt, FormedAt: &formedAt}, wantReaction: seconds(600)},
+		{
+			name:           "closed",
+			task:           models.Task{CreatedAt: createdAt, FormedAt: &formedAt, CompletedAt: &completedAt},
+			wantReaction:   seconds(600),
+			wantResolution: seconds(3600),
+		},
+		{name: "dismissed without work", task: models.Task{CreatedAt: createdAt, CompletedAt: &completedAt}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := newRow(tt.task)
+			assert.Equal(t, tt.wantReaction, row.ReactionSeconds)
+			assert.Equal(t, tt.wantResolution, row.ResolutionSeconds)
+		})
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		parse  func(t *testing.T, data []byte) []map[string]string
+	}{
+		{format: FormatCSV, parse: parseCSV},
+		{format: FormatJSON, parse: parseJSON},
+		{format: FormatNDJSON, parse: parseNDJSON},
+	}
+
+	for _, tt := range tests {
+		for _, n := range []int{0, 1, PageSize + 1} {
+			t.Run(string(tt.format)+" "+strconv.Itoa(n), func(t *testing.T) {
+				exporter, provider := newTestExporter(testTasks(n))
+				var out bytes.Buffer
+
+				count, err := exporter.Export(context.Background(), models.TaskQuery{Labels: []string{" VIP "}, Cursor: "5", Limit: 3}, tt.format, &out)
+				require.NoError(t, err)
+				assert.Equal(t, n, count)
+
+				rows := tt.parse(t, out.Bytes())
+				require.Len(t, rows, n)
+				if n == 0 {
+					return
+				}
+
+				first := rows[0]
+				assert.Equal(t, "1", first["id"])
+				assert.Equal(t, "Задача, \"в кавычках\"", first["title"])
+				assert.Equal(t, "строка 1\nстрока 2", first["description"])
+				assert.Equal(t, "closed", first["status"])
+				assert.Equal(t, "high", first["priority"])
+				assert.Equal(t, "true", first["fire"])
+				assert.Equal(t, "2", first["cluster_index"])
+				assert.Equal(t, "Оплата", first["cluster_name"])
+				assert.Equal(t, "Кейс", first["case_title"])
+				assert.Equal(t, "agent@example.com", first["assignee_email"])
+				assert.Equal(t, "600", first["reaction_seconds"])
+				assert.Equal(t, "3600", first["resolution_seconds"])
+				assert.Equal(t, "1.5", first["avarage_duration"])
+				assert.Equal(t, "vip;sms", first["labels"])
+
+				last := rows[len(rows)-1]
+				assert.Equal(t, strconv.Itoa(n), last["id"])
+				if n > 1 {
+					assert.Equal(t, "", last["resolution_seconds"])
+					assert.Equal(t, "", last["labels"])
+				}
+
+				require.NotEmpty(t, provider.queries)
+				assert.Equal(t, "", provider.queries[0].Cursor, "export starts from the beginning")
+				assert.Equal(t, PageSize, provider.queries[0].Limit)
+				assert.Equal(t, []models.TaskSort{{Field: models.TaskSortID}}, provider.queries[0].Sort)
+				assert.Equal(t, []string{"vip"}, provider.queries[0].Labels)
+			})
+		}
+	}
+}
+
+func parseCSV(t *testing.T, data []byte) []map[string]string {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	require.Equal(t, csvHeader, records[0])
+
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(record))
+		for i, value := range record {
+			row[csvHeader[i]] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func parseJSON(t *testing.T, data []byte) []map[string]string {
+	var values []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &values))
+	return stringify(t, values)
+}
+
+func parseNDJSON(t *testing.T, data []byte) []map[string]string {
+	var values []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var value map[string]interface{}
+		require.NoError(t, decoder.Decode(&value))
+		values = append(values, value)
+	}
+	assert.Equal(t, len(values), strings.Count(string(data), "\n"), "one object per line")
+	return stringify(t, values)
+}
+
+// stringify приводит поля JSON к строкам в том виде, в каком их пишет CSV
+func stringify(t *testing.T, values []map[string]interface{}) []map[string]string {
+	rows := make([]map[string]string, 0, len(values))
+	for _, value := range values {
+		row := make(map[string]string, len(value))
+		for key, field := range value {
+			switch field := field.(type) {
+			case nil:
+				row[key] = ""
+			case string:
+				row[key] = field
+			case float64:
+				row[key] = strconv.FormatFloat(field, 'f', -1, 64)
+			case bool:
+				row[key] = strconv.FormatBool(field)
+			case []interface{}:
+				names := make([]string, 0, len(field))
+				for _, name := range field {
+					names = append(names, name.(string))
+				}
+				row[key] = strings.Join(names, ";")
+			default:
+				data, err := json.Marshal(field)
+				require.NoError(t, err)
+				row[key] = string(data)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	exporter, _ := newTestExporter(nil)
+
+	_, err := exporter.Export(context.Background(), models.TaskQuery{}, Format("xml"), io.Discard)
+
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Format
+		wantErr bool
+	}{
+		{value: "csv", want: FormatCSV},
+		{value: " JSON ", want: FormatJSON},
+		{value: "NDJson", want: FormatNDJSON},
+		{value: "xml", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseFormat(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    models.TaskStatus
+		wantErr bool
+	}{
+		{value: "open", want: models.TaskStatusOpen},
+		{value: " In_Progress ", want: models.TaskStatusInProgress},
+		{value: "waiting_for_customer", want: models.TaskStatusWaitingForCustomer},
+		{value: "reopened", want: models.TaskStatusReopened},
+		{value: "done", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseStatus(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidQuery)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultBatchSize столько сообщений читается и проверяется на повторный импорт за раз
+	DefaultBatchSize = 500
+
+	maxTitleLength = 120
+)
+
+var ErrInvalidFormat = errors.New("invalid import file format")
+
+// Message сообщение чата в формате выгрузки Mattermost
+type Message struct {
+	ID        int64   `json:"id"`
+	Message   string  `json:"message"`
+	UserID    int64   `json:"user_id"`
+	ChannelID int64   `json:"channel_id"`
+	CreateAt  int64   `json:"create_at"`
+	RootID    *string `json:"root_id"`
+	DeleteAt  int64   `json:"delete_at"`
+}
+
+// Stats итоги импорта
+type Stats struct {
+	Read    int
+	Tasks   int
+	Replies int
+	// Orphans ответы, корневое сообщение которых не найдено ни в файле, ни среди импортированных;
+	// они не импортируются и попадают в отчёт
+	Orphans    int
+	Skipped    int
+	Deleted    int
+	Clusters   int
+	Requesters int
+}
+
+type Store interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	ImportedMessages(ctx context.Context, sourceIDs []string) (map[string]int64, error)
+	EnsureCluster(ctx context.Context, cluster models.Cluster) (models.Cluster, error)
+	EnsureRequester(ctx context.Context, requester models.Requester) (models.Requester, error)
+	SaveTask(ctx context.Context, task models.Task) (models.Task, error)
+	SaveComment(ctx context.Context, comment models.TaskComment) (models.TaskComment, error)
+	SaveTaskEvents(ctx context.Context, events ...models.TaskEvent) error
+}
+
+// Importer загружает историю сообщений чата в задачи. Повторный запуск пропускает уже импортированные
+// сообщения, поэтому прерванный импорт можно просто запустить снова
+type Importer struct {
+	log       *logrus.Logger
+	store     Store
+	authorID  int64
+	batchSize int
+
+	clusters   map[int64]models.Cluster
+	requesters map[int64]models.Requester
+	// pending ответы, корневое сообщение которых ещё не встретилось; разбираются после чтения всего файла
+	pending []Message
+}
+
+// New создает Importer; ответы в тредах сохраняются комментариями от имени пользователя authorID
+func New(log *logrus.Logger, store Store, authorID int64, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Importer{
+		log:        log,
+		store:      store,
+		authorID:   authorID,
+		batchSize:  batchSize,
+		clusters:   make(map[int64]models.Cluster),
+		requesters: make(map[int64]models.Requester),
+	}
+}
+
+// Import читает сообщения из messages и метки кластеров из clusters, CSV с колонками id и cluster.
+// Ответы без найденного корневого сообщения записываются в orphans, CSV с колонками id и root_id; orphans может быть nil
+func (i *Importer) Import(ctx context.Context, messages, clusters io.Reader, orphans io.Writer) (Stats, error) {
+	const op = "Importer.Import"
+	log := i.log.WithField("op", op)
+
+	var stats Stats
+
+	labels, frequency, err := readClusters(clusters)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+	log.WithField("messages", len(labels)).WithField("clusters", len(frequency)).Info("cluster labels loaded")
+
+	decoder := json.NewDecoder(messages)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return stats, fmt.Errorf("%s: expected array of messages: %w", op, ErrInvalidFormat)
+	}
+
+	started := time.Now()
+	batch := make([]Message, 0, i.batchSize)
+	for decoder.More() {
+		var message Message
+		if err := decoder.Decode(&message); err != nil {
+			return stats, fmt.Errorf("%s: message %d: %w: %v", op, stats.Read+1, ErrInvalidFormat, err)
+		}
+		stats.Read++
+		batch = append(batch, message)
+
+		if len(batch) == i.batchSize {
+			if err := i.importBatch(ctx, batch, labels, frequency, &stats); err != nil {
+				return stats, fmt.Errorf("%s: %w", op, err)
+			}
+			batch = batch[:0]
+			i.progress(log, stats, started)
+		}
+	}
+	if err := i.importBatch(ctx, batch, labels, frequency, &stats); err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := i.importPending(ctx, orphans, &stats); err != nil {
+		return stats, fmt.Errorf("%s: %w", op, err)
+	}
+	i.progress(log, stats, started)
+
+	if stats.Orphans > 0 {
+		log.WithField("orphans", stats.Orphans).Warn("replies without root message were not imported")
+	}
+
+	return stats, nil
+}
+
+func (i *Importer) importBatch(ctx context.Context, batch []Message, labels map[int64]int64, frequency map[int64]int64, stats *Stats) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	sourceIDs := make([]string, 0, len(batch)*2)
+	for _, message := range batch {
+		sourceIDs = append(sourceIDs, sourceID(message.ID))
+		if message.RootID != nil && *message.RootID != "" {
+			sourceIDs = append(sourceIDs, *message.RootID)
+		}
+	}
+
+	known, err := i.store.ImportedMessages(ctx, sourceIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range batch {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		id := sourceID(message.ID)
+		if _, ok := known[id]; ok {
+			stats.Skipped++
+			continue
+		}
+		if message.DeleteAt != 0 || strings.TrimSpace(message.Message) == "" {
+			stats.Deleted++
+			continue
+		}
+
+		if message.RootID != nil && *message.RootID != "" {
+			taskID, ok := known[*message.RootID]
+			if !ok {
+				// Корневое сообщение может идти в файле позже ответа
+				i.pending = append(i.pending, message)
+				continue
+			}
+			if err := i.importReply(ctx, taskID, message); err != nil {
+				return fmt.Errorf("message %s: %w", id, err)
+			}
+			known[id] = taskID
+			stats.Replies++
+			continue
+		}
+
+		clusterIndex, ok := labels[message.ID]
+		if !ok {
+			return fmt.Errorf("message %s has no cluster label: %w", id, ErrInvalidFormat)
+		}
+
+		taskID, err := i.importTask(ctx, clusterIndex, frequency[clusterIndex], message, stats)
+		if err != nil {
+			return fmt.Errorf("message %s: %w", id, err)
+		}
+		known[id] = taskID
+		stats.Tasks++
+	}
+
+	return nil
+}
+
+// importPending импортирует отложенные ответы, корневые сообщения которых нашлись к концу файла,
+// остальные записывает в отчёт orphans
+func (i *Importer) importPending(ctx context.Context, orphans io.Writer, stats *Stats) error {
+	var report *csv.Writer
+	if orphans != nil {
+		report = csv.NewWriter(orphans)
+		if err := report.Write([]string{"id", "root_id"}); err != nil {
+			return fmt.Errorf("orphans report: %w", err)
+		}
+	}
+
+	known := make(map[string]int64)
+	for start := 0; start < len(i.pending); start += i.batchSize {
+		end := min(start+i.batchSize, len(i.pending))
+		batch := i.pending[start:end]
+
+		rootIDs := make([]string, 0, len(batch))
+		for _, message := range batch {
+			if _, ok := known[*message.RootID]; !ok {
+				rootIDs = append(rootIDs, *message.RootID)
+			}
+		}
+		imported, err := i.store.ImportedMessages(ctx, rootIDs)
+		if err != nil {
+			return err
+		}
+		for rootID, taskID := range imported {
+			known[rootID] = taskID
+		}
+
+		for _, message := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			id := sourceID(message.ID)
+			taskID, ok := known[*message.RootID]
+			if !ok {
+				i.log.WithField("id", id).WithField("rootID", *message.RootID).Debug("reply without root message")
+				stats.Orphans++
+				if report != nil {
+					if err := report.Write([]string{id, *message.RootID}); err != nil {
+						return fmt.Errorf("orphans report: %w", err)
+					}
+				}
+				continue
+			}
+
+			if err := i.importReply(ctx, taskID, message); err != nil {
+				return fmt.Errorf("message %s: %w", id, err)
+			}
+			known[id] = taskID
+			stats.Replies++
+		}
+	}
+	i.pending = nil
+
+	if report != nil {
+		report.Flush()
+		if err := report.Error(); err != nil {
+			return fmt.Errorf("orphans report: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (i *Importer) importTask(ctx context.Context, clusterIndex, frequency int64, message Message, stats *Stats) (int64, error) {
+	cluster, ok := i.clusters[clusterIndex]
+	if !ok {
+		var err error
+		cluster, err = i.store.EnsureCluster(ctx, models.Cluster{
+			ClusterIndex: clusterIndex,
+			Name:         "Кластер " + strconv.FormatInt(clusterIndex, 10),
+			Frequency:    frequency,
+		})
+		if err != nil {
+			return 0, err
+		}
+		i.clusters[clusterIndex] = cluster
+		stats.Clusters++
+	}
+
+	requester, ok := i.requesters[message.UserID]
+	if !ok {
+		var err error
+		requester, err = i.store.EnsureRequester(ctx, models.Requester{
+			ExternalID: strconv.FormatInt(message.UserID, 10),
+			Channel:    strconv.FormatInt(message.ChannelID, 10),
+		})
+		if err != nil {
+			return 0, err
+		}
+		i.requesters[message.UserID] = requester
+		stats.Requesters++
+	}
+
+	createdAt := time.UnixMilli(message.CreateAt)
+	id := sourceID(message.ID)
+	task := models.Task{
+		Title:           title(message.Message),
+		Description:     message.Message,
+		Status:          models.TaskStatusClosed,
+		CreatedAt:       createdAt,
+		CompletedAt:     &createdAt,
+		ClusterID:       &cluster.ID,
+		Priority:        models.TaskPriorityNormal,
+		RequesterID:     &requester.ID,
+		RequesterTier:   requester.Tier,
+		SourceMessageID: &id,
+	}
+
+	err := i.store.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		task, err = i.store.SaveTask(ctx, task)
+		if err != nil {
+			return err
+		}
+
+		return i.store.SaveTaskEvents(ctx, models.TaskEvent{
+			TaskID:    task.ID,
+			Kind:      models.TaskEventImported,
+			NewValue:  &id,
+			CreatedAt: createdAt,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return task.ID, nil
+}
+
+func (i *Importer) importReply(ctx context.Context, taskID int64, message Message) error {
+	id := sourceID(message.ID)
+	_, err := i.store.SaveComment(ctx, models.TaskComment{
+		TaskID:          taskID,
+		Body:            message.Message,
+		Visibility:      models.CommentVisibilityCustomer,
+		CreatedAt:       time.UnixMilli(message.CreateAt),
+		AuthorID:        i.authorID,
+		SourceMessageID: &id,
+	})
+	return err
+}
+
+func (i *Importer) progress(log *logrus.Entry, stats Stats, started time.Time) {
+	elapsed := time.Since(started)
+	rate := float64(stats.Read) / elapsed.Seconds()
+	log.WithFields(logrus.Fields{
+		"read":       stats.Read,
+		"tasks":      stats.Tasks,
+		"replies":    stats.Replies,
+		"orphans":    stats.Orphans,
+		"skipped":    stats.Skipped,
+		"deleted":    stats.Deleted,
+		"clusters":   stats.Clusters,
+		"requesters": stats.Requesters,
+		"elapsed":    elapsed.Round(time.Second),
+		"rate":       fmt.Sprintf("%.0f msg/s", rate),
+	}).Info("import progress")
+}
+
+// readClusters читает метки кластеров по id сообщения и количество сообщений в каждом кластере
+func readClusters(r io.Reader) (map[int64]int64, map[int64]int64, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cluster labels header: %w: %v", ErrInvalidFormat, err)
+	}
+	idColumn, clusterColumn := -1, -1
+	for column, name := range header {
+		switch strings.TrimSpace(name) {
+		case "id":
+			idColumn = column
+		case "cluster":
+			clusterColumn = column
+		}
+	}
+	if idColumn < 0 || clusterColumn < 0 {
+		return nil, nil, fmt.Errorf("cluster labels need id and cluster columns: %w", ErrInvalidFormat)
+	}
+
+	labels := make(map[int64]int64)
+	frequency := make(map[int64]int64)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("cluster labels line %d: %w: %v", line, ErrInvalidFormat, err)
+		}
+
+		id, err := strconv.ParseInt(record[idColumn], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cluster labels line %d: invalid id: %w", line, ErrInvalidFormat)
+		}
+		cluster, err := strconv.ParseInt(record[clusterColumn], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cluster labels line %d: invalid cluster: %w", line, ErrInvalidFormat)
+		}
+
+		labels[id] = cluster
+		frequency[cluster]++
+	}
+
+	return labels, frequency, nil
+}
+
+func sourceID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// title первая строка сообщения, обрезанная до maxTitleLength символов
+func title(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	line = strings.TrimSpace(line)
+	if utf8.RuneCountInString(line) <= maxTitleLength {
+		return line
+	}
+	return string([]rune(line)[:maxTitleLength-1]) + "…"
+}
@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	tasks      []models.Task
+	comments   []models.TaskComment
+	events     []models.TaskEvent
+	clusters   map[int64]models.Cluster
+	requesters map[string]models.Requester
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		clusters:   make(map[int64]models.Cluster),
+		requesters: make(map[string]models.Requester),
+	}
+}
+
+func (s *memoryStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *memoryStore) ImportedMessages(_ context.Context, sourceIDs []string) (map[string]int64, error) {
+	imported := make(map[string]int64)
+	for _, id := range sourceIDs {
+		for _, task := range s.tasks {
+			if *task.SourceMessageID == id {
+				imported[id] = task.ID
+			}
+		}
+		for _, comment := range s.comments {
+			if *comment.SourceMessageID == id {
+				imported[id] = comment.TaskID
+			}
+		}
+	}
+	return imported, nil
+}
+
+func (s *memoryStore) EnsureCluster(_ context.Context, cluster models.Cluster) (models.Cluster, error) {
+	if existing, ok := s.clusters[cluster.ClusterIndex]; ok {
+		return existing, nil
+	}
+	cluster.ID = int64(len(s.clusters) + 1)
+	s.clusters[cluster.ClusterIndex] = cluster
+	return cluster, nil
+}
+
+func (s *memoryStore) EnsureRequester(_ context.Context, requester models.Requester) (models.Requester, error) {
+	if existing, ok := s.requesters[requester.ExternalID]; ok {
+		return existing, nil
+	}
+	requester.ID = int64(len(s.requesters) + 1)
+	s.requesters[requester.ExternalID] = requester
+	return requester, nil
+}
+
+func (s *memoryStore) SaveTask(_ context.Context, task models.Task) (models.Task, error) {
+	task.ID = int64(len(s.tasks) + 1)
+	s.tasks = append(s.tasks, task)
+	return task, nil
+}
+
+func (s *memoryStore) SaveComment(_ context.Context, comment models.TaskComment) (models.TaskComment, error) {
+	comment.ID = int64(len(s.comments) + 1)
+	s.comments = append(s.comments, comment)
+	return comment, nil
+}
+
+func (s *memoryStore) SaveTaskEvents(_ context.Context, events ...models.TaskEvent) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func newTestImporter(store Store, batchSize int) *Importer {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return New(log, store, 99, batchSize)
+}
+
+const testClusters = "id,message,cluster\n1,a,3\n2,b,3\n4,d,5\n5,e,5\n6,f,3\n"
+
+// testMessages: 3 - ответ на 1, 7 - ответ на 6, который идёт в файле позже, 8 - ответ на ответ 3,
+// 9 - ответ на неизвестное сообщение, 5 - удалённое сообщение
+const testMessages = `[
+	{"id": 1, "message": "Не приходит СМС\nподробности", "user_id": 10, "channel_id": 100, "create_at": 1693346597662, "root_id": null},
+	{"id": 2, "message": "Не работает оплата", "user_id": 11, "channel_id": 100, "create_at": 1693346600000, "root_id": ""},
+	{"id": 3, "message": "Уже пришло", "user_id": 10, "channel_id": 100, "create_at": 1693346700000, "root_id": "1"},
+	{"id": 7, "message": "Спасибо", "user_id": 12, "channel_id": 101, "create_at": 1693346800000, "root_id": "6"},
+	{"id": 4, "message": "Вопрос по возврату", "user_id": 10, "channel_id": 101, "create_at": 1693346900000, "root_id": null},
+	{"id": 5, "message": "удалено", "user_id": 10, "channel_id": 101, "create_at": 1693346950000, "root_id": null, "delete_at": 1693347000000},
+	{"id": 6, "message": "Где заказ?", "user_id": 12, "channel_id": 101, "create_at": 1693347000000, "root_id": null},
+	{"id": 8, "message": "И ещё вопрос", "user_id": 10, "channel_id": 100, "create_at": 1693347100000, "root_id": "3"},
+	{"id": 9, "message": "Ответ без начала", "user_id": 13, "channel_id": 102, "create_at": 1693347200000, "root_id": "dsyhsescphovx9o0jktmy0zt"}
+]`
+
+func TestImport(t *testing.T) {
+	for _, batchSize := range []int{1, 2, 100} {
+		t.Run("batch "+strconv.Itoa(batchSize), func(t *testing.T) {
+			store := newMemoryStore()
+			var orphans bytes.Buffer
+
+			stats, err := newTestImporter(store, batchSize).Import(context.Background(), strings.NewReader(testMessages), strings.NewReader(testClusters), &orphans)
+			require.NoError(t, err)
+
+			assert.Equal(t, Stats{Read: 9, Tasks: 4, Replies: 3, Orphans: 1, Deleted: 1, Clusters: 2, Requesters: 3}, stats)
+			assert.Equal(t, "id,root_id\n9,dsyhsescphovx9o0jktmy0zt\n", orphans.String())
+
+			taskBySource := make(map[string]models.Task)
+			for _, task := range store.tasks {
+				taskBySource[*task.SourceMessageID] = task
+			}
+			require.Len(t, taskBySource, 4)
+
+			first := taskBySource["1"]
+			assert.Equal(t, "Не приходит СМС", first.Title)
+			assert.Equal(t, models.TaskStatusClosed, first.Status)
+			assert.Equal(t, time.UnixMilli(1693346597662), first.CreatedAt)
+			assert.Equal(t, store.clusters[3].ID, *first.ClusterID)
+
+			replyTasks := make(map[string]int64)
+			for _, comment := range store.comments {
+				replyTasks[*comment.SourceMessageID] = comment.TaskID
+				assert.Equal(t, int64(99), comment.AuthorID)
+				assert.Equal(t, models.CommentVisibilityCustomer, comment.Visibility)
+			}
+			assert.Equal(t, map[string]int64{"3": first.ID, "7": taskBySource["6"].ID, "8": first.ID}, replyTasks)
+		})
+	}
+}
+
+func TestImportRerunSkipsImported(t *testing.T) {
+	store := newMemoryStore()
+	importer := newTestImporter(store, 3)
+	_, err := importer.Import(context.Background(), strings.NewReader(testMessages), strings.NewReader(testClusters), nil)
+	require.NoError(t, err)
+
+	stats, err := newTestImporter(store, 3).Import(context.Background(), strings.NewReader(testMessages), strings.NewReader(testClusters), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Stats{Read: 9, Skipped: 7, Orphans: 1, Deleted: 1}, stats)
+	assert.Len(t, store.tasks, 4)
+	assert.Len(t, store.comments, 3)
+}
+
+func TestImportInvalidInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages string
+		clusters string
+	}{
+		{name: "not an array", messages: `{"id": 1}`, clusters: testClusters},
+		{name: "broken message", messages: `[{"id": "one"}]`, clusters: testClusters},
+		{name: "message without cluster label", messages: `[{"id": 42, "message": "text", "root_id": null}]`, clusters: testClusters},
+		{name: "clusters without columns", messages: `[]`, clusters: "id,label\n1,2\n"},
+		{name: "invalid cluster", messages: `[]`, clusters: "id,cluster\n1,x\n"},
+		{name: "empty clusters", messages: `[]`, clusters: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestImporter(newMemoryStore(), 0).Import(context.Background(), strings.NewReader(tt.messages), strings.NewReader(tt.clusters), nil)
+			assert.ErrorIs(t, err, ErrInvalidFormat)
+		})
+	}
+}
+
+func TestReadClusters(t *testing.T) {
+	labels, frequency, err := readClusters(strings.NewReader("cluster, id\n3,1\n3,2\n5,4\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[int64]int64{1: 3, 2: 3, 4: 5}, labels)
+	assert.Equal(t, map[int64]int64{3: 2, 5: 1}, frequency)
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "single line", message: "  Не приходит СМС  ", want: "Не приходит СМС"},
+		{name: "first line", message: "Заголовок\nтекст", want: "Заголовок"},
+		{name: "exact length", message: strings.Repeat("я", maxTitleLength), want: strings.Repeat("я", maxTitleLength)},
+		{name: "truncated by runes", message: strings.Repeat("я", maxTitleLength+1), want: strings.Repeat("я", maxTitleLength-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, title(tt.message))
+		})
+	}
+}
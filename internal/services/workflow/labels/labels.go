@@ -0,0 +1,210 @@
+package labels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultColor цвет меток, созданных при добавлении к задаче без каталога
+const DefaultColor = "#9e9e9e"
+
+var (
+	ErrInvalidName   = errors.New("invalid label name")
+	ErrInvalidColor  = errors.New("invalid label color")
+	ErrLabelNotFound = errors.New("label not found")
+	ErrLabelExists   = errors.New("label already exists")
+)
+
+var (
+	namePattern  = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_\-]{0,63}$`)
+	colorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+)
+
+type LabelService struct {
+	log        *logrus.Logger
+	labelStore LabelStore
+}
+
+type LabelStore interface {
+	SaveLabel(ctx context.Context, label models.Label) (models.Label, error)
+	UpdateLabel(ctx context.Context, label models.Label) error
+	DeleteLabel(ctx context.Context, labelID int64) error
+	LabelByID(ctx context.Context, labelID int64) (models.Label, error)
+	ListLabels(ctx context.Context) ([]models.Label, error)
+	TaskCountsByLabel(ctx context.Context, q models.TaskQuery) ([]models.LabelCount, error)
+}
+
+func New(log *logrus.Logger, labelStore LabelStore) *LabelService {
+	return &LabelService{
+		log:        log,
+		labelStore: labelStore,
+	}
+}
+
+func (s *LabelService) CreateLabel(ctx context.Context, name, color, description string) (models.Label, error) {
+	const op = "LabelService.CreateLabel"
+	log := s.log.WithField("op", op)
+
+	label, err := validate(models.Label{Name: name, Color: color, Description: description})
+	if err != nil {
+		return models.Label{}, err
+	}
+
+	log.WithField("name", label.Name).Info("create label")
+	label, err = s.labelStore.SaveLabel(ctx, label)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrLabelExists) {
+			log.Warn("label already exists", err)
+			return models.Label{}, ErrLabelExists
+		}
+
+		log.WithError(err).Error("failed to save label")
+		return models.Label{}, err
+	}
+
+	return label, nil
+}
+
+func (s *LabelService) UpdateLabel(ctx context.Context, labelID int64, name, color, description string) (models.Label, error) {
+	const op = "LabelService.UpdateLabel"
+	log := s.log.WithField("op", op).WithField("labelID", labelID)
+
+	label, err := s.labelStore.LabelByID(ctx, labelID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrLabelNotFound) {
+			log.Warn("label not found", err)
+			return models.Label{}, ErrLabelNotFound
+		}
+
+		log.WithError(err).Error("failed to get label")
+		return models.Label{}, err
+	}
+
+	label.Name, label.Color, label.Description = name, color, description
+	label, err = validate(label)
+	if err != nil {
+		return models.Label{}, err
+	}
+
+	log.Info("update label")
+	if err := s.labelStore.UpdateLabel(ctx, label); err != nil {
+		if errors.Is(err, postgresql.ErrLabelExists) {
+			log.Warn("label already exists", err)
+			return models.Label{}, ErrLabelExists
+		}
+
+		log.WithError(err).Error("failed to update label")
+		return models.Label{}, err
+	}
+
+	return label, nil
+}
+
+func (s *LabelService) DeleteLabel(ctx context.Context, labelID int64) error {
+	const op = "LabelService.DeleteLabel"
+	log := s.log.WithField("op", op).WithField("labelID", labelID)
+
+	log.Info("delete label")
+	if err := s.labelStore.DeleteLabel(ctx, labelID); err != nil {
+		if errors.Is(err, postgresql.ErrLabelNotFound) {
+			log.Warn("label not found", err)
+			return ErrLabelNotFound
+		}
+
+		log.WithError(err).Error("failed to delete label")
+		return err
+	}
+
+	return nil
+}
+
+func (s *LabelService) ListLabels(ctx context.Context) ([]models.Label, error) {
+	const op = "LabelService.ListLabels"
+	log := s.log.WithField("op", op)
+
+	labels, err := s.labelStore.ListLabels(ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to list labels")
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// LabelStats возвращает количество задач по меткам среди задач, подходящих под фильтры query,
+// по убыванию общего количества
+func (s *LabelService) LabelStats(ctx context.Context, query models.TaskQuery) ([]models.LabelCount, error) {
+	const op = "LabelService.LabelStats"
+	log := s.log.WithField("op", op)
+
+	query.Labels = NormalizeNames(query.Labels)
+	counts, err := s.labelStore.TaskCountsByLabel(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("failed to count tasks by label")
+		return nil, err
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Total != counts[j].Total {
+			return counts[i].Total > counts[j].Total
+		}
+		return counts[i].Label.Name < counts[j].Label.Name
+	})
+
+	return counts, nil
+}
+
+// NormalizeNames приводит имена меток к нижнему регистру и убирает пустые и повторяющиеся
+func NormalizeNames(names []string) []string {
+	seen := make(map[string]struct{}, len(names))
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = NormalizeName(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// ValidateName проверяет нормализованное имя метки
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+func validate(label models.Label) (models.Label, error) {
+	label.Name = NormalizeName(label.Name)
+	if err := ValidateName(label.Name); err != nil {
+		return models.Label{}, err
+	}
+
+	label.Color = strings.ToLower(strings.TrimSpace(label.Color))
+	if label.Color == "" {
+		label.Color = DefaultColor
+	}
+	if !colorPattern.MatchString(label.Color) {
+		return models.Label{}, fmt.Errorf("%w: %q", ErrInvalidColor, label.Color)
+	}
+
+	label.Description = strings.TrimSpace(label.Description)
+	return label, nil
+}
@@ -0,0 +1,108 @@
+package sla
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type TaskService interface {
+	QueryTasks(ctx context.Context, query models.TaskQuery) (models.TaskPage, error)
+	FireTaskWithReason(ctx context.Context, taskID int64, reason string) (models.Task, error)
+}
+
+// Evaluator периодически проверяет активные задачи и поджигает нарушившие SLA
+type Evaluator struct {
+	log            *logrus.Logger
+	policy         *Policy
+	taskService    TaskService
+	interval       time.Duration
+	historicalFile string
+}
+
+// New создает Evaluator; historicalFile пустой, если цели не выводятся из медиан
+func New(log *logrus.Logger, policy *Policy, taskService TaskService, interval time.Duration, historicalFile string) *Evaluator {
+	return &Evaluator{
+		log:            log,
+		policy:         policy,
+		taskService:    taskService,
+		interval:       interval,
+		historicalFile: historicalFile,
+	}
+}
+
+func (e *Evaluator) Run(ctx context.Context) {
+	const op = "sla.Evaluator.Run"
+	log := e.log.WithField("op", op)
+
+	log.WithField("interval", e.interval).Info("sla evaluator started")
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("sla evaluator stopped")
+			return
+		case <-ticker.C:
+			if err := e.Evaluate(ctx, time.Now()); err != nil {
+				log.WithError(err).Error("failed to evaluate sla")
+			}
+		}
+	}
+}
+
+func (e *Evaluator) Evaluate(ctx context.Context, now time.Time) error {
+	const op = "sla.Evaluator.Evaluate"
+	log := e.log.WithField("op", op)
+
+	if e.historicalFile != "" {
+		if err := e.policy.LoadHistorical(e.historicalFile); err != nil {
+			log.WithError(err).Warn("failed to load historical sla targets")
+		}
+	}
+
+	notFired := false
+	query := models.TaskQuery{
+		Statuses: activeStatuses,
+		Fire:     &notFired,
+		Limit:    100,
+	}
+
+	for {
+		page, err := e.taskService.QueryTasks(ctx, query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, task := range page.Tasks {
+			status := e.policy.Evaluate(task, now)
+			if status == nil || status.Level != models.SLALevelBreached {
+				continue
+			}
+
+			reason := fmt.Sprintf("sla %s time breached at %s", status.Kind, status.Deadline.Format(time.RFC3339))
+			if _, err := e.taskService.FireTaskWithReason(ctx, task.ID, reason); err != nil {
+				log.WithError(err).WithField("taskID", task.ID).Error("failed to fire task")
+				continue
+			}
+			log.WithField("taskID", task.ID).Info(reason)
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+var activeStatuses = []models.TaskStatus{
+	models.TaskStatusOpen,
+	models.TaskStatusReopened,
+	models.TaskStatusInProgress,
+	models.TaskStatusOnHold,
+	models.TaskStatusWaitingForCustomer,
+}
@@ -0,0 +1,130 @@
+package sla
+
+import (
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/pkg/dataprocessing"
+	"sync"
+	"time"
+)
+
+// Policy хранит цели SLA по кластерам и вычисляет состояние SLA задач
+type Policy struct {
+	mu          sync.RWMutex
+	defaults    config.SLATarget
+	configured  config.SLAClusterTargets
+	historical  map[int64]config.SLATarget
+	atRiskRatio float64
+	factor      float64
+}
+
+func NewPolicy(cfg config.SLAConfig) *Policy {
+	return &Policy{
+		defaults: config.SLATarget{
+			Reaction:   cfg.ReactionTime,
+			Resolution: cfg.ResolutionTime,
+		},
+		configured:  cfg.ClusterTargets,
+		historical:  map[int64]config.SLATarget{},
+		atRiskRatio: cfg.AtRiskRatio,
+		factor:      cfg.HistoricalFactor,
+	}
+}
+
+// LoadHistorical выводит цели из медиан времени реакции и выполнения по кластерам,
+// явно заданные цели имеют приоритет
+func (p *Policy) LoadHistorical(outputFile string) error {
+	medians, err := dataprocessing.ReadClusterMedians(outputFile)
+	if err != nil {
+		return err
+	}
+
+	historical := make(map[int64]config.SLATarget, len(medians))
+	for clusterIndex, m := range medians {
+		historical[int64(clusterIndex)] = config.SLATarget{
+			Reaction:   time.Duration(m.MedianReaction * p.factor * float64(time.Second)),
+			Resolution: time.Duration(m.MedianDuration * p.factor * float64(time.Second)),
+		}
+	}
+
+	p.mu.Lock()
+	p.historical = historical
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *Policy) Target(clusterIndex int64) config.SLATarget {
+	if target, ok := p.configured[clusterIndex]; ok {
+		return target
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if target, ok := p.historical[clusterIndex]; ok && target.Reaction > 0 && target.Resolution > 0 {
+		return target
+	}
+
+	return p.defaults
+}
+
+// Evaluate возвращает состояние SLA задачи или nil для закрытых и отмененных задач
+func (p *Policy) Evaluate(task models.Task, now time.Time) *models.SLAStatus {
+	var clusterIndex int64
+	if task.Cluster != nil {
+		clusterIndex = task.Cluster.ClusterIndex
+	}
+	target := p.Target(clusterIndex)
+
+	var (
+		kind  models.SLAKind
+		start time.Time
+		limit time.Duration
+	)
+	switch task.Status {
+	case models.TaskStatusOpen, models.TaskStatusReopened:
+		kind, start, limit = models.SLAKindReaction, task.CreatedAt, target.Reaction
+	case models.TaskStatusInProgress, models.TaskStatusOnHold, models.TaskStatusWaitingForCustomer:
+		kind, start, limit = models.SLAKindResolution, task.CreatedAt, target.Resolution
+		if task.FormedAt != nil {
+			start = *task.FormedAt
+		}
+	default:
+		return nil
+	}
+
+	// паузы в ожидании клиента и в отложенных задачах не считаются в SLA выполнения
+	var paused time.Duration
+	if kind == models.SLAKindResolution {
+		paused = pausedDuration(task, now)
+	}
+
+	deadline := start.Add(limit + paused)
+	remaining := deadline.Sub(now)
+	elapsed := now.Sub(start) - paused
+
+	level := models.SLALevelOK
+	switch {
+	case remaining <= 0:
+		level = models.SLALevelBreached
+	case elapsed >= time.Duration(float64(limit)*p.atRiskRatio):
+		level = models.SLALevelAtRisk
+	}
+
+	return &models.SLAStatus{
+		Level:     level,
+		Kind:      kind,
+		Deadline:  deadline,
+		Remaining: remaining,
+	}
+}
+
+// pausedDuration время, проведённое задачей на паузе к моменту now, включая текущую паузу
+func pausedDuration(task models.Task, now time.Time) time.Duration {
+	paused := task.PausedDuration
+	if task.PausedAt != nil && now.After(*task.PausedAt) {
+		paused += now.Sub(*task.PausedAt)
+	}
+	return paused
+}
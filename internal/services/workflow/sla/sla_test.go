@@ -0,0 +1,144 @@
+package sla
+
+import (
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/config"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) *time.Time {
+		t := now.Add(-ago)
+		return &t
+	}
+
+	policy := NewPolicy(config.SLAConfig{
+		ReactionTime:   30 * time.Minute,
+		ResolutionTime: 4 * time.Hour,
+		AtRiskRatio:    0.8,
+		ClusterTargets: config.SLAClusterTargets{2: {Reaction: 10 * time.Minute, Resolution: time.Hour}},
+	})
+
+	tests := []struct {
+		name         string
+		task         models.Task
+		wantNil      bool
+		wantKind     models.SLAKind
+		wantLevel    models.SLALevel
+		wantDeadline time.Time
+	}{
+		{
+			name:         "fresh open task",
+			task:         models.Task{Status: models.TaskStatusOpen, CreatedAt: *at(5 * time.Minute)},
+			wantKind:     models.SLAKindReaction,
+			wantLevel:    models.SLALevelOK,
+			wantDeadline: now.Add(25 * time.Minute),
+		},
+		{
+			name:         "open task at risk",
+			task:         models.Task{Status: models.TaskStatusOpen, CreatedAt: *at(25 * time.Minute)},
+			wantKind:     models.SLAKindReaction,
+			wantLevel:    models.SLALevelAtRisk,
+			wantDeadline: now.Add(5 * time.Minute),
+		},
+		{
+			name:         "reopened task breached",
+			task:         models.Task{Status: models.TaskStatusReopened, CreatedAt: *at(time.Hour)},
+			wantKind:     models.SLAKindReaction,
+			wantLevel:    models.SLALevelBreached,
+			wantDeadline: now.Add(-30 * time.Minute),
+		},
+		{
+			name:         "cluster target",
+			task:         models.Task{Status: models.TaskStatusOpen, CreatedAt: *at(15 * time.Minute), Cluster: &models.Cluster{ClusterIndex: 2}},
+			wantKind:     models.SLAKindReaction,
+			wantLevel:    models.SLALevelBreached,
+			wantDeadline: now.Add(-5 * time.Minute),
+		},
+		{
+			name:         "resolution counts from formed at",
+			task:         models.Task{Status: models.TaskStatusInProgress, CreatedAt: *at(10 * time.Hour), FormedAt: at(time.Hour)},
+			wantKind:     models.SLAKindResolution,
+			wantLevel:    models.SLALevelOK,
+			wantDeadline: now.Add(3 * time.Hour),
+		},
+		{
+			name: "finished pauses extend deadline",
+			task: models.Task{
+				Status: models.TaskStatusInProgress, CreatedAt: *at(6 * time.Hour), FormedAt: at(5 * time.Hour),
+				PausedDuration: 2 * time.Hour,
+			},
+			wantKind:     models.SLAKindResolution,
+			wantLevel:    models.SLALevelOK,
+			wantDeadline: now.Add(time.Hour),
+		},
+		{
+			name: "current pause extends deadline",
+			task: models.Task{
+				Status: models.TaskStatusWaitingForCustomer, CreatedAt: *at(6 * time.Hour), FormedAt: at(5 * time.Hour),
+				PausedAt: at(90 * time.Minute), PausedDuration: 30 * time.Minute,
+			},
+			wantKind:     models.SLAKindResolution,
+			wantLevel:    models.SLALevelOK,
+			wantDeadline: now.Add(time.Hour),
+		},
+		{
+			name: "at risk excludes pauses",
+			task: models.Task{
+				Status: models.TaskStatusOnHold, CreatedAt: *at(6 * time.Hour), FormedAt: at(5 * time.Hour),
+				PausedAt: at(time.Hour + 30*time.Minute),
+			},
+			wantKind:     models.SLAKindResolution,
+			wantLevel:    models.SLALevelAtRisk,
+			wantDeadline: now.Add(30 * time.Minute),
+		},
+		{
+			name: "breached without pauses",
+			task: models.Task{
+				Status: models.TaskStatusInProgress, CreatedAt: *at(6 * time.Hour), FormedAt: at(5 * time.Hour),
+			},
+			wantKind:     models.SLAKindResolution,
+			wantLevel:    models.SLALevelBreached,
+			wantDeadline: now.Add(-time.Hour),
+		},
+		{
+			name:    "closed task",
+			task:    models.Task{Status: models.TaskStatusClosed, CreatedAt: *at(time.Hour)},
+			wantNil: true,
+		},
+		{
+			name:    "cancelled task",
+			task:    models.Task{Status: models.TaskStatusCancelled, CreatedAt: *at(time.Hour)},
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := policy.Evaluate(tt.task, now)
+			if tt.wantNil {
+				assert.Nil(t, status)
+				return
+			}
+
+			require.NotNil(t, status)
+			assert.Equal(t, tt.wantKind, status.Kind)
+			assert.Equal(t, tt.wantLevel, status.Level)
+			assert.Equal(t, tt.wantDeadline, status.Deadline)
+			assert.Equal(t, tt.wantDeadline.Sub(now), status.Remaining)
+		})
+	}
+}
+
+func TestPausedDurationIgnoresFuturePause(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	pausedAt := now.Add(time.Minute)
+
+	paused := pausedDuration(models.Task{PausedAt: &pausedAt, PausedDuration: time.Hour}, now)
+
+	assert.Equal(t, time.Hour, paused)
+}
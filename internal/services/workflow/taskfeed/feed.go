@@ -0,0 +1,219 @@
+package taskfeed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHistorySize = 1024
+	subscriberBuffer   = 64
+)
+
+var (
+	ErrSequenceExpired    = errors.New("sequence is no longer available")
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+)
+
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventClosed  EventType = "closed"
+	EventFired   EventType = "fired"
+)
+
+type Event struct {
+	// Epoch идентификатор ленты; меняется при перезапуске процесса, когда нумерация Seq начинается заново
+	Epoch string
+	Seq   uint64
+	Type  EventType
+	Task  models.Task
+	// PreviousUserID исполнитель до изменения, если изменение сменило или сняло исполнителя
+	PreviousUserID *int64
+	At             time.Time
+}
+
+// ResumeToken позиция события в ленте, с которой подписку можно продолжить
+func (e Event) ResumeToken() string {
+	return e.Epoch + "-" + strconv.FormatUint(e.Seq, 10)
+}
+
+func parseResumeToken(token string) (string, uint64, error) {
+	epoch, seq, ok := strings.Cut(token, "-")
+	if !ok || epoch == "" {
+		return "", 0, ErrInvalidResumeToken
+	}
+
+	value, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidResumeToken
+	}
+
+	return epoch, value, nil
+}
+
+// Filter ограничивает подписку кластером и/или исполнителем, пустой фильтр пропускает все события.
+// Подписчику по исполнителю приходят и события о снятии задачи с него
+type Filter struct {
+	ClusterID *int64
+	UserID    *int64
+}
+
+func (f Filter) Match(e Event) bool {
+	if f.ClusterID != nil && (e.Task.ClusterID == nil || *e.Task.ClusterID != *f.ClusterID) {
+		return false
+	}
+	if f.UserID != nil && !sameUser(e.Task.UserID, *f.UserID) && !sameUser(e.PreviousUserID, *f.UserID) {
+		return false
+	}
+	return true
+}
+
+func sameUser(id *int64, userID int64) bool {
+	return id != nil && *id == userID
+}
+
+type subscriber struct {
+	filter Filter
+	events chan Event
+}
+
+// Feed рассылает события задач подписчикам внутри процесса и хранит последние события для переподключения
+type Feed struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	history     []Event
+	historySize int
+	subscribers map[*subscriber]struct{}
+}
+
+func New(historySize int) *Feed {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+
+	return &Feed{
+		epoch:       newEpoch(),
+		historySize: historySize,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func newEpoch() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Publish рассылает событие; previousUserID - исполнитель до изменения, если оно сменило исполнителя
+func (f *Feed) Publish(eventType EventType, task models.Task, previousUserID *int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	event := Event{
+		Epoch:          f.epoch,
+		Seq:            f.seq,
+		Type:           eventType,
+		Task:           task,
+		PreviousUserID: previousUserID,
+		At:             time.Now(),
+	}
+
+	f.history = append(f.history, event)
+	if len(f.history) > f.historySize {
+		f.history = f.history[len(f.history)-f.historySize:]
+	}
+
+	for sub := range f.subscribers {
+		if !sub.filter.Match(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			// Медленный подписчик отключается и переподключается с токеном последнего полученного события
+			delete(f.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe возвращает события после события с токеном resumeToken, затем новые события до отмены ctx.
+// Пустой токен означает только новые события. Токен другой эпохи или вытесненного из истории события
+// даёт ErrSequenceExpired: клиенту нужно перечитать состояние задач
+func (f *Feed) Subscribe(ctx context.Context, filter Filter, resumeToken string) (<-chan Event, error) {
+	var (
+		epoch   string
+		fromSeq uint64
+	)
+	if resumeToken != "" {
+		var err error
+		epoch, fromSeq, err = parseResumeToken(resumeToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+
+	if resumeToken != "" && (epoch != f.epoch || fromSeq > f.seq) {
+		f.mu.Unlock()
+		return nil, ErrSequenceExpired
+	}
+
+	var replay []Event
+	if fromSeq > 0 && fromSeq < f.seq {
+		if len(f.history) == 0 || f.history[0].Seq > fromSeq+1 {
+			f.mu.Unlock()
+			return nil, ErrSequenceExpired
+		}
+
+		for _, event := range f.history {
+			if event.Seq > fromSeq && filter.Match(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &subscriber{
+		filter: filter,
+		events: make(chan Event, len(replay)+subscriberBuffer),
+	}
+	for _, event := range replay {
+		sub.events <- event
+	}
+	f.subscribers[sub] = struct{}{}
+
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.unsubscribe(sub)
+	}()
+
+	return sub.events, nil
+}
+
+func (f *Feed) unsubscribe(sub *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,141 @@
+package taskfeed
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseResumeToken(t *testing.T) {
+	tests := []struct {
+		token     string
+		wantEpoch string
+		wantSeq   uint64
+		wantErr   bool
+	}{
+		{token: "abc-15", wantEpoch: "abc", wantSeq: 15},
+		{token: "abc-0", wantEpoch: "abc", wantSeq: 0},
+		{token: "abc", wantErr: true},
+		{token: "-15", wantErr: true},
+		{token: "abc-", wantErr: true},
+		{token: "abc--1", wantErr: true},
+		{token: "abc-x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			epoch, seq, err := parseResumeToken(tt.token)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidResumeToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEpoch, epoch)
+			assert.Equal(t, tt.wantSeq, seq)
+		})
+	}
+}
+
+func TestEventResumeTokenRoundTrip(t *testing.T) {
+	event := Event{Epoch: "0123abcd", Seq: 42}
+
+	epoch, seq, err := parseResumeToken(event.ResumeToken())
+	require.NoError(t, err)
+	assert.Equal(t, event.Epoch, epoch)
+	assert.Equal(t, event.Seq, seq)
+}
+
+func TestFilterMatch(t *testing.T) {
+	clusterID, otherClusterID := int64(1), int64(2)
+	userID, otherUserID := int64(10), int64(20)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{name: "empty filter", event: Event{Task: models.Task{}}, want: true},
+		{name: "same cluster", filter: Filter{ClusterID: &clusterID}, event: Event{Task: models.Task{ClusterID: &clusterID}}, want: true},
+		{name: "other cluster", filter: Filter{ClusterID: &clusterID}, event: Event{Task: models.Task{ClusterID: &otherClusterID}}},
+		{name: "no cluster", filter: Filter{ClusterID: &clusterID}, event: Event{Task: models.Task{}}},
+		{name: "assignee", filter: Filter{UserID: &userID}, event: Event{Task: models.Task{UserID: &userID}}, want: true},
+		{name: "previous assignee", filter: Filter{UserID: &userID}, event: Event{Task: models.Task{UserID: &otherUserID}, PreviousUserID: &userID}, want: true},
+		{name: "other assignee", filter: Filter{UserID: &userID}, event: Event{Task: models.Task{UserID: &otherUserID}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(tt.event))
+		})
+	}
+}
+
+func TestSubscribeResume(t *testing.T) {
+	feed := New(3)
+	for id := int64(1); id <= 5; id++ {
+		feed.Publish(EventUpdated, models.Task{ID: id}, nil)
+	}
+	token := func(seq uint64) string {
+		return Event{Epoch: feed.epoch, Seq: seq}.ResumeToken()
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantIDs []int64
+		wantErr error
+	}{
+		{name: "only new events", token: ""},
+		{name: "replay after token", token: token(3), wantIDs: []int64{4, 5}},
+		{name: "oldest kept event", token: token(2), wantIDs: []int64{3, 4, 5}},
+		{name: "latest event", token: token(5)},
+		{name: "evicted from history", token: token(1), wantErr: ErrSequenceExpired},
+		{name: "ahead of feed", token: token(6), wantErr: ErrSequenceExpired},
+		{name: "other epoch", token: Event{Epoch: "restarted", Seq: 4}.ResumeToken(), wantErr: ErrSequenceExpired},
+		{name: "malformed", token: "garbage", wantErr: ErrInvalidResumeToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			events, err := feed.Subscribe(ctx, Filter{}, tt.token)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var ids []int64
+			for len(events) > 0 {
+				ids = append(ids, (<-events).Task.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestPublishDeliversToMatchingSubscribers(t *testing.T) {
+	feed := New(0)
+	clusterID, otherClusterID := int64(1), int64(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := feed.Subscribe(ctx, Filter{ClusterID: &clusterID}, "")
+	require.NoError(t, err)
+
+	feed.Publish(EventCreated, models.Task{ID: 1, ClusterID: &otherClusterID}, nil)
+	feed.Publish(EventCreated, models.Task{ID: 2, ClusterID: &clusterID}, nil)
+
+	event := <-events
+	assert.Equal(t, int64(2), event.Task.ID)
+	assert.Equal(t, uint64(2), event.Seq)
+	assert.Equal(t, EventCreated, event.Type)
+
+	cancel()
+	_, open := <-events
+	assert.False(t, open, "channel must be closed after the context is cancelled")
+}
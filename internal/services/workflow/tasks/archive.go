@@ -0,0 +1,119 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"time"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+// archivableStatuses статусы завершённых задач, которые переносятся в архив
+var archivableStatuses = []models.TaskStatus{
+	models.TaskStatusClosed,
+	models.TaskStatusCancelled,
+}
+
+type TaskArchive interface {
+	ArchivableTaskIDs(ctx context.Context, statuses []models.TaskStatus, completedBefore time.Time, limit int) ([]int64, error)
+	ArchiveTask(ctx context.Context, taskID int64, statuses []models.TaskStatus) error
+	ArchivedTaskByID(ctx context.Context, taskID int64) (models.Task, error)
+	RestoreTask(ctx context.Context, taskID int64) (models.Task, error)
+}
+
+// ArchiveClosedTasks переносит в архив до limit задач, завершённых раньше completedBefore,
+// и возвращает количество найденных и заархивированных задач
+func (s *TaskService) ArchiveClosedTasks(ctx context.Context, completedBefore time.Time, limit int) (found int, archived int, err error) {
+	const op = "TaskService.ArchiveClosedTasks"
+	log := s.log.WithField("op", op)
+
+	ids, err := s.taskArchive.ArchivableTaskIDs(ctx, archivableStatuses, completedBefore, limit)
+	if err != nil {
+		log.WithError(err).Error("failed to list archivable tasks")
+		return 0, 0, err
+	}
+
+	for _, taskID := range ids {
+		err := s.inTx(ctx, func(ctx context.Context) error {
+			if err := s.taskArchive.ArchiveTask(ctx, taskID, archivableStatuses); err != nil {
+				return err
+			}
+			return s.taskHistory.SaveTaskEvents(ctx, newTaskEvent(ctx, taskID, models.TaskEventArchived, nil, nil))
+		})
+		if err != nil {
+			// задачу могли переоткрыть после выборки, её заархивирует следующий проход
+			if errors.Is(err, postgresql.ErrTaskNotArchivable) || errors.Is(err, postgresql.ErrTaskVersionConflict) || errors.Is(err, postgresql.ErrTaskNotFound) {
+				log.WithField("taskID", taskID).Warn("task was changed before archiving", err)
+				continue
+			}
+
+			log.WithError(err).WithField("taskID", taskID).Error("failed to archive task")
+			return len(ids), archived, err
+		}
+		archived++
+	}
+
+	if archived > 0 {
+		log.WithField("count", archived).Info("tasks archived")
+	}
+
+	return len(ids), archived, nil
+}
+
+// RestoreTask возвращает задачу из архива; доступно только администратору
+func (s *TaskService) RestoreTask(ctx context.Context, taskID int64) (models.Task, error) {
+	const op = "TaskService.RestoreTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	actor, err := s.actorFromContext(ctx)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+		log.WithError(err).Error("failed to get user")
+		return models.Task{}, err
+	}
+	if actor == nil || actor.Role != postgresql.RoleAdmin {
+		log.Warn("user is not admin")
+		return models.Task{}, ErrPermissionDenied
+	}
+
+	var task models.Task
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		task, err = s.taskArchive.RestoreTask(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		return s.taskHistory.SaveTaskEvents(ctx, newTaskEvent(ctx, taskID, models.TaskEventRestored, nil, nil))
+	})
+	if err != nil {
+		if errors.Is(err, postgresql.ErrArchivedTaskNotFound) {
+			log.Warn("archived task not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to restore task")
+		return models.Task{}, err
+	}
+
+	log.Info("task restored")
+	return s.withSLA(task), nil
+}
+
+// archivedTask читает задачу из архива для GetTask, если в tasks её уже нет
+func (s *TaskService) archivedTask(ctx context.Context, taskID int64) (models.Task, error) {
+	task, err := s.taskArchive.ArchivedTaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrArchivedTaskNotFound) {
+			return models.Task{}, ErrInvalidCredentials
+		}
+		return models.Task{}, err
+	}
+
+	return task, nil
+}
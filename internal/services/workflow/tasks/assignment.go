@@ -0,0 +1,34 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+)
+
+// ExplainAssignment показывает, кому была бы назначена задача и почему, ничего не изменяя
+func (s *TaskService) ExplainAssignment(ctx context.Context, taskID int64) (assignment.Decision, error) {
+	const op = "TaskService.ExplainAssignment"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return assignment.Decision{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return assignment.Decision{}, err
+	}
+
+	log.Info("explain assignment")
+	decision, err := s.assigner.Peek(ctx, task)
+	if err != nil {
+		log.WithError(err).Warn("failed to choose assignee")
+		return assignment.Decision{}, err
+	}
+
+	return decision, nil
+}
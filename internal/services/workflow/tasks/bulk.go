@@ -0,0 +1,298 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"time"
+)
+
+var ErrInvalidBulk = errors.New("invalid bulk request")
+
+const maxBulkSize = 500
+
+// BulkMode определяет, как массовая операция ведёт себя при ошибке по одной из задач
+type BulkMode int32
+
+const (
+	// BulkModeAtomic выполняет все задачи в одной транзакции и откатывает её при первой ошибке
+	BulkModeAtomic BulkMode = iota
+	// BulkModeBestEffort выполняет каждую задачу в своей транзакции и продолжает после ошибок
+	BulkModeBestEffort
+)
+
+type BulkCode string
+
+const (
+	BulkCodeOK                 BulkCode = "ok"
+	BulkCodeNotFound           BulkCode = "not_found"
+	BulkCodeFailedPrecondition BulkCode = "failed_precondition"
+	BulkCodeAborted            BulkCode = "aborted"
+	BulkCodeInternal           BulkCode = "internal"
+)
+
+// BulkResult описывает результат массовой операции по одной задаче
+type BulkResult struct {
+	TaskID int64
+	Task   models.Task
+	Code   BulkCode
+	Err    error
+}
+
+type bulkOperation func(ctx context.Context, taskID int64) (models.Task, error)
+
+// BulkCloseTasks закрывает задачи, прикрепляя к ним общий кейс и/или решение. Задачи, не взятые в работу,
+// закрываются только с причиной
+func (s *TaskService) BulkCloseTasks(ctx context.Context, taskIDs []int64, caseID *int64, solution *string, reason string, mode BulkMode) ([]BulkResult, error) {
+	const op = "TaskService.BulkCloseTasks"
+	log := s.log.WithField("op", op).WithField("count", len(taskIDs))
+
+	actor, err := s.actorFromContext(ctx)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return nil, ErrInvalidCredentials
+		}
+		log.WithError(err).Error("failed to get user")
+		return nil, err
+	}
+
+	var caseItem *models.Case
+	if caseID != nil {
+		item, err := s.caseProvider.CaseByID(ctx, *caseID)
+		if err != nil {
+			if errors.Is(err, postgresql.ErrCaseNotFound) {
+				log.Warn("case not found", err)
+				return nil, ErrInvalidCredentials
+			}
+			log.WithError(err).Error("failed to get case")
+			return nil, err
+		}
+		caseItem = &item
+	}
+
+	return s.runBulk(ctx, op, taskIDs, mode, func(ctx context.Context, taskID int64) (models.Task, error) {
+		task, err := s.bulkTask(ctx, taskID)
+		if err != nil {
+			return models.Task{}, err
+		}
+
+		var events []models.TaskEvent
+		if caseItem != nil && !sameID(task.CaseID, caseID) {
+			events = append(events, newTaskEvent(ctx, taskID, models.TaskEventCaseAdded, idValue(task.CaseID), idValue(caseID)))
+			task.CaseID = &caseItem.ID
+			task.Case = caseItem
+		}
+		if solution != nil {
+			events = append(events, newTaskEvent(ctx, taskID, models.TaskEventSolutionAdded, copyValue(task.Solution), solution))
+			task.Solution = solution
+		}
+
+		tc := &transitionContext{
+			task:   &task,
+			from:   task.Status,
+			to:     models.TaskStatusClosed,
+			actor:  actor,
+			reason: reason,
+			now:    time.Now(),
+		}
+		if err := s.applyTransition(ctx, tc); err != nil {
+			return models.Task{}, err
+		}
+		events = append(events, statusEvent(ctx, tc))
+
+		if err := s.updateTask(ctx, &task, events...); err != nil {
+			return models.Task{}, err
+		}
+
+		return task, nil
+	})
+}
+
+// BulkAssignTasks назначает задачи указанному агенту и берёт их в работу
+func (s *TaskService) BulkAssignTasks(ctx context.Context, taskIDs []int64, userID int64, mode BulkMode) ([]BulkResult, error) {
+	const op = "TaskService.BulkAssignTasks"
+	log := s.log.WithField("op", op).WithField("count", len(taskIDs)).WithField("userID", userID)
+
+	assignee, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return nil, ErrInvalidCredentials
+		}
+		log.WithError(err).Error("failed to get user")
+		return nil, err
+	}
+	if err := assignable(assignee); err != nil {
+		log.Warn("user can not be assigned tasks")
+		return nil, err
+	}
+
+	return s.runBulk(ctx, op, taskIDs, mode, func(ctx context.Context, taskID int64) (models.Task, error) {
+		task, err := s.bulkTask(ctx, taskID)
+		if err != nil {
+			return models.Task{}, err
+		}
+
+		if task.User != nil {
+			return models.Task{}, ErrAlreadyAppointed
+		}
+
+		tc := &transitionContext{
+			task:     &task,
+			from:     task.Status,
+			to:       models.TaskStatusInProgress,
+			assignee: &assignee,
+			now:      time.Now(),
+		}
+		if err := s.applyTransition(ctx, tc); err != nil {
+			return models.Task{}, err
+		}
+
+		events := []models.TaskEvent{
+			newTaskEvent(ctx, taskID, models.TaskEventUserAppointed, nil, idValue(task.UserID)),
+			statusEvent(ctx, tc),
+		}
+		if err := s.updateTask(ctx, &task, events...); err != nil {
+			return models.Task{}, err
+		}
+
+		if err := s.notifyAssignee(ctx, assignee); err != nil {
+			return models.Task{}, err
+		}
+
+		return task, nil
+	})
+}
+
+// BulkFireTasks поджигает или гасит задачи
+func (s *TaskService) BulkFireTasks(ctx context.Context, taskIDs []int64, fire bool, reason *string, mode BulkMode) ([]BulkResult, error) {
+	const op = "TaskService.BulkFireTasks"
+
+	return s.runBulk(ctx, op, taskIDs, mode, func(ctx context.Context, taskID int64) (models.Task, error) {
+		return s.setFire(ctx, taskID, fire, reason)
+	})
+}
+
+// runBulk выполняет операцию над каждой задачей в выбранном режиме и собирает результаты
+func (s *TaskService) runBulk(ctx context.Context, op string, taskIDs []int64, mode BulkMode, fn bulkOperation) ([]BulkResult, error) {
+	log := s.log.WithField("op", op).WithField("mode", mode)
+
+	// одна ожидаемая версия не может относиться ко всем задачам сразу
+	ctx = withoutExpectedVersion(ctx)
+
+	taskIDs = uniqueIDs(taskIDs)
+	if len(taskIDs) == 0 || len(taskIDs) > maxBulkSize {
+		return nil, fmt.Errorf("%s: %d tasks: %w", op, len(taskIDs), ErrInvalidBulk)
+	}
+
+	results := make([]BulkResult, len(taskIDs))
+	for i, taskID := range taskIDs {
+		results[i] = BulkResult{TaskID: taskID}
+	}
+
+	switch mode {
+	case BulkModeAtomic:
+		failed := -1
+		err := s.inTx(ctx, func(ctx context.Context) error {
+			for i, taskID := range taskIDs {
+				task, err := fn(ctx, taskID)
+				if err != nil {
+					failed = i
+					return err
+				}
+				results[i].Task = task
+			}
+			return nil
+		})
+		for i := range results {
+			switch {
+			case err == nil:
+				results[i].Code = BulkCodeOK
+				results[i].Task = s.withSLA(results[i].Task)
+			case i == failed:
+				results[i].Code = bulkCode(err)
+				results[i].Err = err
+				results[i].Task = models.Task{}
+			default:
+				results[i].Code = BulkCodeAborted
+				results[i].Err = err
+				results[i].Task = models.Task{}
+			}
+		}
+		if err != nil {
+			log.WithError(err).Warn("bulk operation rolled back")
+		}
+	case BulkModeBestEffort:
+		for i, taskID := range taskIDs {
+			var task models.Task
+			err := s.inTx(ctx, func(ctx context.Context) error {
+				var err error
+				task, err = fn(ctx, taskID)
+				return err
+			})
+			if err != nil {
+				log.WithError(err).WithField("taskID", taskID).Warn("bulk operation failed for task")
+				results[i].Code = bulkCode(err)
+				results[i].Err = err
+				continue
+			}
+			results[i].Code = BulkCodeOK
+			results[i].Task = s.withSLA(task)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown mode %d: %w", op, mode, ErrInvalidBulk)
+	}
+
+	return results, nil
+}
+
+// bulkTask загружает задачу внутри массовой операции
+func (s *TaskService) bulkTask(ctx context.Context, taskID int64) (models.Task, error) {
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			return models.Task{}, ErrInvalidCredentials
+		}
+		return models.Task{}, err
+	}
+
+	return task, nil
+}
+
+// bulkCode сопоставляет ошибку операции с кодом результата
+func bulkCode(err error) BulkCode {
+	switch {
+	case err == nil:
+		return BulkCodeOK
+	case errors.Is(err, ErrInvalidCredentials):
+		return BulkCodeNotFound
+	case errors.Is(err, ErrTransitionNotAllowed),
+		errors.Is(err, ErrReasonRequired),
+		errors.Is(err, ErrAssigneeRequired),
+		errors.Is(err, ErrAlreadyAppointed),
+		errors.Is(err, ErrOpenSubtasks),
+		errors.Is(err, ErrTaskBlocked):
+		return BulkCodeFailedPrecondition
+	case errors.Is(err, ErrVersionConflict):
+		return BulkCodeAborted
+	default:
+		return BulkCodeInternal
+	}
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]struct{}, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	return unique
+}
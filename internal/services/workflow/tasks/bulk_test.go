@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRunBulk(t *testing.T) {
+	errBoom := errors.New("boom")
+	failures := map[int64]error{
+		2: fmt.Errorf("op: %w", ErrReasonRequired),
+		3: ErrInvalidCredentials,
+	}
+	operation := func(_ context.Context, taskID int64) (models.Task, error) {
+		if err, ok := failures[taskID]; ok {
+			return models.Task{}, err
+		}
+		return models.Task{ID: taskID, Status: models.TaskStatusClosed}, nil
+	}
+
+	tests := []struct {
+		name      string
+		taskIDs   []int64
+		mode      BulkMode
+		fn        bulkOperation
+		wantCodes []BulkCode
+		wantErr   error
+	}{
+		{
+			name:      "atomic success",
+			taskIDs:   []int64{1, 4, 1},
+			mode:      BulkModeAtomic,
+			fn:        operation,
+			wantCodes: []BulkCode{BulkCodeOK, BulkCodeOK},
+		},
+		{
+			name:      "atomic failure aborts the rest",
+			taskIDs:   []int64{1, 2, 4},
+			mode:      BulkModeAtomic,
+			fn:        operation,
+			wantCodes: []BulkCode{BulkCodeAborted, BulkCodeFailedPrecondition, BulkCodeAborted},
+		},
+		{
+			name:      "best effort keeps going",
+			taskIDs:   []int64{1, 2, 3, 4},
+			mode:      BulkModeBestEffort,
+			fn:        operation,
+			wantCodes: []BulkCode{BulkCodeOK, BulkCodeFailedPrecondition, BulkCodeNotFound, BulkCodeOK},
+		},
+		{
+			name:    "internal error",
+			taskIDs: []int64{5},
+			mode:    BulkModeBestEffort,
+			fn: func(context.Context, int64) (models.Task, error) {
+				return models.Task{}, errBoom
+			},
+			wantCodes: []BulkCode{BulkCodeInternal},
+		},
+		{
+			name:    "empty request",
+			mode:    BulkModeAtomic,
+			fn:      operation,
+			wantErr: ErrInvalidBulk,
+		},
+		{
+			name:    "unknown mode",
+			taskIDs: []int64{1},
+			mode:    BulkMode(7),
+			fn:      operation,
+			wantErr: ErrInvalidBulk,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(newFakeStore(), &fakeUsers{})
+
+			results, err := s.runBulk(context.Background(), "test", tt.taskIDs, tt.mode, tt.fn)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Len(t, results, len(tt.wantCodes))
+			for i, result := range results {
+				assert.Equal(t, tt.wantCodes[i], result.Code, "task %d", result.TaskID)
+				if result.Code == BulkCodeOK {
+					assert.NoError(t, result.Err)
+					assert.Equal(t, result.TaskID, result.Task.ID)
+				} else {
+					assert.Error(t, result.Err)
+					assert.Zero(t, result.Task.ID, "failed task must not be returned")
+				}
+			}
+		})
+	}
+}
+
+func TestRunBulkRejectsTooManyTasks(t *testing.T) {
+	s := newTestService(newFakeStore(), &fakeUsers{})
+	taskIDs := make([]int64, maxBulkSize+1)
+	for i := range taskIDs {
+		taskIDs[i] = int64(i + 1)
+	}
+
+	_, err := s.runBulk(context.Background(), "test", taskIDs, BulkModeBestEffort, func(context.Context, int64) (models.Task, error) {
+		return models.Task{}, nil
+	})
+
+	assert.ErrorIs(t, err, ErrInvalidBulk)
+}
+
+func TestBulkCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want BulkCode
+	}{
+		{err: nil, want: BulkCodeOK},
+		{err: ErrInvalidCredentials, want: BulkCodeNotFound},
+		{err: fmt.Errorf("op: %w", ErrTransitionNotAllowed), want: BulkCodeFailedPrecondition},
+		{err: ErrAssigneeRequired, want: BulkCodeFailedPrecondition},
+		{err: ErrAlreadyAppointed, want: BulkCodeFailedPrecondition},
+		{err: ErrOpenSubtasks, want: BulkCodeFailedPrecondition},
+		{err: ErrTaskBlocked, want: BulkCodeFailedPrecondition},
+		{err: ErrVersionConflict, want: BulkCodeAborted},
+		{err: errors.New("boom"), want: BulkCodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.err), func(t *testing.T) {
+			assert.Equal(t, tt.want, bulkCode(tt.err))
+		})
+	}
+}
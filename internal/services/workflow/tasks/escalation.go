@@ -0,0 +1,126 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"strconv"
+)
+
+var ErrInvalidEscalation = errors.New("invalid escalation level")
+
+const (
+	handoffActionReminder  = "escalation_reminder"
+	handoffActionEscalated = "escalated"
+)
+
+// EscalateTask выполняет шаг эскалации level для задачи в работе: напоминает исполнителю, передаёт задачу
+// другому агенту или передаёт её администраторам и поджигает. Уже выполненные шаги не повторяются
+func (s *TaskService) EscalateTask(ctx context.Context, taskID int64, level models.EscalationLevel, reason string) (models.Task, error) {
+	const op = "TaskService.EscalateTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID).WithField("level", level)
+
+	if level <= models.EscalationNone || level > models.EscalationEscalated {
+		return models.Task{}, ErrInvalidEscalation
+	}
+
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
+	}
+
+	if task.Status != models.TaskStatusInProgress || task.User == nil {
+		return models.Task{}, ErrTransitionNotAllowed
+	}
+	if task.EscalationLevel >= level {
+		return s.withSLA(task), nil
+	}
+
+	event := newTaskEvent(ctx, taskID, models.TaskEventEscalated, escalationValue(task.EscalationLevel), escalationValue(level))
+	event.Comment = &reason
+	events := []models.TaskEvent{event}
+	previous := *task.User
+
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		switch level {
+		case models.EscalationNotified:
+			if err := s.notifyHandoff(ctx, previous, taskID, handoffActionReminder, reason); err != nil {
+				return err
+			}
+		case models.EscalationReassigned:
+			decision, err := s.assigner.Choose(ctx, task, previous.ID)
+			if errors.Is(err, assignment.ErrNoCandidates) {
+				// передать некому, остаётся напоминание текущему исполнителю
+				log.Warn("no agents to reassign escalated task")
+				if err := s.notifyHandoff(ctx, previous, taskID, handoffActionReminder, reason); err != nil {
+					return err
+				}
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			assignee := decision.User
+			if err := s.moveWorkload(ctx, previous.ID, assignee.ID, task.AvarageDuration); err != nil {
+				return err
+			}
+			appointed := newTaskEvent(ctx, taskID, models.TaskEventUserAppointed, idValue(&previous.ID), idValue(&assignee.ID))
+			appointed.Comment = &reason
+			events = append(events, appointed)
+			task.UserID = &assignee.ID
+			task.User = &assignee
+
+			if err := s.notifyHandoff(ctx, previous, taskID, handoffActionUnassigned, reason); err != nil {
+				return err
+			}
+			if err := s.notifyHandoff(ctx, assignee, taskID, handoffActionAssigned, reason); err != nil {
+				return err
+			}
+		case models.EscalationEscalated:
+			users, err := s.userService.GetUserList(ctx)
+			if err != nil {
+				return err
+			}
+			for _, admin := range users {
+				if admin.Role != postgresql.RoleAdmin {
+					continue
+				}
+				if err := s.notifyHandoff(ctx, admin, taskID, handoffActionEscalated, reason); err != nil {
+					return err
+				}
+			}
+
+			if !task.Fire {
+				fired := newTaskEvent(ctx, taskID, models.TaskEventFired, boolValue(false), boolValue(true))
+				fired.Comment = &reason
+				events = append(events, fired)
+				task.Fire = true
+				task.FireReason = &reason
+			}
+		}
+
+		task.EscalationLevel = level
+		log.Info("escalate task")
+		return s.updateTask(ctx, &task, events...)
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to escalate task")
+		return models.Task{}, err
+	}
+
+	return s.withSLA(task), nil
+}
+
+func escalationValue(level models.EscalationLevel) *string {
+	value := strconv.Itoa(int(level))
+	return &value
+}
@@ -0,0 +1,214 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
+	"github.com/sirupsen/logrus"
+	"io"
+	"maps"
+	"sort"
+	"time"
+)
+
+// fakeStore хранилище задач в памяти; методы, не нужные тестам, остаются у встроенных nil-интерфейсов.
+// Вложенные транзакции, как и в Postgres, выполняются в той же транзакции
+type fakeStore struct {
+	TaskProvider
+	TaskSaver
+	TaskHistory
+	TaskLinkStore
+
+	tasks  map[int64]models.Task
+	events []models.TaskEvent
+	// comments ID задачи, к которой сейчас относятся комментарии задачи
+	comments map[int64]int64
+	// links незавершённые связанные задачи по виду связи
+	links map[models.TaskLinkKind][]int64
+	sla   fakeSLA
+	// failUpdate задача, изменение которой завершается ошибкой
+	failUpdate int64
+
+	inTx bool
+}
+
+func newFakeStore(tasks ...models.Task) *fakeStore {
+	store := &fakeStore{
+		tasks:    make(map[int64]models.Task),
+		comments: make(map[int64]int64),
+		links:    make(map[models.TaskLinkKind][]int64),
+	}
+	for _, task := range tasks {
+		store.tasks[task.ID] = task
+	}
+	return store
+}
+
+var errFakeUpdate = errors.New("update failed")
+
+// Transaction откатывает изменения задач, журнала и комментариев, если fn вернула ошибку
+func (f *fakeStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if f.inTx {
+		return fn(ctx)
+	}
+
+	tasks := maps.Clone(f.tasks)
+	comments := maps.Clone(f.comments)
+	events := len(f.events)
+
+	f.inTx = true
+	err := fn(ctx)
+	f.inTx = false
+
+	if err != nil {
+		f.tasks, f.comments, f.events = tasks, comments, f.events[:events]
+	}
+	return err
+}
+
+func (f *fakeStore) TaskByID(_ context.Context, taskID int64) (models.Task, error) {
+	task, ok := f.tasks[taskID]
+	if !ok {
+		return models.Task{}, postgresql.ErrTaskNotFound
+	}
+	return task, nil
+}
+
+// UpdateTask как и Postgres, сохраняет задачу только при совпадении версии и увеличивает её
+func (f *fakeStore) UpdateTask(_ context.Context, id int64, task models.Task) error {
+	if id == f.failUpdate {
+		return errFakeUpdate
+	}
+	stored, ok := f.tasks[id]
+	if !ok || stored.Version != task.Version {
+		return postgresql.ErrTaskVersionConflict
+	}
+
+	task.Version++
+	f.tasks[id] = task
+	return nil
+}
+
+func (f *fakeStore) SaveTaskEvents(_ context.Context, events ...models.TaskEvent) error {
+	f.events = append(f.events, events...)
+	return nil
+}
+
+func (f *fakeStore) MoveComments(_ context.Context, fromTaskID, toTaskID int64) error {
+	for commentOf, taskID := range f.comments {
+		if taskID == fromTaskID {
+			f.comments[commentOf] = toTaskID
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) QueryTasks(_ context.Context, query models.TaskQuery) (models.TaskPage, error) {
+	var page models.TaskPage
+	for _, task := range f.tasks {
+		if query.MergedIntoID != nil && (task.MergedIntoID == nil || *task.MergedIntoID != *query.MergedIntoID) {
+			continue
+		}
+		page.Tasks = append(page.Tasks, task)
+	}
+	return page, nil
+}
+
+// QueueTasks отдаёт задачи в статусах query.Statuses по убыванию базового веса, как запрос к базе
+func (f *fakeStore) QueueTasks(_ context.Context, query models.TaskQuery, weights models.QueueWeights, offset, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	for _, task := range f.tasks {
+		for _, status := range query.Statuses {
+			if task.Status == status {
+				tasks = append(tasks, task)
+				break
+			}
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		left, right := baseQueueScore(tasks[i], weights.Now), baseQueueScore(tasks[j], weights.Now)
+		if left != right {
+			return left > right
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+
+	if offset >= len(tasks) {
+		return nil, nil
+	}
+	return tasks[offset:min(offset+limit, len(tasks))], nil
+}
+
+func (f *fakeStore) LinkedTaskIDs(_ context.Context, _ int64, kind models.TaskLinkKind, _, _ bool) ([]int64, error) {
+	return f.links[kind], nil
+}
+
+// racingStore сохраняет параллельное изменение задачи taskID сразу после её первого чтения
+type racingStore struct {
+	*fakeStore
+	taskID int64
+	change func(task *models.Task)
+	done   bool
+}
+
+func (r *racingStore) TaskByID(ctx context.Context, taskID int64) (models.Task, error) {
+	task, err := r.fakeStore.TaskByID(ctx, taskID)
+	if taskID == r.taskID && !r.done {
+		r.done = true
+		stored := r.tasks[taskID]
+		r.change(&stored)
+		stored.Version++
+		r.tasks[taskID] = stored
+	}
+	return task, err
+}
+
+// fakeUsers копит изменения нагрузки агентов
+type fakeUsers struct {
+	user.UserProvider
+
+	workload map[int64]float32
+}
+
+func (f *fakeUsers) AddUserAvarageDuration(_ context.Context, userID int64, delta float32) error {
+	if f.workload == nil {
+		f.workload = make(map[int64]float32)
+	}
+	f.workload[userID] += delta
+	return nil
+}
+
+// fakeSLA возвращает заданный уровень SLA по ID задачи, для остальных задач SLA не считается
+type fakeSLA map[int64]models.SLALevel
+
+func (f fakeSLA) Evaluate(task models.Task, _ time.Time) *models.SLAStatus {
+	level, ok := f[task.ID]
+	if !ok {
+		return nil
+	}
+	return &models.SLAStatus{Level: level}
+}
+
+func newTestLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func newTestService(store *fakeStore, users *fakeUsers) *TaskService {
+	log := newTestLogger()
+	return New(log, "", "", Deps{
+		TaskProvider:  store,
+		TaskSaver:     store,
+		TaskHistory:   store,
+		TaskLinks:     store,
+		CommentMover:  store,
+		TxManager:     store,
+		TaskPublisher: taskfeed.New(0),
+		SLAPolicy:     store.sla,
+		UserService:   *user.New(log, users),
+	})
+}
@@ -0,0 +1,200 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
+	"time"
+)
+
+const (
+	handoffActionAssigned   = "assigned"
+	handoffActionUnassigned = "unassigned"
+)
+
+var ErrInvalidAssignee = errors.New("user can not be assigned tasks")
+
+// assignable проверяет, что пользователю можно назначать задачи: как и при автоматическом назначении,
+// это активный агент, а не администратор или удаленный пользователь
+func assignable(user models.User) error {
+	if user.Role != postgresql.RoleUser || user.Status != postgresql.StatusActive {
+		return ErrInvalidAssignee
+	}
+	return nil
+}
+
+// UnassignTask снимает задачу с исполнителя и возвращает её в очередь
+func (s *TaskService) UnassignTask(ctx context.Context, taskID int64, note string) (models.Task, error) {
+	const op = "TaskService.UnassignTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	actor, err := s.actorFromContext(ctx)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+		log.WithError(err).Error("failed to get user")
+		return models.Task{}, err
+	}
+
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
+	}
+
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	if task.User == nil {
+		log.Warn("task is not assigned")
+		return models.Task{}, ErrAssigneeRequired
+	}
+
+	previous := *task.User
+	oldUserID := task.UserID
+	tc := &transitionContext{
+		task:   &task,
+		from:   task.Status,
+		to:     models.TaskStatusOpen,
+		actor:  actor,
+		reason: note,
+		now:    time.Now(),
+	}
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.applyTransition(ctx, tc); err != nil {
+			log.WithError(err).Warn("failed to apply transition")
+			return err
+		}
+
+		appointed := newTaskEvent(ctx, taskID, models.TaskEventUserAppointed, idValue(oldUserID), nil)
+		appointed.Comment = &note
+
+		log.Info("unassign task")
+		if err := s.updateTask(ctx, &task, statusEvent(ctx, tc), appointed); err != nil {
+			log.WithError(err).Error("failed to update tasks")
+			return err
+		}
+
+		if err := s.notifyHandoff(ctx, previous, taskID, handoffActionUnassigned, note); err != nil {
+			log.WithError(err).Error("failed to save notification")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	if s.dispatcher != nil && !s.dispatcher.Enqueue(taskID) {
+		log.Warn("dispatch queue is full")
+	}
+
+	return s.withSLA(task), nil
+}
+
+// ReassignTask передаёт задачу в работе другому агенту вместе с её нагрузкой
+func (s *TaskService) ReassignTask(ctx context.Context, taskID, userID int64, note string) (models.Task, error) {
+	const op = "TaskService.ReassignTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID).WithField("userID", userID)
+
+	if note == "" {
+		return models.Task{}, ErrReasonRequired
+	}
+
+	assignee, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			log.Warn("user not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+		log.WithError(err).Error("failed to get user")
+		return models.Task{}, err
+	}
+	if err := assignable(assignee); err != nil {
+		log.Warn("user can not be assigned tasks")
+		return models.Task{}, err
+	}
+
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
+	}
+
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	switch {
+	case task.User == nil:
+		return models.Task{}, ErrAssigneeRequired
+	case task.User.ID == assignee.ID:
+		return models.Task{}, ErrAlreadyAppointed
+	case task.Status != models.TaskStatusInProgress && task.Status != models.TaskStatusOnHold && task.Status != models.TaskStatusWaitingForCustomer:
+		return models.Task{}, ErrTransitionNotAllowed
+	}
+
+	previous := *task.User
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.moveWorkload(ctx, previous.ID, assignee.ID, task.AvarageDuration); err != nil {
+			log.WithError(err).Error("failed to move workload")
+			return err
+		}
+
+		event := newTaskEvent(ctx, taskID, models.TaskEventUserAppointed, idValue(&previous.ID), idValue(&assignee.ID))
+		event.Comment = &note
+		task.UserID = &assignee.ID
+		task.User = &assignee
+		now := time.Now()
+		task.EscalationStartedAt = &now
+		task.EscalationLevel = models.EscalationNone
+
+		log.Info("reassign task")
+		if err := s.updateTask(ctx, &task, event); err != nil {
+			log.WithError(err).Error("failed to update tasks")
+			return err
+		}
+
+		if err := s.notifyHandoff(ctx, previous, taskID, handoffActionUnassigned, note); err != nil {
+			log.WithError(err).Error("failed to save notification")
+			return err
+		}
+		if err := s.notifyHandoff(ctx, assignee, taskID, handoffActionAssigned, note); err != nil {
+			log.WithError(err).Error("failed to save notification")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return s.withSLA(task), nil
+}
+
+// moveWorkload переносит нагрузку задачи с одного агента на другого
+func (s *TaskService) moveWorkload(ctx context.Context, fromUserID, toUserID int64, duration float32) error {
+	if err := s.userService.AddUserAvarageDuration(ctx, fromUserID, -duration); err != nil {
+		return err
+	}
+	return s.userService.AddUserAvarageDuration(ctx, toUserID, duration)
+}
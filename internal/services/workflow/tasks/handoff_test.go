@@ -0,0 +1,33 @@
+package tasks
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUnassignTaskRequiresAssignee(t *testing.T) {
+	userID := int64(7)
+
+	tests := []struct {
+		name string
+		task models.Task
+	}{
+		{name: "open task", task: models.Task{ID: 1, Status: models.TaskStatusOpen, Version: 1}},
+		{name: "assignee not loaded", task: models.Task{ID: 1, Status: models.TaskStatusInProgress, UserID: &userID, Version: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore(tt.task)
+			s := newTestService(store, &fakeUsers{})
+
+			_, err := s.UnassignTask(context.Background(), tt.task.ID, "agent is away")
+			require.ErrorIs(t, err, ErrAssigneeRequired)
+			assert.Equal(t, tt.task, store.tasks[tt.task.ID])
+			assert.Empty(t, store.events)
+		})
+	}
+}
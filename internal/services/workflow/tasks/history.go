@@ -0,0 +1,164 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
+	"strconv"
+)
+
+func (s *TaskService) GetTaskHistory(ctx context.Context, taskID int64) ([]models.TaskEvent, error) {
+	const op = "TaskService.GetTaskHistory"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	log.Info("get task history")
+	if _, err := s.taskProvider.TaskByID(ctx, taskID); err != nil {
+		if !errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.WithError(err).Error("failed to get tasks")
+			return nil, err
+		}
+
+		// журнал заархивированной задачи остаётся доступным
+		if _, err := s.archivedTask(ctx, taskID); err != nil {
+			if errors.Is(err, ErrInvalidCredentials) {
+				log.Warn("tasks not found", err)
+			} else {
+				log.WithError(err).Error("failed to get archived task")
+			}
+			return nil, err
+		}
+	}
+
+	events, err := s.taskHistory.ListTaskEvents(ctx, taskID)
+	if err != nil {
+		log.WithError(err).Error("failed to list task events")
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// updateTask сохраняет задачу, если её версия не изменилась с момента чтения, увеличивает версию
+// и дописывает события в журнал задачи в той же транзакции
+func (s *TaskService) updateTask(ctx context.Context, task *models.Task, events ...models.TaskEvent) error {
+	const op = "TaskService.updateTask"
+
+	updated := *task
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.taskSaver.UpdateTask(ctx, updated.ID, updated); err != nil {
+			if errors.Is(err, postgresql.ErrTaskVersionConflict) {
+				return fmt.Errorf("task %d: %w", updated.ID, ErrVersionConflict)
+			}
+			return err
+		}
+		updated.Version++
+
+		if err := s.taskHistory.SaveTaskEvents(ctx, events...); err != nil {
+			return err
+		}
+
+		s.publish(ctx, feedEventType(updated, events), updated, previousAssignee(events))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	*task = updated
+	return nil
+}
+
+// feedEventType определяет тип события для подписчиков по записям журнала
+func feedEventType(task models.Task, events []models.TaskEvent) taskfeed.EventType {
+	for _, event := range events {
+		if event.Kind == models.TaskEventFired {
+			return taskfeed.EventFired
+		}
+	}
+
+	for _, event := range events {
+		if event.Kind == models.TaskEventStatusChanged && (task.Status == models.TaskStatusClosed || task.Status == models.TaskStatusCancelled) {
+			return taskfeed.EventClosed
+		}
+	}
+
+	return taskfeed.EventUpdated
+}
+
+// previousAssignee исполнитель до изменения, если среди событий есть смена исполнителя
+func previousAssignee(events []models.TaskEvent) *int64 {
+	for _, event := range events {
+		if event.Kind != models.TaskEventUserAppointed || event.OldValue == nil {
+			continue
+		}
+		if id, err := strconv.ParseInt(*event.OldValue, 10, 64); err == nil {
+			return &id
+		}
+	}
+	return nil
+}
+
+// newTaskEvent создает событие журнала от имени пользователя из контекста
+func newTaskEvent(ctx context.Context, taskID int64, kind models.TaskEventKind, oldValue, newValue *string) models.TaskEvent {
+	event := models.TaskEvent{
+		TaskID:   taskID,
+		Kind:     kind,
+		OldValue: oldValue,
+		NewValue: newValue,
+	}
+
+	if userID, ok := ctx.Value("userID").(int64); ok {
+		event.ActorID = &userID
+	}
+
+	return event
+}
+
+// statusEvent создает событие смены статуса с причиной перехода в комментарии
+func statusEvent(ctx context.Context, tc *transitionContext) models.TaskEvent {
+	event := newTaskEvent(ctx, tc.task.ID, models.TaskEventStatusChanged, statusValue(tc.from), statusValue(tc.to))
+	if tc.reason != "" {
+		reason := tc.reason
+		event.Comment = &reason
+	}
+
+	return event
+}
+
+func sameID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func statusValue(status models.TaskStatus) *string {
+	value := status.String()
+	return &value
+}
+
+func idValue(id *int64) *string {
+	if id == nil {
+		return nil
+	}
+
+	value := strconv.FormatInt(*id, 10)
+	return &value
+}
+
+func boolValue(b bool) *string {
+	value := strconv.FormatBool(b)
+	return &value
+}
+
+func copyValue(s *string) *string {
+	if s == nil {
+		return nil
+	}
+
+	value := *s
+	return &value
+}
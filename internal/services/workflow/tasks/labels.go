@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/sirupsen/logrus"
+)
+
+type TaskLabeler interface {
+	EnsureLabels(ctx context.Context, names []string, color string) ([]models.Label, error)
+	AddTaskLabels(ctx context.Context, taskID int64, labels []models.Label) error
+	RemoveTaskLabels(ctx context.Context, taskID int64, labels []models.Label) error
+}
+
+// AddLabelsToTask добавляет задаче метки, создавая отсутствующие в каталоге
+func (s *TaskService) AddLabelsToTask(ctx context.Context, taskID int64, names []string) (models.Task, error) {
+	const op = "TaskService.AddLabelsToTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	names = labels.NormalizeNames(names)
+	for _, name := range names {
+		if err := labels.ValidateName(name); err != nil {
+			return models.Task{}, err
+		}
+	}
+
+	return s.changeLabels(ctx, log, taskID, names, true)
+}
+
+// RemoveLabelsFromTask снимает с задачи метки, каталог не меняется
+func (s *TaskService) RemoveLabelsFromTask(ctx context.Context, taskID int64, names []string) (models.Task, error) {
+	const op = "TaskService.RemoveLabelsFromTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	return s.changeLabels(ctx, log, taskID, labels.NormalizeNames(names), false)
+}
+
+func (s *TaskService) changeLabels(ctx context.Context, log *logrus.Entry, taskID int64, names []string, add bool) (models.Task, error) {
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
+	}
+
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	current := make(map[string]bool, len(task.Labels))
+	for _, label := range task.Labels {
+		current[label.Name] = true
+	}
+
+	var changed []string
+	for _, name := range names {
+		if current[name] != add {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) == 0 {
+		return s.withSLA(task), nil
+	}
+
+	// изменяется прочитанная задача, чтобы обновление проверило её версию и не затёрло параллельные изменения
+	updated := task
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		if add {
+			added, err := s.taskLabeler.EnsureLabels(ctx, changed, labels.DefaultColor)
+			if err != nil {
+				return err
+			}
+			if err := s.taskLabeler.AddTaskLabels(ctx, taskID, added); err != nil {
+				return err
+			}
+			updated.Labels = append(append([]models.Label(nil), task.Labels...), added...)
+		} else {
+			removing := make(map[string]bool, len(changed))
+			for _, name := range changed {
+				removing[name] = true
+			}
+
+			var removed, kept []models.Label
+			for _, label := range task.Labels {
+				if removing[label.Name] {
+					removed = append(removed, label)
+				} else {
+					kept = append(kept, label)
+				}
+			}
+			if err := s.taskLabeler.RemoveTaskLabels(ctx, taskID, removed); err != nil {
+				return err
+			}
+			updated.Labels = kept
+		}
+
+		events := make([]models.TaskEvent, 0, len(changed))
+		for _, name := range changed {
+			name := name
+			if add {
+				events = append(events, newTaskEvent(ctx, taskID, models.TaskEventLabelAdded, nil, &name))
+			} else {
+				events = append(events, newTaskEvent(ctx, taskID, models.TaskEventLabelRemoved, &name, nil))
+			}
+		}
+
+		log.WithField("labels", changed).Info("change task labels")
+		return s.updateTask(ctx, &updated, events...)
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to change task labels")
+		return models.Task{}, err
+	}
+
+	return s.withSLA(updated), nil
+}
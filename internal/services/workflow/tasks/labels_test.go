@@ -0,0 +1,95 @@
+package tasks
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fakeLabeler создаёт метки каталога с последовательными ID
+type fakeLabeler struct {
+	nextID int64
+}
+
+func (f *fakeLabeler) EnsureLabels(_ context.Context, names []string, color string) ([]models.Label, error) {
+	labels := make([]models.Label, 0, len(names))
+	for _, name := range names {
+		f.nextID++
+		labels = append(labels, models.Label{ID: f.nextID, Name: name, Color: color})
+	}
+	return labels, nil
+}
+
+func (f *fakeLabeler) AddTaskLabels(context.Context, int64, []models.Label) error {
+	return nil
+}
+
+func (f *fakeLabeler) RemoveTaskLabels(context.Context, int64, []models.Label) error {
+	return nil
+}
+
+func TestChangeLabels(t *testing.T) {
+	vip := models.Label{ID: 10, Name: "vip"}
+	sms := models.Label{ID: 11, Name: "sms"}
+
+	tests := []struct {
+		name       string
+		change     func(s *TaskService) (models.Task, error)
+		wantLabels []string
+		wantEvents int
+	}{
+		{
+			name: "add",
+			change: func(s *TaskService) (models.Task, error) {
+				return s.AddLabelsToTask(context.Background(), 1, []string{"VIP", "billing"})
+			},
+			wantLabels: []string{"vip", "sms", "billing"},
+			wantEvents: 1,
+		},
+		{
+			name: "remove",
+			change: func(s *TaskService) (models.Task, error) {
+				return s.RemoveLabelsFromTask(context.Background(), 1, []string{"sms", "billing"})
+			},
+			wantLabels: []string{"vip"},
+			wantEvents: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore(models.Task{ID: 1, Version: 3, Labels: []models.Label{vip, sms}})
+			s := newTestService(store, &fakeUsers{})
+			s.taskLabeler = &fakeLabeler{nextID: 100}
+
+			task, err := tt.change(s)
+			require.NoError(t, err)
+
+			var names []string
+			for _, label := range task.Labels {
+				names = append(names, label.Name)
+			}
+			assert.Equal(t, tt.wantLabels, names)
+			assert.Equal(t, int64(4), task.Version)
+			assert.Equal(t, int64(4), store.tasks[1].Version)
+			assert.Len(t, store.events, tt.wantEvents)
+		})
+	}
+}
+
+func TestChangeLabelsConflictsWithConcurrentUpdate(t *testing.T) {
+	store := newFakeStore(models.Task{ID: 1, Version: 3})
+	s := newTestService(store, &fakeUsers{})
+	s.taskLabeler = &fakeLabeler{}
+	s.taskProvider = &racingStore{fakeStore: store, taskID: 1, change: func(task *models.Task) {
+		task.Status = models.TaskStatusInProgress
+	}}
+
+	_, err := s.AddLabelsToTask(context.Background(), 1, []string{"vip"})
+	require.ErrorIs(t, err, ErrVersionConflict)
+
+	assert.Equal(t, models.TaskStatusInProgress, store.tasks[1].Status)
+	assert.Empty(t, store.events)
+}
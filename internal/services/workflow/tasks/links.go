@@ -0,0 +1,217 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"strconv"
+)
+
+var (
+	ErrInvalidLink  = errors.New("invalid task link")
+	ErrLinkExists   = errors.New("task link already exists")
+	ErrLinkCycle    = errors.New("task link creates a cycle")
+	ErrOpenSubtasks = errors.New("task has unfinished subtasks")
+	ErrTaskBlocked  = errors.New("task is blocked by unfinished tasks")
+)
+
+type TaskLinkStore interface {
+	SaveTaskLink(ctx context.Context, link models.TaskLink) (models.TaskLink, error)
+	TaskLinkByID(ctx context.Context, linkID int64) (models.TaskLink, error)
+	DeleteTaskLink(ctx context.Context, linkID int64) error
+	ListTaskLinks(ctx context.Context, taskID int64) ([]models.TaskLink, error)
+	LinkedTaskIDs(ctx context.Context, taskID int64, kind models.TaskLinkKind, outgoing, unfinished bool) ([]int64, error)
+}
+
+// LinkTasks связывает задачи: для subtask fromID - родитель, для blocks fromID блокирует toID
+func (s *TaskService) LinkTasks(ctx context.Context, fromID, toID int64, kind models.TaskLinkKind) (models.TaskLink, error) {
+	const op = "TaskService.LinkTasks"
+	log := s.log.WithField("op", op).WithField("from", fromID).WithField("to", toID).WithField("kind", kind)
+
+	switch kind {
+	case models.TaskLinkSubtask, models.TaskLinkBlocks:
+	case models.TaskLinkRelated:
+		if fromID > toID {
+			fromID, toID = toID, fromID
+		}
+	default:
+		return models.TaskLink{}, fmt.Errorf("%s: unknown kind %q: %w", op, kind, ErrInvalidLink)
+	}
+	if fromID == toID {
+		return models.TaskLink{}, fmt.Errorf("%s: task linked to itself: %w", op, ErrInvalidLink)
+	}
+
+	for _, taskID := range []int64{fromID, toID} {
+		if _, err := s.taskProvider.TaskByID(ctx, taskID); err != nil {
+			if errors.Is(err, postgresql.ErrTaskNotFound) {
+				log.Warn("tasks not found", err)
+				return models.TaskLink{}, ErrInvalidCredentials
+			}
+
+			log.WithError(err).Error("failed to get tasks")
+			return models.TaskLink{}, err
+		}
+	}
+
+	link := models.TaskLink{FromTaskID: fromID, ToTaskID: toID, Kind: kind}
+	if userID, ok := ctx.Value("userID").(int64); ok {
+		link.CreatedByID = &userID
+	}
+
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		if kind == models.TaskLinkSubtask {
+			parents, err := s.taskLinks.LinkedTaskIDs(ctx, toID, models.TaskLinkSubtask, false, false)
+			if err != nil {
+				return err
+			}
+			if len(parents) > 0 {
+				return fmt.Errorf("%s: task %d already has parent %d: %w", op, toID, parents[0], ErrInvalidLink)
+			}
+		}
+
+		if kind != models.TaskLinkRelated {
+			cycle, err := s.reachable(ctx, toID, fromID, kind)
+			if err != nil {
+				return err
+			}
+			if cycle {
+				return fmt.Errorf("%s: %w", op, ErrLinkCycle)
+			}
+		}
+
+		var err error
+		link, err = s.taskLinks.SaveTaskLink(ctx, link)
+		if err != nil {
+			if errors.Is(err, postgresql.ErrTaskLinkExists) {
+				return ErrLinkExists
+			}
+			return err
+		}
+
+		return s.taskHistory.SaveTaskEvents(ctx,
+			newTaskEvent(ctx, fromID, models.TaskEventLinkAdded, nil, linkValue(kind, toID)),
+			newTaskEvent(ctx, toID, models.TaskEventLinkAdded, nil, linkValue(kind, fromID)),
+		)
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to link tasks")
+		return models.TaskLink{}, err
+	}
+
+	return link, nil
+}
+
+// UnlinkTasks удаляет связь между задачами
+func (s *TaskService) UnlinkTasks(ctx context.Context, linkID int64) error {
+	const op = "TaskService.UnlinkTasks"
+	log := s.log.WithField("op", op).WithField("linkID", linkID)
+
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		link, err := s.taskLinks.TaskLinkByID(ctx, linkID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.taskLinks.DeleteTaskLink(ctx, linkID); err != nil {
+			return err
+		}
+
+		return s.taskHistory.SaveTaskEvents(ctx,
+			newTaskEvent(ctx, link.FromTaskID, models.TaskEventLinkRemoved, linkValue(link.Kind, link.ToTaskID), nil),
+			newTaskEvent(ctx, link.ToTaskID, models.TaskEventLinkRemoved, linkValue(link.Kind, link.FromTaskID), nil),
+		)
+	})
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskLinkNotFound) {
+			log.Warn("task link not found", err)
+			return ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to unlink tasks")
+		return err
+	}
+
+	return nil
+}
+
+// ListTaskLinks возвращает все связи задачи
+func (s *TaskService) ListTaskLinks(ctx context.Context, taskID int64) ([]models.TaskLink, error) {
+	const op = "TaskService.ListTaskLinks"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	if _, err := s.taskProvider.TaskByID(ctx, taskID); err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return nil, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return nil, err
+	}
+
+	links, err := s.taskLinks.ListTaskLinks(ctx, taskID)
+	if err != nil {
+		log.WithError(err).Error("failed to list task links")
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// reachable проверяет, ведёт ли цепочка связей kind из задачи from в задачу to
+func (s *TaskService) reachable(ctx context.Context, from, to int64, kind models.TaskLinkKind) (bool, error) {
+	visited := map[int64]bool{from: true}
+	queue := []int64{from}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		next, err := s.taskLinks.LinkedTaskIDs(ctx, current, kind, true, false)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range next {
+			if id == to {
+				return true, nil
+			}
+			if !visited[id] {
+				visited[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// requireSubtasksClosed не даёт закрыть задачу, пока не завершены её подзадачи
+func (s *TaskService) requireSubtasksClosed(ctx context.Context, tc *transitionContext) error {
+	ids, err := s.taskLinks.LinkedTaskIDs(ctx, tc.task.ID, models.TaskLinkSubtask, true, true)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("%w: %v", ErrOpenSubtasks, ids)
+	}
+	return nil
+}
+
+// requireUnblocked не даёт закрыть задачу, пока не завершены блокирующие её задачи
+func (s *TaskService) requireUnblocked(ctx context.Context, tc *transitionContext) error {
+	ids, err := s.taskLinks.LinkedTaskIDs(ctx, tc.task.ID, models.TaskLinkBlocks, false, true)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("%w: %v", ErrTaskBlocked, ids)
+	}
+	return nil
+}
+
+func linkValue(kind models.TaskLinkKind, taskID int64) *string {
+	value := string(kind) + ":" + strconv.FormatInt(taskID, 10)
+	return &value
+}
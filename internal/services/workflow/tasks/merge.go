@@ -0,0 +1,283 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/duplicates"
+	"strconv"
+)
+
+var ErrMergeNotAllowed = errors.New("task merge not allowed")
+
+// activeStatuses статусы задач, которые ещё не завершены
+var activeStatuses = []models.TaskStatus{
+	models.TaskStatusOpen,
+	models.TaskStatusInProgress,
+	models.TaskStatusOnHold,
+	models.TaskStatusWaitingForCustomer,
+	models.TaskStatusReopened,
+}
+
+// FindDuplicates возвращает незавершённые задачи кластера, похожие на задачу
+func (s *TaskService) FindDuplicates(ctx context.Context, taskID int64) ([]duplicates.Match, error) {
+	const op = "TaskService.FindDuplicates"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return nil, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return nil, err
+	}
+
+	if s.duplicates == nil {
+		return nil, nil
+	}
+
+	candidates, err := s.duplicateCandidates(ctx, task)
+	if err != nil {
+		log.WithError(err).Error("failed to list duplicate candidates")
+		return nil, err
+	}
+
+	return s.duplicates.Match(task, candidates), nil
+}
+
+// findDuplicate возвращает самую похожую на новую задачу незавершённую задачу кластера;
+// ошибка поиска не мешает созданию задачи
+func (s *TaskService) findDuplicate(ctx context.Context, task models.Task) *duplicates.Match {
+	const op = "TaskService.findDuplicate"
+	log := s.log.WithField("op", op)
+
+	if s.duplicates == nil || task.ClusterID == nil {
+		return nil
+	}
+
+	candidates, err := s.duplicateCandidates(ctx, task)
+	if err != nil {
+		log.WithError(err).Warn("failed to list duplicate candidates")
+		return nil
+	}
+
+	matches := s.duplicates.Match(task, candidates)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	log.WithField("duplicateOf", matches[0].Task.ID).WithField("score", matches[0].Score).Info("possible duplicate found")
+	return &matches[0]
+}
+
+func (s *TaskService) duplicateCandidates(ctx context.Context, task models.Task) ([]models.Task, error) {
+	merged := false
+	page, err := s.taskProvider.QueryTasks(ctx, models.TaskQuery{
+		Statuses:  activeStatuses,
+		ClusterID: task.ClusterID,
+		Merged:    &merged,
+		Sort:      []models.TaskSort{{Field: models.TaskSortCreatedAt, Desc: true}},
+		Limit:     s.duplicates.MaxCandidates(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Tasks, nil
+}
+
+func duplicateEvent(ctx context.Context, taskID int64, match duplicates.Match) models.TaskEvent {
+	event := newTaskEvent(ctx, taskID, models.TaskEventDuplicateFound, nil, idValue(&match.Task.ID))
+	score := strconv.FormatFloat(match.Score, 'f', 2, 64)
+	event.Comment = &score
+	return event
+}
+
+// MergeTasks присоединяет дубликаты того же кластера к родительской задаче: комментарии переносятся
+// в родителя, а дубликаты закрываются вместе с ним
+func (s *TaskService) MergeTasks(ctx context.Context, parentID int64, childIDs []int64, reason string) (models.Task, error) {
+	const op = "TaskService.MergeTasks"
+	log := s.log.WithField("op", op).WithField("parentID", parentID)
+
+	childIDs = uniqueIDs(childIDs)
+	if len(childIDs) == 0 || len(childIDs) > maxBulkSize {
+		return models.Task{}, fmt.Errorf("%s: %d tasks: %w", op, len(childIDs), ErrInvalidBulk)
+	}
+
+	parent, err := s.taskProvider.TaskByID(ctx, parentID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
+	}
+
+	if err := checkVersion(ctx, parent); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+	if parent.MergedIntoID != nil || !isActive(parent.Status) {
+		return models.Task{}, fmt.Errorf("%s: parent %d: %w", op, parentID, ErrMergeNotAllowed)
+	}
+
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		events := make([]models.TaskEvent, 0, len(childIDs))
+		for _, childID := range childIDs {
+			event, err := s.mergeTask(ctx, parent, childID, reason)
+			if err != nil {
+				log.WithError(err).WithField("childID", childID).Warn("failed to merge task")
+				return err
+			}
+			events = append(events, event)
+		}
+
+		// версия родителя увеличивается в той же транзакции, чтобы параллельное закрытие родителя
+		// завершилось конфликтом версий, а не оставило присоединённые дубликаты открытыми
+		if err := s.updateTask(ctx, &parent, events...); err != nil {
+			log.WithError(err).Error("failed to update tasks")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return s.withSLA(parent), nil
+}
+
+// mergeTask присоединяет дубликат к родителю и возвращает событие ChildMerged для журнала родителя
+func (s *TaskService) mergeTask(ctx context.Context, parent models.Task, childID int64, reason string) (models.TaskEvent, error) {
+	const op = "TaskService.mergeTask"
+	parentID := parent.ID
+
+	if childID == parentID {
+		return models.TaskEvent{}, fmt.Errorf("%s: task %d: %w", op, childID, ErrMergeNotAllowed)
+	}
+
+	child, err := s.taskProvider.TaskByID(ctx, childID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			return models.TaskEvent{}, ErrInvalidCredentials
+		}
+		return models.TaskEvent{}, err
+	}
+	if child.MergedIntoID != nil || !isActive(child.Status) {
+		return models.TaskEvent{}, fmt.Errorf("%s: task %d: %w", op, childID, ErrMergeNotAllowed)
+	}
+	if !sameID(child.ClusterID, parent.ClusterID) {
+		return models.TaskEvent{}, fmt.Errorf("%s: task %d is in another cluster: %w", op, childID, ErrMergeNotAllowed)
+	}
+
+	if err := s.commentMover.MoveComments(ctx, childID, parentID); err != nil {
+		return models.TaskEvent{}, err
+	}
+
+	// дубликаты, ранее присоединённые к child, переходят к новому родителю
+	grandchildren, err := s.mergedChildren(ctx, childID)
+	if err != nil {
+		return models.TaskEvent{}, err
+	}
+	for _, grandchild := range grandchildren {
+		event := newTaskEvent(ctx, grandchild.ID, models.TaskEventMerged, idValue(grandchild.MergedIntoID), idValue(&parentID))
+		grandchild.MergedIntoID = &parentID
+		if err := s.updateTask(ctx, &grandchild, event); err != nil {
+			return models.TaskEvent{}, err
+		}
+	}
+
+	event := newTaskEvent(ctx, childID, models.TaskEventMerged, nil, idValue(&parentID))
+	if reason != "" {
+		event.Comment = &reason
+	}
+	child.MergedIntoID = &parentID
+	if err := s.updateTask(ctx, &child, event); err != nil {
+		return models.TaskEvent{}, err
+	}
+
+	return newTaskEvent(ctx, parentID, models.TaskEventChildMerged, nil, idValue(&childID)), nil
+}
+
+// closeMergedChildren переводит присоединённые дубликаты в статус родителя, копируя кейс и решение
+func (s *TaskService) closeMergedChildren(ctx context.Context, tc *transitionContext) error {
+	children, err := s.mergedChildren(ctx, tc.task.ID)
+	if err != nil {
+		return err
+	}
+
+	reason := "merged into task " + strconv.FormatInt(tc.task.ID, 10)
+	for _, child := range children {
+		if !isActive(child.Status) {
+			continue
+		}
+
+		childTC := &transitionContext{
+			task:   &child,
+			from:   child.Status,
+			to:     tc.to,
+			actor:  tc.actor,
+			reason: reason,
+			now:    tc.now,
+		}
+		if err := markCompleted(ctx, childTC); err != nil {
+			return err
+		}
+		if err := s.releaseWorkload(ctx, childTC); err != nil {
+			return err
+		}
+
+		events := []models.TaskEvent{statusEvent(ctx, childTC)}
+		if child.CaseID == nil && tc.task.CaseID != nil {
+			events = append(events, newTaskEvent(ctx, child.ID, models.TaskEventCaseAdded, nil, idValue(tc.task.CaseID)))
+			child.CaseID = tc.task.CaseID
+			child.Case = tc.task.Case
+		}
+		if child.Solution == nil && tc.task.Solution != nil {
+			events = append(events, newTaskEvent(ctx, child.ID, models.TaskEventSolutionAdded, nil, copyValue(tc.task.Solution)))
+			child.Solution = copyValue(tc.task.Solution)
+		}
+		child.Status = tc.to
+		child.StatusReason = &reason
+
+		if err := s.updateTask(ctx, &child, events...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TaskService) mergedChildren(ctx context.Context, parentID int64) ([]models.Task, error) {
+	query := models.TaskQuery{MergedIntoID: &parentID, Limit: maxPageSize}
+
+	var children []models.Task
+	for {
+		page, err := s.taskProvider.QueryTasks(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, page.Tasks...)
+
+		if page.NextCursor == "" {
+			return children, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func isActive(status models.TaskStatus) bool {
+	for _, active := range activeStatuses {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package tasks
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newMergeStore() *fakeStore {
+	cluster, otherCluster := int64(1), int64(2)
+	parentID, childID := int64(1), int64(2)
+
+	store := newFakeStore(
+		models.Task{ID: 1, Status: models.TaskStatusInProgress, ClusterID: &cluster, Version: 1},
+		models.Task{ID: 2, Status: models.TaskStatusOpen, ClusterID: &cluster, Version: 1},
+		models.Task{ID: 3, Status: models.TaskStatusOpen, ClusterID: &cluster, Version: 1},
+		models.Task{ID: 4, Status: models.TaskStatusOpen, ClusterID: &cluster, MergedIntoID: &childID, Version: 1},
+		models.Task{ID: 5, Status: models.TaskStatusOpen, ClusterID: &otherCluster, Version: 1},
+		models.Task{ID: 6, Status: models.TaskStatusClosed, ClusterID: &cluster, Version: 1},
+		models.Task{ID: 7, Status: models.TaskStatusOpen, ClusterID: &cluster, MergedIntoID: &parentID, Version: 1},
+		models.Task{ID: 8, Status: models.TaskStatusOpen, Version: 1},
+	)
+	store.comments[20] = 2
+	store.comments[30] = 3
+	return store
+}
+
+func TestMergeTasks(t *testing.T) {
+	store := newMergeStore()
+	s := newTestService(store, &fakeUsers{})
+
+	parent, err := s.MergeTasks(context.Background(), 1, []int64{2, 3, 2}, "same complaint")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), parent.ID)
+
+	for _, id := range []int64{2, 3, 4} {
+		require.NotNil(t, store.tasks[id].MergedIntoID, "task %d", id)
+		assert.Equal(t, int64(1), *store.tasks[id].MergedIntoID, "task %d", id)
+		assert.Equal(t, models.TaskStatusOpen, store.tasks[id].Status, "task %d", id)
+	}
+	assert.Equal(t, map[int64]int64{20: 1, 30: 1}, store.comments)
+
+	var merged, childMerged int
+	for _, event := range store.events {
+		switch event.Kind {
+		case models.TaskEventMerged:
+			merged++
+		case models.TaskEventChildMerged:
+			childMerged++
+			assert.Equal(t, int64(1), event.TaskID)
+		}
+	}
+	assert.Equal(t, 3, merged, "children and the moved grandchild")
+	assert.Equal(t, 2, childMerged)
+	assert.Equal(t, int64(2), parent.Version)
+	assert.Equal(t, int64(2), store.tasks[1].Version)
+}
+
+func TestMergeTasksConflictsWithConcurrentParentClose(t *testing.T) {
+	store := newMergeStore()
+	s := newTestService(store, &fakeUsers{})
+	s.taskProvider = &racingStore{fakeStore: store, taskID: 1, change: func(task *models.Task) {
+		task.Status = models.TaskStatusClosed
+	}}
+
+	_, err := s.MergeTasks(context.Background(), 1, []int64{2, 3}, "")
+	require.ErrorIs(t, err, ErrVersionConflict)
+
+	for _, id := range []int64{2, 3} {
+		assert.Nil(t, store.tasks[id].MergedIntoID, "task %d", id)
+	}
+	assert.Empty(t, store.events)
+}
+
+func TestMergeTasksRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		parentID int64
+		childIDs []int64
+		wantErr  error
+	}{
+		{name: "no children", parentID: 1, wantErr: ErrInvalidBulk},
+		{name: "parent into itself", parentID: 1, childIDs: []int64{1}, wantErr: ErrMergeNotAllowed},
+		{name: "child of another cluster", parentID: 1, childIDs: []int64{2, 5}, wantErr: ErrMergeNotAllowed},
+		{name: "child without cluster", parentID: 1, childIDs: []int64{3, 8}, wantErr: ErrMergeNotAllowed},
+		{name: "closed child", parentID: 1, childIDs: []int64{2, 6}, wantErr: ErrMergeNotAllowed},
+		{name: "already merged child", parentID: 1, childIDs: []int64{2, 7}, wantErr: ErrMergeNotAllowed},
+		{name: "missing child", parentID: 1, childIDs: []int64{3, 99}, wantErr: ErrInvalidCredentials},
+		{name: "closed parent", parentID: 6, childIDs: []int64{2}, wantErr: ErrMergeNotAllowed},
+		{name: "merged parent", parentID: 4, childIDs: []int64{3}, wantErr: ErrMergeNotAllowed},
+		{name: "missing parent", parentID: 99, childIDs: []int64{2}, wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMergeStore()
+			before := newMergeStore()
+			s := newTestService(store, &fakeUsers{})
+
+			_, err := s.MergeTasks(context.Background(), tt.parentID, tt.childIDs, "")
+			require.ErrorIs(t, err, tt.wantErr)
+
+			assert.Equal(t, before.tasks, store.tasks, "failed merge must be rolled back")
+			assert.Equal(t, before.comments, store.comments)
+			assert.Empty(t, store.events)
+		})
+	}
+}
+
+func TestCloseMergedChildren(t *testing.T) {
+	store := newMergeStore()
+	s := newTestService(store, &fakeUsers{})
+	_, err := s.MergeTasks(context.Background(), 1, []int64{2, 3}, "")
+	require.NoError(t, err)
+
+	agent := &models.User{ID: 7}
+	caseID, solution := int64(11), "restart the router"
+	parent := store.tasks[1]
+	parent.UserID, parent.User = &agent.ID, agent
+	parent.CaseID, parent.Solution = &caseID, &solution
+	store.tasks[1] = parent
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	err = s.applyTransition(context.Background(), &transitionContext{
+		task: &parent,
+		from: parent.Status,
+		to:   models.TaskStatusClosed,
+		now:  now,
+	})
+	require.NoError(t, err)
+
+	for _, id := range []int64{2, 3, 4, 7} {
+		child := store.tasks[id]
+		assert.Equal(t, models.TaskStatusClosed, child.Status, "task %d", id)
+		require.NotNil(t, child.CompletedAt, "task %d", id)
+		assert.Equal(t, now, *child.CompletedAt, "task %d", id)
+		assert.Equal(t, &caseID, child.CaseID, "task %d", id)
+		require.NotNil(t, child.Solution, "task %d", id)
+		assert.Equal(t, solution, *child.Solution, "task %d", id)
+	}
+	assert.Equal(t, models.TaskStatusOpen, store.tasks[5].Status, "unrelated task stays open")
+}
@@ -0,0 +1,182 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"sort"
+	"time"
+)
+
+// Веса составляющих queueScore
+const (
+	scoreFire          = 30
+	scoreAgePerHour    = 1
+	scoreAgeMaxHours   = 24
+	scoreSLAAtRisk     = 15
+	scoreSLABreached   = 40
+	scorePriorityStep  = 12.5
+	scoreRequesterStep = 7.5
+)
+
+// queueBatch размер порции кандидатов, которую queue читает из базы за раз
+const queueBatch = 100
+
+// queueScore вычисляет вес задачи в очереди: чем больше, тем раньше её стоит взять
+func queueScore(task models.Task, now time.Time) float64 {
+	if task.Status != models.TaskStatusOpen && task.Status != models.TaskStatusReopened {
+		return 0
+	}
+
+	score := baseQueueScore(task, now)
+
+	if task.SLA != nil {
+		switch task.SLA.Level {
+		case models.SLALevelAtRisk:
+			score += scoreSLAAtRisk
+		case models.SLALevelBreached:
+			score += scoreSLABreached
+		}
+	}
+
+	return score
+}
+
+// baseQueueScore часть веса без надбавки за SLA; её же считает база данных по queueWeights
+func baseQueueScore(task models.Task, now time.Time) float64 {
+	priority := task.Priority
+	if priority < models.TaskPriorityLow {
+		priority = models.TaskPriorityNormal
+	}
+	score := float64(priority-models.TaskPriorityLow)*scorePriorityStep + float64(task.RequesterTier)*scoreRequesterStep
+
+	if task.Fire {
+		score += scoreFire
+	}
+
+	age := now.Sub(task.CreatedAt).Hours()
+	if age > scoreAgeMaxHours {
+		age = scoreAgeMaxHours
+	}
+	if age > 0 {
+		score += age * scoreAgePerHour
+	}
+
+	return score
+}
+
+func queueWeights(now time.Time) models.QueueWeights {
+	return models.QueueWeights{
+		Now:           now,
+		PriorityStep:  scorePriorityStep,
+		RequesterStep: scoreRequesterStep,
+		Fire:          scoreFire,
+		AgePerHour:    scoreAgePerHour,
+		AgeMaxHours:   scoreAgeMaxHours,
+	}
+}
+
+// ListQueue возвращает открытые и переоткрытые задачи по убыванию веса в очереди, не больше limit
+// (limit <= 0 или больше maxPageSize ограничивается maxPageSize)
+func (s *TaskService) ListQueue(ctx context.Context, clusterID *int64, limit int) ([]models.Task, error) {
+	merged := false
+	return s.queue(ctx, models.TaskQuery{
+		Statuses:  []models.TaskStatus{models.TaskStatusOpen, models.TaskStatusReopened},
+		ClusterID: clusterID,
+		Merged:    &merged,
+	}, limit)
+}
+
+// queue читает кандидатов порциями по убыванию базового веса и досчитывает надбавку за SLA.
+// Чтение останавливается, когда следующие задачи уже не обгонят выбранные даже с максимальной надбавкой
+func (s *TaskService) queue(ctx context.Context, query models.TaskQuery, limit int) ([]models.Task, error) {
+	const op = "TaskService.queue"
+	log := s.log.WithField("op", op)
+
+	if limit <= 0 || limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	now := time.Now()
+	weights := queueWeights(now)
+
+	var tasks []models.Task
+	for offset := 0; ; offset += queueBatch {
+		batch, err := s.taskProvider.QueueTasks(ctx, query, weights, offset, queueBatch)
+		if err != nil {
+			log.WithError(err).Error("failed to list tasks")
+			return nil, err
+		}
+
+		tasks = append(tasks, s.withSLAList(batch)...)
+		sortByScore(tasks)
+		if len(tasks) > limit {
+			tasks = tasks[:limit]
+		}
+
+		if len(batch) < queueBatch {
+			break
+		}
+		if len(tasks) == limit && tasks[limit-1].Score > baseQueueScore(batch[len(batch)-1], now)+scoreSLABreached {
+			break
+		}
+	}
+
+	return tasks, nil
+}
+
+// sortByScore упорядочивает задачи по убыванию веса в очереди, при равенстве по ID
+func sortByScore(tasks []models.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Score != tasks[j].Score {
+			return tasks[i].Score > tasks[j].Score
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// SetTaskPriority меняет приоритет задачи. Уровень клиента задачи с клиентом берётся из его карточки
+// и меняется через SetRequesterTier; tier задает уровень только задачам без клиента
+func (s *TaskService) SetTaskPriority(ctx context.Context, taskID int64, priority models.TaskPriority, tier models.RequesterTier) (models.Task, error) {
+	const op = "TaskService.SetTaskPriority"
+	log := s.log.WithField("op", op).WithField("taskID", taskID)
+
+	if priority < models.TaskPriorityLow || priority > models.TaskPriorityCritical || tier < models.RequesterTierStandard || tier > models.RequesterTierVIP {
+		return models.Task{}, ErrInvalidPriority
+	}
+
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
+	}
+
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	if task.Requester != nil {
+		tier = task.Requester.Tier
+	}
+
+	oldValue := task.Priority.String() + "/" + task.RequesterTier.String()
+	newValue := priority.String() + "/" + tier.String()
+	event := newTaskEvent(ctx, taskID, models.TaskEventPriorityChanged, &oldValue, &newValue)
+	task.Priority = priority
+	task.RequesterTier = tier
+
+	log.WithField("priority", priority).WithField("tier", tier).Info("change task priority")
+	if err := s.updateTask(ctx, &task, event); err != nil {
+		log.WithError(err).Error("failed to update tasks")
+		return models.Task{}, err
+	}
+
+	return s.withSLA(task), nil
+}
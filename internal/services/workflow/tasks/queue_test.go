@@ -0,0 +1,107 @@
+package tasks
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestQueueScore(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	open := func(modify func(task *models.Task)) models.Task {
+		task := models.Task{Status: models.TaskStatusOpen, Priority: models.TaskPriorityNormal, CreatedAt: now}
+		if modify != nil {
+			modify(&task)
+		}
+		return task
+	}
+
+	tests := []struct {
+		name string
+		task models.Task
+		want float64
+	}{
+		{name: "new normal task", task: open(nil), want: scorePriorityStep},
+		{name: "low priority", task: open(func(task *models.Task) { task.Priority = models.TaskPriorityLow }), want: 0},
+		{name: "unset priority counts as normal", task: open(func(task *models.Task) { task.Priority = 0 }), want: scorePriorityStep},
+		{name: "critical", task: open(func(task *models.Task) { task.Priority = models.TaskPriorityCritical }), want: 3 * scorePriorityStep},
+		{name: "vip requester", task: open(func(task *models.Task) { task.RequesterTier = models.RequesterTierVIP }), want: scorePriorityStep + 2*scoreRequesterStep},
+		{name: "fire", task: open(func(task *models.Task) { task.Fire = true }), want: scorePriorityStep + scoreFire},
+		{name: "age", task: open(func(task *models.Task) { task.CreatedAt = now.Add(-3 * time.Hour) }), want: scorePriorityStep + 3*scoreAgePerHour},
+		{name: "age is capped", task: open(func(task *models.Task) { task.CreatedAt = now.Add(-72 * time.Hour) }), want: scorePriorityStep + scoreAgeMaxHours*scoreAgePerHour},
+		{name: "future created at", task: open(func(task *models.Task) { task.CreatedAt = now.Add(time.Hour) }), want: scorePriorityStep},
+		{
+			name: "sla at risk",
+			task: open(func(task *models.Task) { task.SLA = &models.SLAStatus{Level: models.SLALevelAtRisk} }),
+			want: scorePriorityStep + scoreSLAAtRisk,
+		},
+		{
+			name: "sla breached",
+			task: open(func(task *models.Task) { task.SLA = &models.SLAStatus{Level: models.SLALevelBreached} }),
+			want: scorePriorityStep + scoreSLABreached,
+		},
+		{name: "reopened", task: open(func(task *models.Task) { task.Status = models.TaskStatusReopened }), want: scorePriorityStep},
+		{name: "in progress is not queued", task: open(func(task *models.Task) { task.Status = models.TaskStatusInProgress; task.Fire = true }), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, queueScore(tt.task, now), 1e-9)
+		})
+	}
+}
+
+func TestSortByScore(t *testing.T) {
+	tasks := []models.Task{{ID: 3, Score: 1}, {ID: 2, Score: 5}, {ID: 1, Score: 1}, {ID: 4, Score: 5}}
+
+	sortByScore(tasks)
+
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	assert.Equal(t, []int64{2, 4, 1, 3}, ids)
+}
+
+func TestQueueFindsExactTopWithSLABonus(t *testing.T) {
+	now := time.Now()
+	store := newFakeStore()
+	store.sla = fakeSLA{}
+
+	// старые задачи с низким базовым весом, часть из них с нарушенным SLA, которые должны обогнать новые
+	var id int64
+	for i := 0; i < 3*queueBatch; i++ {
+		id++
+		task := models.Task{ID: id, Status: models.TaskStatusOpen, Priority: models.TaskPriorityLow, CreatedAt: now.Add(-time.Duration(i%20) * time.Hour)}
+		store.tasks[id] = task
+		if i%50 == 49 {
+			store.sla[id] = models.SLALevelBreached
+		}
+	}
+	for i := 0; i < queueBatch; i++ {
+		id++
+		store.tasks[id] = models.Task{ID: id, Status: models.TaskStatusOpen, Priority: models.TaskPriorityHigh, CreatedAt: now}
+	}
+	id++
+	store.tasks[id] = models.Task{ID: id, Status: models.TaskStatusInProgress, Priority: models.TaskPriorityCritical, Fire: true, CreatedAt: now}
+
+	s := newTestService(store, &fakeUsers{})
+
+	for _, limit := range []int{1, 5, 20, 150} {
+		got, err := s.ListQueue(context.Background(), nil, limit)
+		require.NoError(t, err)
+
+		all, err := store.QueueTasks(context.Background(), models.TaskQuery{Statuses: []models.TaskStatus{models.TaskStatusOpen}}, queueWeights(now), 0, len(store.tasks))
+		require.NoError(t, err)
+		want := s.withSLAList(all)
+		sortByScore(want)
+
+		require.Len(t, got, limit)
+		for i := range got {
+			assert.Equal(t, want[i].ID, got[i].ID, "limit %d position %d", limit, i)
+		}
+	}
+}
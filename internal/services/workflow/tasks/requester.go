@@ -0,0 +1,151 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"strings"
+)
+
+var ErrInvalidRequester = errors.New("invalid requester")
+
+type RequesterStore interface {
+	EnsureRequester(ctx context.Context, requester models.Requester) (models.Requester, error)
+	RequesterByID(ctx context.Context, id int64) (models.Requester, error)
+	SetRequesterTier(ctx context.Context, id int64, tier models.RequesterTier) (models.Requester, error)
+}
+
+// CreateTaskForRequester создает задачу от имени клиента, заводя клиента при первом обращении.
+// Уровень обслуживания задачи берётся из карточки клиента
+func (s *TaskService) CreateTaskForRequester(ctx context.Context, requester models.Requester, title string, description string, clusterIndex int64, clusterName string, frequency int64, avarage_duration float32) (models.Task, error) {
+	const op = "TaskService.CreateTaskForRequester"
+	log := s.log.WithField("op", op)
+
+	requester, err := s.ensureRequester(ctx, requester)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidRequester) {
+			log.WithError(err).Error("failed to ensure requester")
+		}
+		return models.Task{}, err
+	}
+
+	return s.createTask(ctx, log.WithField("requesterID", requester.ID), &requester, title, description, clusterIndex, clusterName, frequency, avarage_duration)
+}
+
+// ensureRequester заводит клиента при первом обращении; уровень обслуживания меняется только через SetRequesterTier
+func (s *TaskService) ensureRequester(ctx context.Context, requester models.Requester) (models.Requester, error) {
+	requester.ExternalID = strings.TrimSpace(requester.ExternalID)
+	if requester.ExternalID == "" {
+		return models.Requester{}, ErrInvalidRequester
+	}
+	requester.ID = 0
+	requester.Tier = models.RequesterTierStandard
+
+	return s.requesters.EnsureRequester(ctx, requester)
+}
+
+func (s *TaskService) GetRequester(ctx context.Context, id int64) (models.Requester, error) {
+	const op = "TaskService.GetRequester"
+	log := s.log.WithField("op", op).WithField("requesterID", id)
+
+	requester, err := s.requesters.RequesterByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrRequesterNotFound) {
+			log.Warn("requester not found", err)
+			return models.Requester{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get requester")
+		return models.Requester{}, err
+	}
+
+	return requester, nil
+}
+
+// RequesterHistory возвращает все задачи клиента, начиная с новых
+func (s *TaskService) RequesterHistory(ctx context.Context, requesterID int64, cursor string, limit int) (models.TaskPage, error) {
+	if _, err := s.GetRequester(ctx, requesterID); err != nil {
+		return models.TaskPage{}, err
+	}
+
+	return s.QueryTasks(ctx, models.TaskQuery{
+		RequesterID: &requesterID,
+		Sort:        []models.TaskSort{{Field: models.TaskSortCreatedAt, Desc: true}},
+		Cursor:      cursor,
+		Limit:       limit,
+	})
+}
+
+// RequesterOpenTasks возвращает незавершённые задачи клиента, начиная с новых
+func (s *TaskService) RequesterOpenTasks(ctx context.Context, requesterID int64, cursor string, limit int) (models.TaskPage, error) {
+	if _, err := s.GetRequester(ctx, requesterID); err != nil {
+		return models.TaskPage{}, err
+	}
+
+	return s.QueryTasks(ctx, models.TaskQuery{
+		RequesterID: &requesterID,
+		Statuses:    activeStatuses,
+		Sort:        []models.TaskSort{{Field: models.TaskSortCreatedAt, Desc: true}},
+		Cursor:      cursor,
+		Limit:       limit,
+	})
+}
+
+// SetRequesterTier меняет уровень обслуживания клиента и переносит его на незавершённые задачи клиента
+func (s *TaskService) SetRequesterTier(ctx context.Context, requesterID int64, tier models.RequesterTier) (models.Requester, error) {
+	const op = "TaskService.SetRequesterTier"
+	log := s.log.WithField("op", op).WithField("requesterID", requesterID)
+
+	if tier < models.RequesterTierStandard || tier > models.RequesterTierVIP {
+		return models.Requester{}, ErrInvalidPriority
+	}
+
+	var requester models.Requester
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		requester, err = s.requesters.SetRequesterTier(ctx, requesterID, tier)
+		if err != nil {
+			return err
+		}
+
+		query := models.TaskQuery{RequesterID: &requesterID, Statuses: activeStatuses, Limit: maxPageSize}
+		for {
+			page, err := s.taskProvider.QueryTasks(ctx, query)
+			if err != nil {
+				return err
+			}
+
+			for _, task := range page.Tasks {
+				if task.RequesterTier == tier {
+					continue
+				}
+
+				oldValue := task.Priority.String() + "/" + task.RequesterTier.String()
+				newValue := task.Priority.String() + "/" + tier.String()
+				event := newTaskEvent(ctx, task.ID, models.TaskEventPriorityChanged, &oldValue, &newValue)
+				task.RequesterTier = tier
+				if err := s.updateTask(ctx, &task, event); err != nil {
+					return err
+				}
+			}
+
+			if page.NextCursor == "" {
+				return nil
+			}
+			query.Cursor = page.NextCursor
+		}
+	})
+	if err != nil {
+		if errors.Is(err, postgresql.ErrRequesterNotFound) {
+			log.Warn("requester not found", err)
+			return models.Requester{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to set requester tier")
+		return models.Requester{}, err
+	}
+
+	log.WithField("tier", tier).Info("requester tier changed")
+	return requester, nil
+}
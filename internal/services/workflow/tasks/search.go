@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSearchTextLength ограничивает длину поискового запроса в символах
+const maxSearchTextLength = 256
+
+// SearchTasks ищет задачи по тексту с учётом морфологии русского языка;
+// запрос поддерживает синтаксис websearch: "фраза", or, -исключение
+func (s *TaskService) SearchTasks(ctx context.Context, query models.TaskSearchQuery) ([]models.TaskSearchHit, error) {
+	const op = "TaskService.SearchTasks"
+	log := s.log.WithField("op", op)
+
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" || utf8.RuneCountInString(query.Text) > maxSearchTextLength || query.Offset < 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidQuery)
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultPageSize
+	}
+	if query.Limit > maxPageSize {
+		query.Limit = maxPageSize
+	}
+
+	log.WithField("text", query.Text).Info("search tasks")
+	hits, err := s.taskProvider.SearchTasks(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("failed to search tasks")
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Task = s.withSLA(hits[i].Task)
+	}
+
+	return hits, nil
+}
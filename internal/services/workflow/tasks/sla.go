@@ -0,0 +1,23 @@
+package tasks
+
+import (
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"time"
+)
+
+// withSLA заполняет состояние SLA и вес задачи в очереди на текущий момент
+func (s *TaskService) withSLA(task models.Task) models.Task {
+	now := time.Now()
+	task.SLA = s.slaPolicy.Evaluate(task, now)
+	task.Score = queueScore(task, now)
+	return task
+}
+
+func (s *TaskService) withSLAList(tasks []models.Task) []models.Task {
+	now := time.Now()
+	for i := range tasks {
+		tasks[i].SLA = s.slaPolicy.Evaluate(tasks[i], now)
+		tasks[i].Score = queueScore(tasks[i], now)
+	}
+	return tasks
+}
@@ -1,17 +1,18 @@
 package tasks
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
 	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/user"
-	"github.com/markgregr/bestHack_support_gRPC_server/pkg/dataprocessing"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/assignment"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/duplicates"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/labels"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/types/known/emptypb"
-	"net/http"
 	"time"
 )
 
@@ -19,12 +20,26 @@ type TaskService struct {
 	log             *logrus.Logger
 	outputFileData  string
 	inputFileData   string
-	AnalURL         string
 	taskSaver       TaskSaver
 	taskProvider    TaskProvider
 	clusterSaver    ClusterSaver
 	clusterProvider ClusterProvider
 	caseProvider    CaseProvider
+	taskHistory     TaskHistory
+	txManager       TxManager
+	outboxSaver     OutboxSaver
+	taskPublisher   TaskPublisher
+	slaPolicy       SLAPolicy
+	assigner        Assigner
+	dispatcher      TaskDispatcher
+	duplicates      DuplicateDetector
+	commentMover    CommentMover
+	taskLinks       TaskLinkStore
+	taskLabeler     TaskLabeler
+	taskArchive     TaskArchive
+	requesters      RequesterStore
+	taskTemplates   TaskTemplateProvider
+	transitions     map[transitionKey]transition
 
 	userService user.UserService
 }
@@ -37,9 +52,51 @@ type TaskSaver interface {
 
 type TaskProvider interface {
 	TaskByID(ctx context.Context, taskID int64) (models.Task, error)
-	ListTasks(ctx context.Context, status models.TaskStatus) ([]models.Task, error)
-	UserWithMinAverageDuration(ctx context.Context) (models.User, error)
-	ListTasksByUserID(ctx context.Context, userID int64, status models.TaskStatus) ([]models.Task, error)
+	QueryTasks(ctx context.Context, query models.TaskQuery) (models.TaskPage, error)
+	QueueTasks(ctx context.Context, query models.TaskQuery, order models.QueueWeights, offset, limit int) ([]models.Task, error)
+	SearchTasks(ctx context.Context, query models.TaskSearchQuery) ([]models.TaskSearchHit, error)
+}
+
+type TaskHistory interface {
+	SaveTaskEvents(ctx context.Context, events ...models.TaskEvent) error
+	ListTaskEvents(ctx context.Context, taskID int64) ([]models.TaskEvent, error)
+}
+
+type TxManager interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type OutboxSaver interface {
+	SaveOutboxMessages(ctx context.Context, messages ...models.OutboxMessage) error
+}
+
+type TaskPublisher interface {
+	Publish(eventType taskfeed.EventType, task models.Task, previousUserID *int64)
+	Subscribe(ctx context.Context, filter taskfeed.Filter, resumeToken string) (<-chan taskfeed.Event, error)
+}
+
+type SLAPolicy interface {
+	Evaluate(task models.Task, now time.Time) *models.SLAStatus
+}
+
+type Assigner interface {
+	Choose(ctx context.Context, task models.Task, exclude ...int64) (assignment.Decision, error)
+	Peek(ctx context.Context, task models.Task, exclude ...int64) (assignment.Decision, error)
+}
+
+// TaskDispatcher ставит новые задачи в очередь автоматического назначения
+type TaskDispatcher interface {
+	Enqueue(taskID int64) bool
+}
+
+// DuplicateDetector ищет среди задач кластера похожие на новую задачу
+type DuplicateDetector interface {
+	Match(task models.Task, candidates []models.Task) []duplicates.Match
+	MaxCandidates() int
+}
+
+type CommentMover interface {
+	MoveComments(ctx context.Context, fromTaskID, toTaskID int64) error
 }
 
 type ClusterSaver interface {
@@ -57,31 +114,86 @@ type CaseProvider interface {
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidQuery       = errors.New("invalid task query")
+	ErrAlreadyAppointed   = errors.New("user already appointed")
+	ErrInvalidPriority    = errors.New("invalid task priority")
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
 )
 
 type NotficationRequest struct {
 	Username string `json:"username"`
+	TaskID   int64  `json:"task_id,omitempty"`
+	Action   string `json:"action,omitempty"`
+	Note     string `json:"note,omitempty"`
 }
 
-func New(log *logrus.Logger, inputFileData, outputFileData, AnalURL string, taskSaver TaskSaver, taskProvider TaskProvider, clusterProvider ClusterProvider, clusterSaver ClusterSaver, caseProvider CaseProvider, userService user.UserService) *TaskService {
-	return &TaskService{
+// Deps зависимости сервиса задач; Dispatcher и Duplicates необязательны и могут быть nil
+type Deps struct {
+	TaskSaver       TaskSaver
+	TaskProvider    TaskProvider
+	ClusterProvider ClusterProvider
+	ClusterSaver    ClusterSaver
+	CaseProvider    CaseProvider
+	TaskHistory     TaskHistory
+	TxManager       TxManager
+	OutboxSaver     OutboxSaver
+	TaskPublisher   TaskPublisher
+	SLAPolicy       SLAPolicy
+	Assigner        Assigner
+	Dispatcher      TaskDispatcher
+	Duplicates      DuplicateDetector
+	CommentMover    CommentMover
+	TaskLinks       TaskLinkStore
+	TaskLabeler     TaskLabeler
+	TaskArchive     TaskArchive
+	Requesters      RequesterStore
+	TaskTemplates   TaskTemplateProvider
+	UserService     user.UserService
+}
+
+func New(log *logrus.Logger, inputFileData, outputFileData string, deps Deps) *TaskService {
+	s := &TaskService{
 		log:             log,
 		outputFileData:  outputFileData,
 		inputFileData:   inputFileData,
-		AnalURL:         AnalURL,
-		taskSaver:       taskSaver,
-		taskProvider:    taskProvider,
-		clusterSaver:    clusterSaver,
-		clusterProvider: clusterProvider,
-		caseProvider:    caseProvider,
-		userService:     userService,
-	}
+		taskSaver:       deps.TaskSaver,
+		taskProvider:    deps.TaskProvider,
+		clusterProvider: deps.ClusterProvider,
+		clusterSaver:    deps.ClusterSaver,
+		caseProvider:    deps.CaseProvider,
+		taskHistory:     deps.TaskHistory,
+		txManager:       deps.TxManager,
+		outboxSaver:     deps.OutboxSaver,
+		taskPublisher:   deps.TaskPublisher,
+		slaPolicy:       deps.SLAPolicy,
+		assigner:        deps.Assigner,
+		dispatcher:      deps.Dispatcher,
+		duplicates:      deps.Duplicates,
+		commentMover:    deps.CommentMover,
+		taskLinks:       deps.TaskLinks,
+		taskLabeler:     deps.TaskLabeler,
+		taskArchive:     deps.TaskArchive,
+		requesters:      deps.Requesters,
+		taskTemplates:   deps.TaskTemplates,
+		userService:     deps.UserService,
+	}
+	s.transitions = s.transitionTable()
+
+	return s
 }
 
 func (s *TaskService) CreateTask(ctx context.Context, title string, description string, clusterIndex int64, clusterName string, frequency int64, avarage_duration float32) (models.Task, error) {
 	const op = "TaskService.CreateTask"
 	log := s.log.WithField("op", op)
 
+	return s.createTask(ctx, log, nil, title, description, clusterIndex, clusterName, frequency, avarage_duration)
+}
+
+func (s *TaskService) createTask(ctx context.Context, log *logrus.Entry, requester *models.Requester, title string, description string, clusterIndex int64, clusterName string, frequency int64, avarage_duration float32) (models.Task, error) {
 	log.Info("create by index")
 	cluster, err := s.clusterProvider.ClusterByIndex(ctx, clusterIndex)
 	if err != nil {
@@ -107,11 +219,60 @@ func (s *TaskService) CreateTask(ctx context.Context, title string, description
 		ClusterID:       &cluster.ID,
 		Cluster:         &cluster,
 		Fire:            false,
+		Priority:        models.TaskPriorityNormal,
+		RequesterTier:   models.RequesterTierStandard,
+	}
+	if requester != nil {
+		task.RequesterID = &requester.ID
+		task.Requester = requester
+		task.RequesterTier = requester.Tier
+	}
+
+	return s.saveNewTask(ctx, log, task)
+}
+
+// saveNewTask сохраняет новую задачу и её журнал в одной транзакции, отмечает возможный дубликат
+// и после фиксации ставит задачу в очередь распределения; events добавляются в журнал после записи о создании
+func (s *TaskService) saveNewTask(ctx context.Context, log *logrus.Entry, task models.Task, events ...models.TaskEvent) (models.Task, error) {
+	duplicate := s.findDuplicate(ctx, task)
+	if duplicate != nil {
+		task.DuplicateOfID = &duplicate.Task.ID
+		task.DuplicateScore = duplicate.Score
 	}
 
 	log.WithField("task", task).Info("create tasks")
-	task, err = s.taskSaver.SaveTask(ctx, task)
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		saved, err := s.taskSaver.SaveTask(ctx, task)
+		if err != nil {
+			return err
+		}
+
+		history := make([]models.TaskEvent, 0, len(events)+2)
+		history = append(history, newTaskEvent(ctx, saved.ID, models.TaskEventCreated, nil, statusValue(saved.Status)))
+		for _, event := range events {
+			event.TaskID = saved.ID
+			history = append(history, event)
+		}
+		if duplicate != nil {
+			history = append(history, duplicateEvent(ctx, saved.ID, *duplicate))
+		}
+		if err := s.taskHistory.SaveTaskEvents(ctx, history...); err != nil {
+			log.WithError(err).Error("failed to save task event")
+			return err
+		}
+
+		s.publish(ctx, taskfeed.EventCreated, saved, nil)
+		afterCommit(ctx, func() {
+			if s.dispatcher != nil && !s.dispatcher.Enqueue(saved.ID) {
+				log.WithField("taskID", saved.ID).Warn("dispatch queue is full")
+			}
+		})
+
+		task = saved
+		return nil
+	})
 	if err != nil {
+		log.WithError(err).Error("failed to create task")
 		return models.Task{}, err
 	}
 
@@ -126,44 +287,102 @@ func (s *TaskService) GetTask(ctx context.Context, id int64) (models.Task, error
 	task, err := s.taskProvider.TaskByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, postgresql.ErrTaskNotFound) {
-			log.Warn("tasks not found", err)
-			return models.Task{}, ErrInvalidCredentials
+			archived, err := s.archivedTask(ctx, id)
+			if err != nil {
+				if errors.Is(err, ErrInvalidCredentials) {
+					log.Warn("tasks not found", err)
+				} else {
+					log.WithError(err).Error("failed to get archived task")
+				}
+				return models.Task{}, err
+			}
+			return archived, nil
 		}
 
 		log.WithError(err).Error("failed to get tasks")
 		return models.Task{}, err
 	}
 
-	return task, nil
+	return s.withSLA(task), nil
 }
 
-func (s *TaskService) ListTasks(ctx context.Context, status models.TaskStatus) ([]models.Task, error) {
+// ListTasks возвращает задачи в статусе status, у которых есть все метки labelNames
+func (s *TaskService) ListTasks(ctx context.Context, status models.TaskStatus, labelNames ...string) ([]models.Task, error) {
 	const op = "TaskService.ListTasks"
 	log := s.log.WithField("op", op)
 
 	log.Info("list tasks")
-	tasks, err := s.taskProvider.ListTasks(ctx, status)
+	page, err := s.taskProvider.QueryTasks(ctx, models.TaskQuery{Status: &status, Labels: labels.NormalizeNames(labelNames)})
 	if err != nil {
 		log.WithError(err).Error("failed to list tasks")
 		return nil, err
 	}
 
-	return tasks, nil
+	return s.withSLAList(page.Tasks), nil
+}
+
+// QueryTasks возвращает страницу задач по фильтрам и курсору
+func (s *TaskService) QueryTasks(ctx context.Context, query models.TaskQuery) (models.TaskPage, error) {
+	const op = "TaskService.QueryTasks"
+	log := s.log.WithField("op", op)
+
+	if query.Limit <= 0 {
+		query.Limit = defaultPageSize
+	}
+	if query.Limit > maxPageSize {
+		query.Limit = maxPageSize
+	}
+
+	query.Labels = labels.NormalizeNames(query.Labels)
+
+	log.Info("query tasks")
+	page, err := s.taskProvider.QueryTasks(ctx, query)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrInvalidCursor) || errors.Is(err, postgresql.ErrInvalidSortField) {
+			log.Warn("invalid task query", err)
+			return models.TaskPage{}, fmt.Errorf("%s: %w", op, ErrInvalidQuery)
+		}
+
+		log.WithError(err).Error("failed to query tasks")
+		return models.TaskPage{}, err
+	}
+	page.Tasks = s.withSLAList(page.Tasks)
+
+	return page, nil
 }
 
 func (s *TaskService) ChangeTaskStatus(ctx context.Context, taskID int64) (models.Task, error) {
 	const op = "TaskService.ChangeTaskStatus"
 	log := s.log.WithField("op", op)
 
-	userID, ok := ctx.Value("userID").(int64)
-	if !ok {
-		log.Error("failed to get userID from context")
-		return models.Task{}, errors.New("failed to get userID from context")
+	task, err := s.taskProvider.TaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskNotFound) {
+			log.Warn("tasks not found", err)
+			return models.Task{}, ErrInvalidCredentials
+		}
+
+		log.WithError(err).Error("failed to get tasks")
+		return models.Task{}, err
 	}
 
-	user, err := s.userService.GetUserByID(ctx, userID)
+	target, err := nextStatus(task.Status)
 	if err != nil {
-		if errors.Is(err, postgresql.ErrUsersNotFound) {
+		log.WithField("status", task.Status).Warn("task has no next status")
+		return models.Task{}, err
+	}
+
+	return s.TransitionTask(ctx, taskID, target, "")
+}
+
+// TransitionTask переводит задачу в статус target по таблице переходов
+func (s *TaskService) TransitionTask(ctx context.Context, taskID int64, target models.TaskStatus, reason string) (models.Task, error) {
+	const op = "TaskService.TransitionTask"
+	log := s.log.WithField("op", op).WithField("taskID", taskID).WithField("target", target)
+
+	actor, err := s.actorFromContext(ctx)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
 			log.Warn("user not found", err)
 			return models.Task{}, ErrInvalidCredentials
 		}
@@ -182,63 +401,40 @@ func (s *TaskService) ChangeTaskStatus(ctx context.Context, taskID int64) (model
 		return models.Task{}, err
 	}
 
-	switch task.Status {
-	case models.TaskStatusOpen:
-		task.Status = models.TaskStatusInProgress
-
-		task.UserID = &user.ID
-		task.User = &user
-
-		currTime := time.Now()
-		task.FormedAt = &currTime
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
 
-		err = s.userService.UpdateUserAvarageDuration(ctx, user.ID, user.AvarageDuration+task.AvarageDuration)
-		if err != nil {
-			log.WithError(err).Error("failed to update user avarage duration")
-			return models.Task{}, err
-		}
-	case models.TaskStatusInProgress:
-		task.Status = models.TaskStatusClosed
-		currTime := time.Now()
-		task.CompletedAt = &currTime
-
-		// Вычисление времени формирования и времени начала выполнения в секундах
-		formedAtUnix := task.FormedAt.Unix()
-		startedAtUnix := task.CreatedAt.Unix()
-
-		// Вычисление разницы в секундах
-		reactionTimeInSeconds := int(formedAtUnix - startedAtUnix)
-		durationInSeconds := int(currTime.Unix() - formedAtUnix)
-
-		clusterData := dataprocessing.ClusterData{
-			ClusterIndex: int(task.Cluster.ClusterIndex),
-			ReactionTime: reactionTimeInSeconds,
-			DurationTime: durationInSeconds,
-		}
-		err = dataprocessing.AddDataToJSON(s.outputFileData, clusterData, log.Logger)
-		if err != nil {
-			log.WithError(err).Error("failed to add data to JSON")
-			return models.Task{}, err
-		}
-		err = dataprocessing.AvgCsv(s.inputFileData, s.outputFileData, log.Logger)
-		if err != nil {
-			log.WithError(err).Error("failed to calculate average")
-			return models.Task{}, err
+	oldUserID := task.UserID
+	tc := &transitionContext{
+		task:   &task,
+		from:   task.Status,
+		to:     target,
+		actor:  actor,
+		reason: reason,
+		now:    time.Now(),
+	}
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.applyTransition(ctx, tc); err != nil {
+			log.WithError(err).Warn("failed to apply transition")
+			return err
 		}
 
-		err = s.userService.UpdateUserAvarageDuration(ctx, user.ID, user.AvarageDuration-task.AvarageDuration)
-		if err != nil {
-			log.WithError(err).Error("failed to update user avarage duration")
-			return models.Task{}, err
+		events := []models.TaskEvent{statusEvent(ctx, tc)}
+		if !sameID(oldUserID, task.UserID) {
+			events = append(events, newTaskEvent(ctx, taskID, models.TaskEventUserAppointed, idValue(oldUserID), idValue(task.UserID)))
 		}
 
-	default:
-		return models.Task{}, errors.New("invalid task status")
-	}
+		log.WithField("reason", reason).Info("change tasks status")
+		if err := s.updateTask(ctx, &task, events...); err != nil {
+			log.WithError(err).Error("failed to update tasks")
+			return err
+		}
 
-	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
-		log.WithError(err).Error("failed to update tasks")
+		return nil
+	})
+	if err != nil {
 		return models.Task{}, err
 	}
 
@@ -253,7 +449,22 @@ func (s *TaskService) ChangeTaskStatus(ctx context.Context, taskID int64) (model
 		return models.Task{}, err
 	}
 
-	return task, nil
+	return s.withSLA(task), nil
+}
+
+// actorFromContext возвращает пользователя, выполняющего запрос, или nil для системных вызовов
+func (s *TaskService) actorFromContext(ctx context.Context) (*models.User, error) {
+	userID, ok := ctx.Value("userID").(int64)
+	if !ok {
+		return nil, nil
+	}
+
+	actor, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &actor, nil
 }
 
 func (s *TaskService) AddCaseToTask(ctx context.Context, taskID, caseID int64) (models.Task, error) {
@@ -272,6 +483,11 @@ func (s *TaskService) AddCaseToTask(ctx context.Context, taskID, caseID int64) (
 		return models.Task{}, err
 	}
 
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
 	caseItem, err := s.caseProvider.CaseByID(ctx, caseID)
 	if err != nil {
 		if errors.Is(err, postgresql.ErrCaseNotFound) {
@@ -283,11 +499,12 @@ func (s *TaskService) AddCaseToTask(ctx context.Context, taskID, caseID int64) (
 		return models.Task{}, err
 
 	}
+	event := newTaskEvent(ctx, taskID, models.TaskEventCaseAdded, idValue(task.CaseID), idValue(&caseID))
 	task.CaseID = &caseID
 	task.Case = &caseItem
 
 	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
+	if err := s.updateTask(ctx, &task, event); err != nil {
 		log.WithError(err).Error("failed to update tasks")
 		return models.Task{}, err
 	}
@@ -311,10 +528,16 @@ func (s *TaskService) AddSolutionToTask(ctx context.Context, taskID int64, solut
 		return models.Task{}, err
 	}
 
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	event := newTaskEvent(ctx, taskID, models.TaskEventSolutionAdded, copyValue(task.Solution), &solution)
 	task.Solution = &solution
 
 	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
+	if err := s.updateTask(ctx, &task, event); err != nil {
 		log.WithError(err).Error("failed to update tasks")
 		return models.Task{}, err
 	}
@@ -338,11 +561,17 @@ func (s *TaskService) RemoveCaseFromTask(ctx context.Context, taskID int64) (mod
 		return models.Task{}, err
 	}
 
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	event := newTaskEvent(ctx, taskID, models.TaskEventCaseRemoved, idValue(task.CaseID), nil)
 	task.CaseID = nil
 	task.Case = nil
 
 	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
+	if err := s.updateTask(ctx, &task, event); err != nil {
 		log.WithError(err).Error("failed to update tasks")
 		return models.Task{}, err
 	}
@@ -366,10 +595,16 @@ func (s *TaskService) RemoveSolutionFromTask(ctx context.Context, taskID int64)
 		return models.Task{}, err
 	}
 
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	event := newTaskEvent(ctx, taskID, models.TaskEventSolutionRemoved, copyValue(task.Solution), nil)
 	task.Solution = nil
 
 	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
+	if err := s.updateTask(ctx, &task, event); err != nil {
 		log.WithError(err).Error("failed to update tasks")
 		return models.Task{}, err
 	}
@@ -381,12 +616,6 @@ func (s *TaskService) AppointUserToTask(ctx context.Context, taskID int64) (mode
 	const op = "TaskService.AppointUserToTask"
 	log := s.log.WithField("op", op)
 
-	user, err := s.taskProvider.UserWithMinAverageDuration(ctx)
-	if err != nil {
-		log.WithError(err).Error("failed to get user with min avarage duration")
-		return models.Task{}, err
-	}
-
 	task, err := s.taskProvider.TaskByID(ctx, taskID)
 	if err != nil {
 		if errors.Is(err, postgresql.ErrTaskNotFound) {
@@ -398,49 +627,77 @@ func (s *TaskService) AppointUserToTask(ctx context.Context, taskID int64) (mode
 		return models.Task{}, err
 	}
 
-	if task.User != nil {
-		return models.Task{}, errors.New("user already appointed")
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
 	}
 
-	task.UserID = &user.ID
-	task.User = &user
-
-	task.Status = models.TaskStatusInProgress
-	currTime := time.Now()
-	task.FormedAt = &currTime
-
-	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
-		log.WithError(err).Error("failed to update tasks")
-		return models.Task{}, err
+	if task.User != nil {
+		return models.Task{}, ErrAlreadyAppointed
 	}
-	err = s.userService.UpdateUserAvarageDuration(ctx, user.ID, user.AvarageDuration+task.AvarageDuration)
+
+	decision, err := s.assigner.Choose(ctx, task)
 	if err != nil {
-		log.WithError(err).Error("failed to update user avarage duration")
+		if errors.Is(err, assignment.ErrNoCandidates) {
+			log.Warn("no agents available", err)
+			return models.Task{}, err
+		}
+		log.WithError(err).Error("failed to choose assignee")
 		return models.Task{}, err
 	}
+	user := decision.User
 
-	requestBody, err := json.Marshal(NotficationRequest{
-		Username: user.TelegramUsername,
-	})
-	if err != nil {
-		log.WithError(err).Error("failed to marshal request body")
-		return models.Task{}, err
+	tc := &transitionContext{
+		task:     &task,
+		from:     task.Status,
+		to:       models.TaskStatusInProgress,
+		assignee: &user,
+		now:      time.Now(),
 	}
 
-	resp, err := http.Post(s.AnalURL, "application/json", bytes.NewBuffer(requestBody))
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.applyTransition(ctx, tc); err != nil {
+			log.WithError(err).Warn("failed to apply transition")
+			return err
+		}
+
+		events := []models.TaskEvent{
+			newTaskEvent(ctx, taskID, models.TaskEventUserAppointed, nil, idValue(task.UserID)),
+			statusEvent(ctx, tc),
+		}
+
+		log.Info("change tasks status")
+		if err := s.updateTask(ctx, &task, events...); err != nil {
+			log.WithError(err).Error("failed to update tasks")
+			return err
+		}
+
+		if err := s.notifyAssignee(ctx, user); err != nil {
+			log.WithError(err).Error("failed to save notification")
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		log.WithError(err).Error("failed to send request")
 		return models.Task{}, err
 	}
-	defer resp.Body.Close()
 
 	return task, nil
 }
 
 func (s *TaskService) FireTask(ctx context.Context, taskID int64) (models.Task, error) {
-	const op = "TaskService.FireTask"
-	log := s.log.WithField("op", op)
+	return s.setFire(ctx, taskID, true, nil)
+}
+
+// FireTaskWithReason поджигает задачу и сохраняет причину, например нарушение SLA
+func (s *TaskService) FireTaskWithReason(ctx context.Context, taskID int64, reason string) (models.Task, error) {
+	return s.setFire(ctx, taskID, true, &reason)
+}
+
+func (s *TaskService) setFire(ctx context.Context, taskID int64, fire bool, reason *string) (models.Task, error) {
+	const op = "TaskService.setFire"
+	log := s.log.WithField("op", op).WithField("fire", fire)
 
 	task, err := s.taskProvider.TaskByID(ctx, taskID)
 	if err != nil {
@@ -453,29 +710,43 @@ func (s *TaskService) FireTask(ctx context.Context, taskID int64) (models.Task,
 		return models.Task{}, err
 	}
 
-	task.Fire = true
+	if err := checkVersion(ctx, task); err != nil {
+		log.Warn("task version mismatch", err)
+		return models.Task{}, err
+	}
+
+	kind := models.TaskEventFired
+	if !fire {
+		kind = models.TaskEventUnfired
+		reason = nil
+	}
+	event := newTaskEvent(ctx, taskID, kind, boolValue(task.Fire), boolValue(fire))
+	event.Comment = reason
+	task.Fire = fire
+	task.FireReason = reason
 
 	log.Info("change tasks status")
-	if err := s.taskSaver.UpdateTask(ctx, taskID, task); err != nil {
+	if err := s.updateTask(ctx, &task, event); err != nil {
 		log.WithError(err).Error("failed to update tasks")
 		return models.Task{}, err
 	}
 
-	return task, nil
+	return s.withSLA(task), nil
 }
 
-func (s *TaskService) ListTasksByUserID(ctx context.Context, userID int64, status models.TaskStatus) ([]models.Task, error) {
+// ListTasksByUserID возвращает задачи исполнителя в статусе status, у которых есть все метки labelNames
+func (s *TaskService) ListTasksByUserID(ctx context.Context, userID int64, status models.TaskStatus, labelNames ...string) ([]models.Task, error) {
 	const op = "TaskService.ListTasksByUserID"
 	log := s.log.WithField("op", op)
 
 	log.Info("list tasks by user id")
-	tasks, err := s.taskProvider.ListTasksByUserID(ctx, userID, status)
+	page, err := s.taskProvider.QueryTasks(ctx, models.TaskQuery{Status: &status, UserID: &userID, Labels: labels.NormalizeNames(labelNames)})
 	if err != nil {
 		log.WithError(err).Error("failed to list tasks")
 		return nil, err
 	}
 
-	return tasks, nil
+	return s.withSLAList(page.Tasks), nil
 }
 
 func (s *TaskService) ListUsers(ctx context.Context, empty *emptypb.Empty) ([]models.User, error) {
@@ -0,0 +1,69 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/templates"
+)
+
+type TaskTemplateProvider interface {
+	TaskTemplateByID(ctx context.Context, id int64) (models.TaskTemplate, error)
+}
+
+// CreateTaskFromTemplate создает задачу в кластере шаблона, подставляя vars в заголовок и описание;
+// приоритет и кейс берутся из шаблона. Если передан requester, задача привязывается к клиенту
+func (s *TaskService) CreateTaskFromTemplate(ctx context.Context, templateID int64, vars map[string]string, requester *models.Requester) (models.Task, error) {
+	const op = "TaskService.CreateTaskFromTemplate"
+	log := s.log.WithField("op", op).WithField("templateID", templateID)
+
+	template, err := s.taskTemplates.TaskTemplateByID(ctx, templateID)
+	if err != nil {
+		if errors.Is(err, postgresql.ErrTaskTemplateNotFound) {
+			log.Warn("task template not found", err)
+			return models.Task{}, templates.ErrTemplateNotFound
+		}
+
+		log.WithError(err).Error("failed to get task template")
+		return models.Task{}, err
+	}
+
+	title, description, err := templates.Render(template, vars)
+	if err != nil {
+		log.Warn("failed to render task template", err)
+		return models.Task{}, err
+	}
+
+	task := models.Task{
+		Title:         title,
+		Description:   description,
+		Status:        models.TaskStatusOpen,
+		ClusterID:     &template.ClusterID,
+		Cluster:       template.Cluster,
+		Priority:      template.DefaultPriority,
+		RequesterTier: models.RequesterTierStandard,
+	}
+
+	var events []models.TaskEvent
+	if template.DefaultCaseID != nil {
+		task.CaseID = template.DefaultCaseID
+		task.Case = template.DefaultCase
+		events = append(events, newTaskEvent(ctx, 0, models.TaskEventCaseAdded, nil, idValue(template.DefaultCaseID)))
+	}
+
+	if requester != nil {
+		ensured, err := s.ensureRequester(ctx, *requester)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidRequester) {
+				log.WithError(err).Error("failed to ensure requester")
+			}
+			return models.Task{}, err
+		}
+		task.RequesterID = &ensured.ID
+		task.Requester = &ensured
+		task.RequesterTier = ensured.Tier
+	}
+
+	return s.saveNewTask(ctx, log, task, events...)
+}
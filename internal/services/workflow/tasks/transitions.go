@@ -0,0 +1,283 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/pkg/dataprocessing"
+	"time"
+)
+
+var (
+	ErrTransitionNotAllowed = errors.New("task status transition not allowed")
+	ErrReasonRequired       = errors.New("reason is required for this transition")
+	ErrAssigneeRequired     = errors.New("assignee is required for this transition")
+)
+
+// transitionContext содержит состояние одного перехода задачи между статусами
+type transitionContext struct {
+	task     *models.Task
+	from     models.TaskStatus
+	to       models.TaskStatus
+	actor    *models.User
+	assignee *models.User
+	reason   string
+	now      time.Time
+}
+
+type transitionGuard func(ctx context.Context, tc *transitionContext) error
+
+type transitionEffect func(ctx context.Context, tc *transitionContext) error
+
+type transition struct {
+	guards  []transitionGuard
+	effects []transitionEffect
+}
+
+type transitionKey struct {
+	from models.TaskStatus
+	to   models.TaskStatus
+}
+
+// transitionTable описывает все допустимые переходы между статусами задачи
+func (s *TaskService) transitionTable() map[transitionKey]transition {
+	take := transition{
+		guards:  []transitionGuard{requireAssignee},
+		effects: []transitionEffect{assign, markFormed, startEscalation, s.addWorkload},
+	}
+	awaitCustomer := transition{
+		effects: []transitionEffect{pauseSLA},
+	}
+	resumeWork := transition{
+		effects: []transitionEffect{resumeSLA, startEscalation},
+	}
+	park := transition{
+		guards:  []transitionGuard{requireReason},
+		effects: []transitionEffect{pauseSLA},
+	}
+	finish := transition{
+		guards:  []transitionGuard{requireAssigned, s.requireSubtasksClosed, s.requireUnblocked},
+		effects: []transitionEffect{resumeSLA, markCompleted, s.releaseWorkload, s.recordStats, s.closeMergedChildren},
+	}
+	// dismiss закрывает задачу, которую не брали в работу (например, решённую массово общим кейсом);
+	// причина обязательна, статистика кластера не пишется, так как задача не была сформирована
+	dismiss := transition{
+		guards:  []transitionGuard{requireReason, s.requireSubtasksClosed, s.requireUnblocked},
+		effects: []transitionEffect{markCompleted, s.closeMergedChildren},
+	}
+	cancel := transition{
+		guards:  []transitionGuard{requireReason},
+		effects: []transitionEffect{resumeSLA, markCompleted, s.releaseWorkload, s.closeMergedChildren},
+	}
+	// reopen возвращает задачу в очередь без исполнителя: её заново назначают или берут в работу
+	reopen := transition{
+		guards:  []transitionGuard{requireReason},
+		effects: []transitionEffect{clearCompleted, unassign},
+	}
+	release := transition{
+		guards:  []transitionGuard{requireReason, requireAssigned},
+		effects: []transitionEffect{resumeSLA, s.releaseWorkload, unassign},
+	}
+
+	return map[transitionKey]transition{
+		{models.TaskStatusOpen, models.TaskStatusInProgress}:               take,
+		{models.TaskStatusOpen, models.TaskStatusClosed}:                   dismiss,
+		{models.TaskStatusOpen, models.TaskStatusCancelled}:                cancel,
+		{models.TaskStatusInProgress, models.TaskStatusClosed}:             finish,
+		{models.TaskStatusInProgress, models.TaskStatusOnHold}:             park,
+		{models.TaskStatusInProgress, models.TaskStatusWaitingForCustomer}: awaitCustomer,
+		{models.TaskStatusInProgress, models.TaskStatusCancelled}:          cancel,
+		{models.TaskStatusInProgress, models.TaskStatusOpen}:               release,
+		{models.TaskStatusOnHold, models.TaskStatusInProgress}:             resumeWork,
+		{models.TaskStatusOnHold, models.TaskStatusClosed}:                 finish,
+		{models.TaskStatusOnHold, models.TaskStatusCancelled}:              cancel,
+		{models.TaskStatusOnHold, models.TaskStatusOpen}:                   release,
+		{models.TaskStatusWaitingForCustomer, models.TaskStatusInProgress}: resumeWork,
+		{models.TaskStatusWaitingForCustomer, models.TaskStatusClosed}:     finish,
+		{models.TaskStatusWaitingForCustomer, models.TaskStatusCancelled}:  cancel,
+		{models.TaskStatusWaitingForCustomer, models.TaskStatusOpen}:       release,
+		{models.TaskStatusClosed, models.TaskStatusReopened}:               reopen,
+		{models.TaskStatusCancelled, models.TaskStatusReopened}:            reopen,
+		{models.TaskStatusReopened, models.TaskStatusInProgress}:           take,
+		{models.TaskStatusReopened, models.TaskStatusClosed}:               dismiss,
+		{models.TaskStatusReopened, models.TaskStatusCancelled}:            cancel,
+	}
+}
+
+// nextStatus возвращает статус, в который ChangeTaskStatus переводит задачу
+func nextStatus(status models.TaskStatus) (models.TaskStatus, error) {
+	switch status {
+	case models.TaskStatusOpen, models.TaskStatusReopened, models.TaskStatusOnHold, models.TaskStatusWaitingForCustomer:
+		return models.TaskStatusInProgress, nil
+	case models.TaskStatusInProgress:
+		return models.TaskStatusClosed, nil
+	default:
+		return 0, ErrTransitionNotAllowed
+	}
+}
+
+// applyTransition проверяет и выполняет переход, не сохраняя задачу
+func (s *TaskService) applyTransition(ctx context.Context, tc *transitionContext) error {
+	const op = "TaskService.applyTransition"
+
+	tr, ok := s.transitions[transitionKey{from: tc.from, to: tc.to}]
+	if !ok {
+		return fmt.Errorf("%s: %s -> %s: %w", op, tc.from, tc.to, ErrTransitionNotAllowed)
+	}
+
+	for _, guard := range tr.guards {
+		if err := guard(ctx, tc); err != nil {
+			return fmt.Errorf("%s: %s -> %s: %w", op, tc.from, tc.to, err)
+		}
+	}
+
+	for _, effect := range tr.effects {
+		if err := effect(ctx, tc); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	tc.task.Status = tc.to
+	if tc.reason != "" {
+		reason := tc.reason
+		tc.task.StatusReason = &reason
+	} else {
+		tc.task.StatusReason = nil
+	}
+
+	return nil
+}
+
+func requireReason(_ context.Context, tc *transitionContext) error {
+	if tc.reason == "" {
+		return ErrReasonRequired
+	}
+	return nil
+}
+
+func requireAssignee(_ context.Context, tc *transitionContext) error {
+	if tc.assignee == nil && tc.actor == nil {
+		return ErrAssigneeRequired
+	}
+	return nil
+}
+
+func requireAssigned(_ context.Context, tc *transitionContext) error {
+	if tc.task.UserID == nil {
+		return ErrAssigneeRequired
+	}
+	return nil
+}
+
+func assign(_ context.Context, tc *transitionContext) error {
+	user := tc.assignee
+	if user == nil {
+		user = tc.actor
+	}
+
+	tc.task.UserID = &user.ID
+	tc.task.User = user
+	return nil
+}
+
+func unassign(_ context.Context, tc *transitionContext) error {
+	tc.task.UserID = nil
+	tc.task.User = nil
+	return nil
+}
+
+func markFormed(_ context.Context, tc *transitionContext) error {
+	if tc.task.FormedAt == nil {
+		formedAt := tc.now
+		tc.task.FormedAt = &formedAt
+	}
+	return nil
+}
+
+// startEscalation запускает цепочку эскалации заново, когда задача возвращается в работу
+func startEscalation(_ context.Context, tc *transitionContext) error {
+	startedAt := tc.now
+	tc.task.EscalationStartedAt = &startedAt
+	tc.task.EscalationLevel = models.EscalationNone
+	return nil
+}
+
+// pauseSLA останавливает отсчёт SLA выполнения, пока задача ждёт клиента или отложена
+func pauseSLA(_ context.Context, tc *transitionContext) error {
+	if tc.task.PausedAt == nil {
+		pausedAt := tc.now
+		tc.task.PausedAt = &pausedAt
+	}
+	return nil
+}
+
+// resumeSLA добавляет текущую паузу к общему времени пауз задачи
+func resumeSLA(_ context.Context, tc *transitionContext) error {
+	if tc.task.PausedAt != nil {
+		if paused := tc.now.Sub(*tc.task.PausedAt); paused > 0 {
+			tc.task.PausedDuration += paused
+		}
+		tc.task.PausedAt = nil
+	}
+	return nil
+}
+
+func markCompleted(_ context.Context, tc *transitionContext) error {
+	completedAt := tc.now
+	tc.task.CompletedAt = &completedAt
+	return nil
+}
+
+func clearCompleted(_ context.Context, tc *transitionContext) error {
+	tc.task.CompletedAt = nil
+	return nil
+}
+
+func (s *TaskService) addWorkload(ctx context.Context, tc *transitionContext) error {
+	return s.userService.AddUserAvarageDuration(ctx, tc.task.User.ID, tc.task.AvarageDuration)
+}
+
+// releaseWorkload снимает нагрузку задачи с исполнителя, если задача была в работе
+func (s *TaskService) releaseWorkload(ctx context.Context, tc *transitionContext) error {
+	if tc.task.User == nil || tc.from == models.TaskStatusOpen || tc.from == models.TaskStatusReopened {
+		return nil
+	}
+
+	return s.userService.AddUserAvarageDuration(ctx, tc.task.User.ID, -tc.task.AvarageDuration)
+}
+
+// recordStats сохраняет время реакции и выполнения задачи для статистики по кластерам. Файлы статистики
+// не участвуют в транзакции, поэтому запись откладывается до её фиксации
+func (s *TaskService) recordStats(ctx context.Context, tc *transitionContext) error {
+	task := tc.task
+	if task.FormedAt == nil || task.Cluster == nil {
+		return nil
+	}
+
+	clusterData := clusterStats(*task, tc.now)
+	afterCommit(ctx, func() {
+		log := s.log.WithField("op", "TaskService.recordStats").WithField("taskID", task.ID)
+		if err := dataprocessing.AddDataToJSON(s.outputFileData, clusterData, s.log); err != nil {
+			log.WithError(err).Error("failed to record task stats")
+			return
+		}
+		if err := dataprocessing.AvgCsv(s.inputFileData, s.outputFileData, s.log); err != nil {
+			log.WithError(err).Error("failed to update cluster stats")
+		}
+	})
+
+	return nil
+}
+
+// clusterStats время реакции и выполнения задачи в секундах, закрытой в момент completedAt
+func clusterStats(task models.Task, completedAt time.Time) dataprocessing.ClusterData {
+	formedAtUnix := task.FormedAt.Unix()
+	startedAtUnix := task.CreatedAt.Unix()
+
+	return dataprocessing.ClusterData{
+		ClusterIndex: int(task.Cluster.ClusterIndex),
+		ReactionTime: int(formedAtUnix - startedAtUnix),
+		DurationTime: int(completedAt.Unix() - formedAtUnix),
+	}
+}
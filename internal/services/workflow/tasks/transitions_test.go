@@ -0,0 +1,327 @@
+package tasks
+
+import (
+	"context"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNextStatus(t *testing.T) {
+	tests := []struct {
+		from    models.TaskStatus
+		want    models.TaskStatus
+		wantErr error
+	}{
+		{from: models.TaskStatusOpen, want: models.TaskStatusInProgress},
+		{from: models.TaskStatusReopened, want: models.TaskStatusInProgress},
+		{from: models.TaskStatusOnHold, want: models.TaskStatusInProgress},
+		{from: models.TaskStatusWaitingForCustomer, want: models.TaskStatusInProgress},
+		{from: models.TaskStatusInProgress, want: models.TaskStatusClosed},
+		{from: models.TaskStatusClosed, wantErr: ErrTransitionNotAllowed},
+		{from: models.TaskStatusCancelled, wantErr: ErrTransitionNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String(), func(t *testing.T) {
+			got, err := nextStatus(tt.from)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestApplyTransition(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := now.Add(-2 * time.Hour)
+	agent := &models.User{ID: 7}
+
+	open := func() models.Task {
+		return models.Task{ID: 1, Status: models.TaskStatusOpen, CreatedAt: createdAt, AvarageDuration: 30}
+	}
+	inProgress := func() models.Task {
+		formedAt := now.Add(-time.Hour)
+		return models.Task{
+			ID: 1, Status: models.TaskStatusInProgress, CreatedAt: createdAt, FormedAt: &formedAt,
+			AvarageDuration: 30, UserID: &agent.ID, User: agent,
+		}
+	}
+	closed := func() models.Task {
+		task := inProgress()
+		task.Status = models.TaskStatusClosed
+		task.CompletedAt = &now
+		return task
+	}
+	onHold := func() models.Task {
+		task := inProgress()
+		pausedAt := now.Add(-30 * time.Minute)
+		task.Status = models.TaskStatusOnHold
+		task.PausedAt = &pausedAt
+		return task
+	}
+	reopened := func() models.Task {
+		task := open()
+		task.Status = models.TaskStatusReopened
+		return task
+	}
+
+	tests := []struct {
+		name         string
+		task         models.Task
+		to           models.TaskStatus
+		actor        *models.User
+		reason       string
+		links        map[models.TaskLinkKind][]int64
+		wantErr      error
+		wantUser     *int64
+		wantWorkload float32
+		check        func(t *testing.T, task models.Task)
+	}{
+		{
+			name:         "take open task",
+			task:         open(),
+			to:           models.TaskStatusInProgress,
+			actor:        agent,
+			wantUser:     &agent.ID,
+			wantWorkload: 30,
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.FormedAt)
+				assert.Equal(t, now, *task.FormedAt)
+				require.NotNil(t, task.EscalationStartedAt)
+				assert.Equal(t, models.EscalationNone, task.EscalationLevel)
+			},
+		},
+		{
+			name:    "take without assignee",
+			task:    open(),
+			to:      models.TaskStatusInProgress,
+			wantErr: ErrAssigneeRequired,
+		},
+		{
+			name:    "dismiss without reason",
+			task:    open(),
+			to:      models.TaskStatusClosed,
+			wantErr: ErrReasonRequired,
+		},
+		{
+			name:   "dismiss with reason",
+			task:   open(),
+			to:     models.TaskStatusClosed,
+			reason: "solved by case",
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.CompletedAt)
+				require.NotNil(t, task.StatusReason)
+				assert.Equal(t, "solved by case", *task.StatusReason)
+			},
+		},
+		{
+			name:         "finish releases workload",
+			task:         inProgress(),
+			to:           models.TaskStatusClosed,
+			wantUser:     &agent.ID,
+			wantWorkload: -30,
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.CompletedAt)
+				assert.Equal(t, now, *task.CompletedAt)
+				assert.Nil(t, task.StatusReason)
+			},
+		},
+		{
+			name:    "finish with open subtasks",
+			task:    inProgress(),
+			to:      models.TaskStatusClosed,
+			links:   map[models.TaskLinkKind][]int64{models.TaskLinkSubtask: {2}},
+			wantErr: ErrOpenSubtasks,
+		},
+		{
+			name:    "finish while blocked",
+			task:    inProgress(),
+			to:      models.TaskStatusClosed,
+			links:   map[models.TaskLinkKind][]int64{models.TaskLinkBlocks: {3}},
+			wantErr: ErrTaskBlocked,
+		},
+		{
+			name:         "release to open",
+			task:         inProgress(),
+			to:           models.TaskStatusOpen,
+			reason:       "agent is away",
+			wantWorkload: -30,
+		},
+		{
+			name:    "release without reason",
+			task:    inProgress(),
+			to:      models.TaskStatusOpen,
+			wantErr: ErrReasonRequired,
+		},
+		{
+			name:         "finish parked task",
+			task:         onHold(),
+			to:           models.TaskStatusClosed,
+			wantUser:     &agent.ID,
+			wantWorkload: -30,
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.CompletedAt)
+				assert.Nil(t, task.PausedAt)
+				assert.Equal(t, 30*time.Minute, task.PausedDuration)
+			},
+		},
+		{
+			name:    "park without reason",
+			task:    inProgress(),
+			to:      models.TaskStatusOnHold,
+			wantErr: ErrReasonRequired,
+		},
+		{
+			name:     "park with reason",
+			task:     inProgress(),
+			to:       models.TaskStatusOnHold,
+			reason:   "waiting for the bank",
+			wantUser: &agent.ID,
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.StatusReason)
+				assert.Equal(t, "waiting for the bank", *task.StatusReason)
+			},
+		},
+		{
+			name:   "cancel open task",
+			task:   open(),
+			to:     models.TaskStatusCancelled,
+			reason: "spam",
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.CompletedAt)
+			},
+		},
+		{
+			name:         "cancel in progress task",
+			task:         inProgress(),
+			to:           models.TaskStatusCancelled,
+			reason:       "duplicate request",
+			wantUser:     &agent.ID,
+			wantWorkload: -30,
+			check: func(t *testing.T, task models.Task) {
+				require.NotNil(t, task.CompletedAt)
+			},
+		},
+		{
+			name:   "reopen closed task",
+			task:   closed(),
+			to:     models.TaskStatusReopened,
+			reason: "customer came back",
+			check: func(t *testing.T, task models.Task) {
+				assert.Nil(t, task.CompletedAt)
+				assert.Nil(t, task.User)
+			},
+		},
+		{
+			name:         "take reopened task",
+			task:         reopened(),
+			to:           models.TaskStatusInProgress,
+			actor:        agent,
+			wantUser:     &agent.ID,
+			wantWorkload: 30,
+		},
+		{
+			name:    "closed task cannot be taken",
+			task:    closed(),
+			to:      models.TaskStatusInProgress,
+			actor:   agent,
+			wantErr: ErrTransitionNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			if tt.links != nil {
+				store.links = tt.links
+			}
+			users := &fakeUsers{}
+			s := newTestService(store, users)
+
+			task := tt.task
+			from := task.Status
+			err := s.applyTransition(context.Background(), &transitionContext{
+				task:   &task,
+				from:   from,
+				to:     tt.to,
+				actor:  tt.actor,
+				reason: tt.reason,
+				now:    now,
+			})
+
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.task, task, "failed transition must not change the task")
+				assert.Empty(t, users.workload)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.to, task.Status)
+			assert.Equal(t, tt.wantUser, task.UserID)
+			assert.Equal(t, tt.wantWorkload, users.workload[agent.ID])
+			if tt.check != nil {
+				tt.check(t, task)
+			}
+		})
+	}
+}
+
+func TestTransitionTableClosesOnlyFromActiveStatuses(t *testing.T) {
+	s := newTestService(newFakeStore(), &fakeUsers{})
+
+	for key := range s.transitions {
+		if key.to == models.TaskStatusClosed || key.to == models.TaskStatusCancelled {
+			assert.True(t, isActive(key.from), "%s -> %s", key.from, key.to)
+		}
+		if key.to == models.TaskStatusReopened {
+			assert.False(t, isActive(key.from), "%s -> %s", key.from, key.to)
+		}
+	}
+}
+
+func TestSLAPauseAcrossTransitions(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	agent := &models.User{ID: 7}
+	s := newTestService(newFakeStore(), &fakeUsers{})
+	task := models.Task{ID: 1, Status: models.TaskStatusInProgress, FormedAt: &start, UserID: &agent.ID, User: agent}
+
+	steps := []struct {
+		to         models.TaskStatus
+		after      time.Duration
+		reason     string
+		wantPaused bool
+		wantTotal  time.Duration
+	}{
+		{to: models.TaskStatusWaitingForCustomer, after: time.Hour, wantPaused: true},
+		{to: models.TaskStatusInProgress, after: 2 * time.Hour, wantTotal: time.Hour},
+		{to: models.TaskStatusOnHold, after: 3 * time.Hour, reason: "waiting for vendor", wantPaused: true, wantTotal: time.Hour},
+		{to: models.TaskStatusCancelled, after: 5 * time.Hour, reason: "vendor closed the issue", wantTotal: 3 * time.Hour},
+	}
+
+	for _, step := range steps {
+		now := start.Add(step.after)
+		err := s.applyTransition(context.Background(), &transitionContext{
+			task:   &task,
+			from:   task.Status,
+			to:     step.to,
+			reason: step.reason,
+			now:    now,
+		})
+		require.NoError(t, err, step.to.String())
+
+		if step.wantPaused {
+			require.NotNil(t, task.PausedAt, step.to.String())
+			assert.Equal(t, now, *task.PausedAt, step.to.String())
+		} else {
+			assert.Nil(t, task.PausedAt, step.to.String())
+		}
+		assert.Equal(t, step.wantTotal, task.PausedDuration, step.to.String())
+	}
+}
@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
+)
+
+type afterCommitKey struct{}
+
+// inTx выполняет fn в транзакции; действия, отложенные через afterCommit, выполняются только после её фиксации.
+// Если вложенная транзакция откатывается, отложенные в ней действия отбрасываются
+func (s *TaskService) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		mark := len(*hooks)
+		if err := s.txManager.Transaction(ctx, fn); err != nil {
+			*hooks = (*hooks)[:mark]
+			return err
+		}
+		return nil
+	}
+
+	var hooks []func()
+	txCtx := context.WithValue(ctx, afterCommitKey{}, &hooks)
+
+	if err := s.txManager.Transaction(txCtx, fn); err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	return nil
+}
+
+// afterCommit откладывает fn до фиксации текущей транзакции или выполняет сразу, если транзакции нет
+func afterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+
+	fn()
+}
+
+// publish отправляет событие подписчикам после фиксации транзакции; previousUserID - исполнитель до изменения
+func (s *TaskService) publish(ctx context.Context, eventType taskfeed.EventType, task models.Task, previousUserID *int64) {
+	afterCommit(ctx, func() {
+		s.taskPublisher.Publish(eventType, task, previousUserID)
+	})
+}
+
+// notifyAssignee записывает уведомление исполнителю в outbox в текущей транзакции
+func (s *TaskService) notifyAssignee(ctx context.Context, user models.User) error {
+	return s.notifyAgent(ctx, NotficationRequest{
+		Username: user.TelegramUsername,
+	})
+}
+
+// notifyHandoff уведомляет агента о передаче ему задачи или о снятии её с него
+func (s *TaskService) notifyHandoff(ctx context.Context, user models.User, taskID int64, action, note string) error {
+	return s.notifyAgent(ctx, NotficationRequest{
+		Username: user.TelegramUsername,
+		TaskID:   taskID,
+		Action:   action,
+		Note:     note,
+	})
+}
+
+func (s *TaskService) notifyAgent(ctx context.Context, request NotficationRequest) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return s.outboxSaver.SaveOutboxMessages(ctx, models.OutboxMessage{
+		Topic:   models.OutboxTopicAssignmentNotification,
+		Payload: payload,
+		Status:  models.OutboxStatusPending,
+	})
+}
@@ -0,0 +1,143 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAfterCommit(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		run     func(s *TaskService, ctx context.Context, record func(name string)) error
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "without transaction runs immediately",
+			run: func(_ *TaskService, ctx context.Context, record func(name string)) error {
+				afterCommit(ctx, func() { record("hook") })
+				record("after")
+				return nil
+			},
+			want: []string{"hook", "after"},
+		},
+		{
+			name: "runs after commit in order",
+			run: func(s *TaskService, ctx context.Context, record func(name string)) error {
+				return s.inTx(ctx, func(ctx context.Context) error {
+					afterCommit(ctx, func() { record("first") })
+					afterCommit(ctx, func() { record("second") })
+					record("body")
+					return nil
+				})
+			},
+			want: []string{"body", "first", "second"},
+		},
+		{
+			name: "dropped on rollback",
+			run: func(s *TaskService, ctx context.Context, record func(name string)) error {
+				return s.inTx(ctx, func(ctx context.Context) error {
+					afterCommit(ctx, func() { record("hook") })
+					return errFailed
+				})
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "nested transaction defers to the outer commit",
+			run: func(s *TaskService, ctx context.Context, record func(name string)) error {
+				return s.inTx(ctx, func(ctx context.Context) error {
+					if err := s.inTx(ctx, func(ctx context.Context) error {
+						afterCommit(ctx, func() { record("inner") })
+						return nil
+					}); err != nil {
+						return err
+					}
+					record("outer body")
+					return nil
+				})
+			},
+			want: []string{"outer body", "inner"},
+		},
+		{
+			name: "failed nested transaction drops only its hooks",
+			run: func(s *TaskService, ctx context.Context, record func(name string)) error {
+				return s.inTx(ctx, func(ctx context.Context) error {
+					afterCommit(ctx, func() { record("before") })
+					err := s.inTx(ctx, func(ctx context.Context) error {
+						afterCommit(ctx, func() { record("inner") })
+						return errFailed
+					})
+					if !errors.Is(err, errFailed) {
+						return errors.New("nested transaction error is lost")
+					}
+					afterCommit(ctx, func() { record("after") })
+					return nil
+				})
+			},
+			want: []string{"before", "after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(newFakeStore(), &fakeUsers{})
+
+			var got []string
+			err := tt.run(s, context.Background(), func(name string) { got = append(got, name) })
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	task := models.Task{ID: 1, Version: 3}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "no expected version", ctx: context.Background()},
+		{name: "same version", ctx: context.WithValue(context.Background(), expectedVersionKey, int64(3))},
+		{name: "stale version", ctx: context.WithValue(context.Background(), expectedVersionKey, int64(2)), wantErr: ErrVersionMismatch},
+		{name: "removed for bulk", ctx: withoutExpectedVersion(context.WithValue(context.Background(), expectedVersionKey, int64(2)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVersion(tt.ctx, task)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUpdateTaskVersionConflict(t *testing.T) {
+	store := newFakeStore(models.Task{ID: 1, Version: 2})
+	s := newTestService(store, &fakeUsers{})
+
+	stale := models.Task{ID: 1, Version: 1, Title: "stale"}
+	err := s.updateTask(context.Background(), &stale)
+	require.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, int64(1), stale.Version, "task is not changed on conflict")
+
+	current := models.Task{ID: 1, Version: 2, Title: "current"}
+	require.NoError(t, s.updateTask(context.Background(), &current))
+	assert.Equal(t, int64(3), current.Version)
+	assert.Equal(t, "current", store.tasks[1].Title)
+}
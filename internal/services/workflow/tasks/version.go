@@ -0,0 +1,36 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+)
+
+var (
+	// ErrVersionMismatch клиент передал версию, которая уже устарела к началу запроса
+	ErrVersionMismatch = errors.New("task version mismatch")
+	// ErrVersionConflict задачу изменили параллельно во время выполнения запроса, запрос можно повторить
+	ErrVersionConflict = errors.New("task was modified concurrently")
+)
+
+// expectedVersionKey ключ контекста, в который gmiddleware кладёт ожидаемую клиентом версию задачи
+const expectedVersionKey = "expectedVersion"
+
+// checkVersion сравнивает версию задачи с версией, которую клиент передал в запросе
+func checkVersion(ctx context.Context, task models.Task) error {
+	expected, ok := ctx.Value(expectedVersionKey).(int64)
+	if !ok || expected == task.Version {
+		return nil
+	}
+
+	return fmt.Errorf("%w: task %d has version %d, expected %d", ErrVersionMismatch, task.ID, task.Version, expected)
+}
+
+// withoutExpectedVersion убирает ожидаемую версию из контекста для операций над несколькими задачами
+func withoutExpectedVersion(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(expectedVersionKey).(int64); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, expectedVersionKey, nil)
+}
@@ -0,0 +1,27 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/services/workflow/taskfeed"
+)
+
+// WatchTasks подписывает на события задач; resumeToken последнего полученного события позволяет продолжить с него
+func (s *TaskService) WatchTasks(ctx context.Context, filter taskfeed.Filter, resumeToken string) (<-chan taskfeed.Event, error) {
+	const op = "TaskService.WatchTasks"
+	log := s.log.WithField("op", op).WithField("resumeToken", resumeToken)
+
+	log.Info("watch tasks")
+	events, err := s.taskPublisher.Subscribe(ctx, filter, resumeToken)
+	if err != nil {
+		if errors.Is(err, taskfeed.ErrSequenceExpired) || errors.Is(err, taskfeed.ErrInvalidResumeToken) {
+			log.Warn("sequence expired", err)
+			return nil, err
+		}
+
+		log.WithError(err).Error("failed to subscribe to tasks")
+		return nil, err
+	}
+
+	return events, nil
+}
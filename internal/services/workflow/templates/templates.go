@@ -0,0 +1,221 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/postgresql"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+const (
+	maxNameLength  = 100
+	maxTitleLength = 255
+)
+
+var (
+	ErrInvalidTemplate  = errors.New("invalid task template")
+	ErrMissingVariable  = errors.New("missing template variable")
+	ErrTemplateNotFound = errors.New("task template not found")
+	ErrTemplateExists   = errors.New("task template already exists")
+	ErrClusterNotFound  = errors.New("cluster not found")
+	ErrCaseNotFound     = errors.New("default case not found in template cluster")
+	ErrInvalidPriority  = errors.New("invalid template priority")
+)
+
+type TemplateService struct {
+	log           *logrus.Logger
+	templateStore TemplateStore
+}
+
+type TemplateStore interface {
+	SaveTaskTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error)
+	UpdateTaskTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error)
+	DeleteTaskTemplate(ctx context.Context, id int64) error
+	TaskTemplateByID(ctx context.Context, id int64) (models.TaskTemplate, error)
+	ListTaskTemplates(ctx context.Context, clusterID *int64) ([]models.TaskTemplate, error)
+}
+
+func New(log *logrus.Logger, templateStore TemplateStore) *TemplateService {
+	return &TemplateService{
+		log:           log,
+		templateStore: templateStore,
+	}
+}
+
+func (s *TemplateService) CreateTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error) {
+	const op = "TemplateService.CreateTemplate"
+	log := s.log.WithField("op", op).WithField("clusterID", template.ClusterID)
+
+	template.ID = 0
+	template, err := validate(template)
+	if err != nil {
+		return models.TaskTemplate{}, err
+	}
+
+	log.WithField("name", template.Name).Info("create task template")
+	template, err = s.templateStore.SaveTaskTemplate(ctx, template)
+	if err != nil {
+		return models.TaskTemplate{}, storeError(log, err, "failed to save task template")
+	}
+
+	return template, nil
+}
+
+func (s *TemplateService) UpdateTemplate(ctx context.Context, template models.TaskTemplate) (models.TaskTemplate, error) {
+	const op = "TemplateService.UpdateTemplate"
+	log := s.log.WithField("op", op).WithField("templateID", template.ID)
+
+	template, err := validate(template)
+	if err != nil {
+		return models.TaskTemplate{}, err
+	}
+
+	log.Info("update task template")
+	template, err = s.templateStore.UpdateTaskTemplate(ctx, template)
+	if err != nil {
+		return models.TaskTemplate{}, storeError(log, err, "failed to update task template")
+	}
+
+	return template, nil
+}
+
+func (s *TemplateService) DeleteTemplate(ctx context.Context, id int64) error {
+	const op = "TemplateService.DeleteTemplate"
+	log := s.log.WithField("op", op).WithField("templateID", id)
+
+	log.Info("delete task template")
+	if err := s.templateStore.DeleteTaskTemplate(ctx, id); err != nil {
+		return storeError(log, err, "failed to delete task template")
+	}
+
+	return nil
+}
+
+func (s *TemplateService) GetTemplate(ctx context.Context, id int64) (models.TaskTemplate, error) {
+	const op = "TemplateService.GetTemplate"
+	log := s.log.WithField("op", op).WithField("templateID", id)
+
+	template, err := s.templateStore.TaskTemplateByID(ctx, id)
+	if err != nil {
+		return models.TaskTemplate{}, storeError(log, err, "failed to get task template")
+	}
+
+	return template, nil
+}
+
+// ListTemplates возвращает шаблоны кластера clusterID или все шаблоны, если clusterID не задан
+func (s *TemplateService) ListTemplates(ctx context.Context, clusterID *int64) ([]models.TaskTemplate, error) {
+	const op = "TemplateService.ListTemplates"
+	log := s.log.WithField("op", op)
+
+	templates, err := s.templateStore.ListTaskTemplates(ctx, clusterID)
+	if err != nil {
+		log.WithError(err).Error("failed to list task templates")
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// Render подставляет переменные vars в заголовок и описание шаблона. Переменная, которой нет в vars,
+// считается ошибкой, чтобы в задачу не попадали незаполненные места
+func Render(template models.TaskTemplate, vars map[string]string) (title string, description string, err error) {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+
+	title, err = execute("title", template.TitlePattern, vars)
+	if err != nil {
+		return "", "", err
+	}
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		return "", "", fmt.Errorf("%w: rendered title is empty", ErrMissingVariable)
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength-1]) + "…"
+	}
+
+	description, err = execute("description", template.DescriptionScaffold, vars)
+	if err != nil {
+		return "", "", err
+	}
+
+	return title, strings.TrimSpace(description), nil
+}
+
+func execute(name, text string, vars map[string]string) (string, error) {
+	parsed, err := parse(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := parsed.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMissingVariable, err)
+	}
+
+	return buf.String(), nil
+}
+
+func parse(name, text string) (*template.Template, error) {
+	parsed, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidTemplate, name, err)
+	}
+	return parsed, nil
+}
+
+func validate(template models.TaskTemplate) (models.TaskTemplate, error) {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" || utf8.RuneCountInString(template.Name) > maxNameLength {
+		return models.TaskTemplate{}, fmt.Errorf("%w: invalid name", ErrInvalidTemplate)
+	}
+
+	template.TitlePattern = strings.TrimSpace(template.TitlePattern)
+	if template.TitlePattern == "" {
+		return models.TaskTemplate{}, fmt.Errorf("%w: empty title pattern", ErrInvalidTemplate)
+	}
+	if _, err := parse("title", template.TitlePattern); err != nil {
+		return models.TaskTemplate{}, err
+	}
+	if _, err := parse("description", template.DescriptionScaffold); err != nil {
+		return models.TaskTemplate{}, err
+	}
+
+	if template.DefaultPriority == 0 {
+		template.DefaultPriority = models.TaskPriorityNormal
+	}
+	if template.DefaultPriority < models.TaskPriorityLow || template.DefaultPriority > models.TaskPriorityCritical {
+		return models.TaskTemplate{}, ErrInvalidPriority
+	}
+
+	template.Cluster, template.DefaultCase = nil, nil
+	return template, nil
+}
+
+func storeError(log *logrus.Entry, err error, message string) error {
+	switch {
+	case errors.Is(err, postgresql.ErrTaskTemplateNotFound):
+		log.Warn("task template not found", err)
+		return ErrTemplateNotFound
+	case errors.Is(err, postgresql.ErrTaskTemplateExists):
+		log.Warn("task template already exists", err)
+		return ErrTemplateExists
+	case errors.Is(err, postgresql.ErrClusterNotFound):
+		log.Warn("cluster not found", err)
+		return ErrClusterNotFound
+	case errors.Is(err, postgresql.ErrCaseNotFound):
+		log.Warn("default case not found in cluster", err)
+		return ErrCaseNotFound
+	}
+
+	log.WithError(err).Error(message)
+	return err
+}
@@ -0,0 +1,138 @@
+package templates
+
+import (
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name            string
+		template        models.TaskTemplate
+		vars            map[string]string
+		wantTitle       string
+		wantDescription string
+		wantErr         error
+	}{
+		{
+			name: "all variables",
+			template: models.TaskTemplate{
+				TitlePattern:        "Сбой оплаты у {{.client}}",
+				DescriptionScaffold: "\nКлиент: {{.client}}\nСумма: {{.amount}}\n",
+			},
+			vars:            map[string]string{"client": "ООО Ромашка", "amount": "100"},
+			wantTitle:       "Сбой оплаты у ООО Ромашка",
+			wantDescription: "Клиент: ООО Ромашка\nСумма: 100",
+		},
+		{
+			name:      "whitespace in title collapsed",
+			template:  models.TaskTemplate{TitlePattern: "  Заявка\n{{.id}}  "},
+			vars:      map[string]string{"id": " 42\t"},
+			wantTitle: "Заявка 42",
+		},
+		{
+			name:      "extra variables ignored",
+			template:  models.TaskTemplate{TitlePattern: "Заявка"},
+			vars:      map[string]string{"id": "42"},
+			wantTitle: "Заявка",
+		},
+		{
+			name:     "missing title variable",
+			template: models.TaskTemplate{TitlePattern: "Заявка {{.id}}"},
+			vars:     map[string]string{"client": "ООО Ромашка"},
+			wantErr:  ErrMissingVariable,
+		},
+		{
+			name:     "missing variable with nil vars",
+			template: models.TaskTemplate{TitlePattern: "Заявка {{.id}}"},
+			wantErr:  ErrMissingVariable,
+		},
+		{
+			name:     "missing description variable",
+			template: models.TaskTemplate{TitlePattern: "Заявка", DescriptionScaffold: "Клиент: {{.client}}"},
+			vars:     map[string]string{},
+			wantErr:  ErrMissingVariable,
+		},
+		{
+			name:     "empty rendered title",
+			template: models.TaskTemplate{TitlePattern: "{{.id}}"},
+			vars:     map[string]string{"id": "  "},
+			wantErr:  ErrMissingVariable,
+		},
+		{
+			name:     "broken pattern",
+			template: models.TaskTemplate{TitlePattern: "Заявка {{.id"},
+			vars:     map[string]string{"id": "42"},
+			wantErr:  ErrInvalidTemplate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, description, err := Render(tt.template, tt.vars)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, title)
+				assert.Empty(t, description)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTitle, title)
+			assert.Equal(t, tt.wantDescription, description)
+		})
+	}
+}
+
+func TestRenderTruncatesLongTitle(t *testing.T) {
+	template := models.TaskTemplate{TitlePattern: "{{.text}}"}
+
+	title, _, err := Render(template, map[string]string{"text": strings.Repeat("я", maxTitleLength+10)})
+
+	require.NoError(t, err)
+	assert.Equal(t, maxTitleLength, utf8.RuneCountInString(title))
+	assert.True(t, strings.HasSuffix(title, "…"))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     models.TaskTemplate
+		wantPriority models.TaskPriority
+		wantErr      error
+	}{
+		{
+			name:         "default priority",
+			template:     models.TaskTemplate{Name: " Оплата ", TitlePattern: " Сбой {{.client}} "},
+			wantPriority: models.TaskPriorityNormal,
+		},
+		{
+			name:         "explicit priority",
+			template:     models.TaskTemplate{Name: "Оплата", TitlePattern: "Сбой", DefaultPriority: models.TaskPriorityCritical},
+			wantPriority: models.TaskPriorityCritical,
+		},
+		{name: "empty name", template: models.TaskTemplate{Name: " ", TitlePattern: "Сбой"}, wantErr: ErrInvalidTemplate},
+		{name: "long name", template: models.TaskTemplate{Name: strings.Repeat("я", maxNameLength+1), TitlePattern: "Сбой"}, wantErr: ErrInvalidTemplate},
+		{name: "empty title", template: models.TaskTemplate{Name: "Оплата", TitlePattern: " "}, wantErr: ErrInvalidTemplate},
+		{name: "broken title", template: models.TaskTemplate{Name: "Оплата", TitlePattern: "{{.client"}, wantErr: ErrInvalidTemplate},
+		{name: "broken description", template: models.TaskTemplate{Name: "Оплата", TitlePattern: "Сбой", DescriptionScaffold: "{{end}}"}, wantErr: ErrInvalidTemplate},
+		{name: "invalid priority", template: models.TaskTemplate{Name: "Оплата", TitlePattern: "Сбой", DefaultPriority: models.TaskPriorityCritical + 1}, wantErr: ErrInvalidPriority},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validate(tt.template)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, strings.TrimSpace(tt.template.Name), got.Name)
+			assert.Equal(t, strings.TrimSpace(tt.template.TitlePattern), got.TitlePattern)
+			assert.Equal(t, tt.wantPriority, got.DefaultPriority)
+		})
+	}
+}
@@ -23,6 +23,12 @@ type ClusterStats struct {
 	Count         int
 }
 
+// Медианы времени реакции и выполнения кластера в секундах
+type ClusterMedians struct {
+	MedianDuration float64
+	MedianReaction float64
+}
+
 // Helper functions
 func median(numbers []int) float64 {
 	sort.Ints(numbers)
@@ -114,7 +120,7 @@ func AvgCsv(inputFile, outputFile string, log *logrus.Logger) error {
 	writer := csv.NewWriter(outFile)
 	defer writer.Flush()
 
-	header := []string{"ClusterIndex", "AvgDuration", "MedianDuration", "StdDevDuration", "AvgReaction", "MedianReaction", "StdDevReaction"}
+	header := []string{"ClusterIndex", "AvgDuration", "MedianDuration", "AvgReaction", "MedianReaction"}
 	if err := writer.Write(header); err != nil {
 		log.WithError(err).Error("failed to write header")
 		return err
@@ -143,3 +149,62 @@ func AvgCsv(inputFile, outputFile string, log *logrus.Logger) error {
 	log.Info("Statistical data saved to CSV for analysis")
 	return nil
 }
+
+// ReadClusterMedians читает медианы по кластерам из CSV, записанного AvgCsv
+func ReadClusterMedians(outputFile string) (map[int]ClusterMedians, error) {
+	file, err := os.Open(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return map[int]ClusterMedians{}, nil
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+
+	clusterCol, ok1 := columns["ClusterIndex"]
+	durationCol, ok2 := columns["MedianDuration"]
+	reactionCol, ok3 := columns["MedianReaction"]
+	if !ok1 || !ok2 || !ok3 {
+		return map[int]ClusterMedians{}, nil
+	}
+
+	medians := make(map[int]ClusterMedians, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) <= clusterCol || len(record) <= durationCol || len(record) <= reactionCol {
+			continue
+		}
+
+		cluster, err := strconv.Atoi(record[clusterCol])
+		if err != nil {
+			continue
+		}
+		duration, err := strconv.ParseFloat(record[durationCol], 64)
+		if err != nil {
+			continue
+		}
+		reaction, err := strconv.ParseFloat(record[reactionCol], 64)
+		if err != nil {
+			continue
+		}
+
+		medians[cluster] = ClusterMedians{
+			MedianDuration: duration,
+			MedianReaction: reaction,
+		}
+	}
+
+	return medians, nil
+}
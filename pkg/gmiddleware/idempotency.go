@@ -0,0 +1,316 @@
+package gmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/redis"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// IdempotencyKeyHeader заголовок метаданных с ключом идемпотентности запроса
+const IdempotencyKeyHeader = "idempotency-key"
+
+const maxIdempotencyKeyLength = 255
+
+type IdempotencyStore interface {
+	IdempotentResponse(ctx context.Context, key string) ([]byte, error)
+	LockIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	ExtendIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	UnlockIdempotencyKey(ctx context.Context, key string) error
+	SaveIdempotentResponse(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+type Idempotency struct {
+	log     *logrus.Logger
+	store   IdempotencyStore
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+// idempotentRecord сохранённый ответ и его заголовки вместе с отпечатком запроса, на который он был дан
+type idempotentRecord struct {
+	Method      string              `json:"method"`
+	RequestHash string              `json:"request_hash"`
+	Response    []byte              `json:"response"`
+	Header      map[string][]string `json:"header,omitempty"`
+}
+
+// NewIdempotencyInterceptor создает интерсептор, который хранит успешные ответы ttl,
+// а lockTTL ограничивает время, на которое запрос захватывает ключ
+func NewIdempotencyInterceptor(log *logrus.Logger, store IdempotencyStore, ttl, lockTTL time.Duration) *Idempotency {
+	return &Idempotency{
+		log:     log,
+		store:   store,
+		ttl:     ttl,
+		lockTTL: lockTTL,
+	}
+}
+
+// UnaryServerInterceptor повторяет сохранённый ответ для запросов с уже использованным ключом
+// идемпотентности. Интерсептор применяется только к перечисленным методам methods и только
+// к запросам авторизованного пользователя, поэтому ставится после авторизации; ключ действует
+// в пределах приложения, пользователя и метода. Ошибки не сохраняются, и такой запрос можно
+// повторить с тем же ключом
+func (i *Idempotency) UnaryServerInterceptor(methods ...string) grpc.UnaryServerInterceptor {
+	idempotent := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		idempotent[method] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !idempotent[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md := metautils.ExtractIncoming(ctx)
+		key := md.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is too long", IdempotencyKeyHeader)
+		}
+
+		userID, ok := ctx.Value("userID").(int64)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		message, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		requestHash, err := hashRequest(message)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+
+		const op = "Idempotency.UnaryServerInterceptor"
+		log := i.log.WithField("op", op).WithField("method", info.FullMethod).WithField("key", key)
+
+		storeKey := idempotencyStoreKey(md.Get("app_id"), userID, info.FullMethod, key)
+
+		if resp, found, err := i.replay(ctx, storeKey, info.FullMethod, requestHash); found || err != nil {
+			return resp, err
+		}
+
+		locked, err := i.store.LockIdempotencyKey(ctx, storeKey, i.lockTTL)
+		if err != nil {
+			log.WithError(err).Error("failed to lock idempotency key")
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		if !locked {
+			return nil, status.Error(codes.Aborted, "request with this idempotency key is in progress")
+		}
+
+		// ответ мог быть сохранён между проверкой и захватом ключа
+		if resp, found, err := i.replay(ctx, storeKey, info.FullMethod, requestHash); found || err != nil {
+			i.unlock(ctx, log, storeKey)
+			return resp, err
+		}
+
+		stopKeepAlive := i.keepLocked(ctx, log, storeKey)
+		stream := &headerRecorder{ServerTransportStream: grpc.ServerTransportStreamFromContext(ctx)}
+		resp, err := handler(grpc.NewContextWithServerTransportStream(ctx, stream), req)
+		stopKeepAlive()
+		if err != nil {
+			i.unlock(ctx, log, storeKey)
+			return nil, err
+		}
+
+		data, err := marshalRecord(info.FullMethod, requestHash, resp, stream.recorded())
+		if err != nil {
+			log.WithError(err).Warn("failed to marshal idempotent response")
+			i.unlock(ctx, log, storeKey)
+			return resp, nil
+		}
+		if err := i.store.SaveIdempotentResponse(ctx, storeKey, data, i.ttl); err != nil {
+			log.WithError(err).Warn("failed to save idempotent response")
+			i.unlock(ctx, log, storeKey)
+		}
+
+		return resp, nil
+	}
+}
+
+func idempotencyStoreKey(appID string, userID int64, method, key string) string {
+	return "idempotency:" + appID + ":" + strconv.FormatInt(userID, 10) + ":" + method + ":" + key
+}
+
+// keepLocked продлевает захват ключа, пока выполняется обработчик, чтобы долгий запрос не выполнился
+// повторно после истечения lockTTL; возвращённая функция останавливает продление
+func (i *Idempotency) keepLocked(ctx context.Context, log *logrus.Entry, key string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(i.lockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				extended, err := i.store.ExtendIdempotencyKey(context.WithoutCancel(ctx), key, i.lockTTL)
+				if err != nil {
+					log.WithError(err).Warn("failed to extend idempotency key lock")
+					continue
+				}
+				if !extended {
+					log.Warn("idempotency key lock expired")
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// headerRecorder запоминает заголовки ответа, которые выставил обработчик, чтобы повторить их при replay
+type headerRecorder struct {
+	grpc.ServerTransportStream
+	mu     sync.Mutex
+	header metadata.MD
+}
+
+func (r *headerRecorder) SetHeader(md metadata.MD) error {
+	r.record(md)
+	if r.ServerTransportStream == nil {
+		return nil
+	}
+	return r.ServerTransportStream.SetHeader(md)
+}
+
+func (r *headerRecorder) SendHeader(md metadata.MD) error {
+	r.record(md)
+	if r.ServerTransportStream == nil {
+		return nil
+	}
+	return r.ServerTransportStream.SendHeader(md)
+}
+
+func (r *headerRecorder) SetTrailer(md metadata.MD) error {
+	if r.ServerTransportStream == nil {
+		return nil
+	}
+	return r.ServerTransportStream.SetTrailer(md)
+}
+
+func (r *headerRecorder) Method() string {
+	if r.ServerTransportStream == nil {
+		return ""
+	}
+	return r.ServerTransportStream.Method()
+}
+
+func (r *headerRecorder) record(md metadata.MD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.header = metadata.Join(r.header, md)
+}
+
+func (r *headerRecorder) recorded() metadata.MD {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.header.Copy()
+}
+
+// replay возвращает сохранённый ответ, если он есть
+func (i *Idempotency) replay(ctx context.Context, key, method, requestHash string) (interface{}, bool, error) {
+	data, err := i.store.IdempotentResponse(ctx, key)
+	if err != nil {
+		if errors.Is(err, redis.ErrIdempotencyKeyNotFound) {
+			return nil, false, nil
+		}
+		i.log.WithError(err).Error("failed to get idempotent response")
+		return nil, false, status.Error(codes.Internal, "internal error")
+	}
+
+	var record idempotentRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		i.log.WithError(err).Error("failed to unmarshal idempotent response")
+		return nil, false, status.Error(codes.Internal, "internal error")
+	}
+	if record.Method != method || record.RequestHash != requestHash {
+		return nil, true, status.Errorf(codes.InvalidArgument, "%s was already used for another request", IdempotencyKeyHeader)
+	}
+
+	var response anypb.Any
+	if err := proto.Unmarshal(record.Response, &response); err != nil {
+		i.log.WithError(err).Error("failed to unmarshal idempotent response")
+		return nil, false, status.Error(codes.Internal, "internal error")
+	}
+	resp, err := response.UnmarshalNew()
+	if err != nil {
+		i.log.WithError(err).Error("failed to unmarshal idempotent response")
+		return nil, false, status.Error(codes.Internal, "internal error")
+	}
+
+	if len(record.Header) > 0 {
+		if err := grpc.SetHeader(ctx, metadata.MD(record.Header)); err != nil {
+			i.log.WithError(err).Warn("failed to set idempotent response header")
+		}
+	}
+
+	i.log.WithField("method", method).Info("replay idempotent response")
+	return resp, true, nil
+}
+
+func (i *Idempotency) unlock(ctx context.Context, log *logrus.Entry, key string) {
+	if err := i.store.UnlockIdempotencyKey(ctx, key); err != nil {
+		log.WithError(err).Warn("failed to unlock idempotency key")
+	}
+}
+
+func hashRequest(req proto.Message) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func marshalRecord(method, requestHash string, resp interface{}, header metadata.MD) ([]byte, error) {
+	message, ok := resp.(proto.Message)
+	if !ok {
+		return nil, errors.New("response is not a proto message")
+	}
+
+	response, err := anypb.New(message)
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(idempotentRecord{
+		Method:      method,
+		RequestHash: requestHash,
+		Response:    data,
+		Header:      header,
+	})
+}
@@ -0,0 +1,239 @@
+package gmiddleware
+
+import (
+	"context"
+	"errors"
+	"github.com/markgregr/bestHack_support_gRPC_server/internal/adapters/db/redis"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	createMethod = "/tasks.TaskService/CreateTask"
+	fireMethod   = "/tasks.TaskService/FireTask"
+	getMethod    = "/tasks.TaskService/GetTask"
+)
+
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string][]byte
+	locks     map[string]bool
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		responses: make(map[string][]byte),
+		locks:     make(map[string]bool),
+	}
+}
+
+func (s *memoryIdempotencyStore) IdempotentResponse(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.responses[key]
+	if !ok {
+		return nil, redis.ErrIdempotencyKeyNotFound
+	}
+	return data, nil
+}
+
+func (s *memoryIdempotencyStore) LockIdempotencyKey(_ context.Context, key string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[key] {
+		return false, nil
+	}
+	s.locks[key] = true
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) ExtendIdempotencyKey(_ context.Context, key string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locks[key], nil
+}
+
+func (s *memoryIdempotencyStore) UnlockIdempotencyKey(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, key)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) SaveIdempotentResponse(_ context.Context, key string, data []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[key] = data
+	return nil
+}
+
+// transportStream запоминает заголовки, которые интерсептор отправляет клиенту
+type transportStream struct {
+	method string
+	header metadata.MD
+}
+
+func (s *transportStream) Method() string { return s.method }
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *transportStream) SetTrailer(metadata.MD) error { return nil }
+
+type call struct {
+	method string
+	userID int64
+	key    string
+	req    string
+	fail   bool
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	longKey := strings.Repeat("k", maxIdempotencyKeyLength+1)
+
+	tests := []struct {
+		name string
+		// первый вызов выполняется всегда, second повторяет его с изменениями
+		first       call
+		second      call
+		wantHandled int
+		wantCode    codes.Code
+		wantReplay  bool
+	}{
+		{
+			name:        "same request is replayed",
+			first:       call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			second:      call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			wantHandled: 1,
+			wantReplay:  true,
+		},
+		{
+			name:        "other request with the same key",
+			first:       call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			second:      call{method: createMethod, userID: 1, key: "k1", req: "b"},
+			wantHandled: 1,
+			wantCode:    codes.InvalidArgument,
+		},
+		{
+			name:        "key is scoped by method",
+			first:       call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			second:      call{method: fireMethod, userID: 1, key: "k1", req: "a"},
+			wantHandled: 2,
+		},
+		{
+			name:        "key is scoped by user",
+			first:       call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			second:      call{method: createMethod, userID: 2, key: "k1", req: "a"},
+			wantHandled: 2,
+		},
+		{
+			name:        "errors are not saved",
+			first:       call{method: createMethod, userID: 1, key: "k1", req: "a", fail: true},
+			second:      call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			wantHandled: 2,
+		},
+		{
+			name:        "method is not idempotent",
+			first:       call{method: getMethod, userID: 1, key: "k1", req: "a"},
+			second:      call{method: getMethod, userID: 1, key: "k1", req: "a"},
+			wantHandled: 2,
+		},
+		{
+			name:        "request without key",
+			first:       call{method: createMethod, userID: 1, req: "a"},
+			second:      call{method: createMethod, userID: 1, req: "a"},
+			wantHandled: 2,
+		},
+		{
+			name:        "too long key",
+			first:       call{method: createMethod, userID: 1, key: "k1", req: "a"},
+			second:      call{method: createMethod, userID: 1, key: longKey, req: "a"},
+			wantHandled: 1,
+			wantCode:    codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+			idempotency := NewIdempotencyInterceptor(log, newMemoryIdempotencyStore(), time.Hour, time.Minute)
+			interceptor := idempotency.UnaryServerInterceptor(createMethod, fireMethod)
+
+			handled := 0
+			invoke := func(c call) (interface{}, *transportStream, error) {
+				stream := &transportStream{method: c.method}
+				ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("app_id", "1", IdempotencyKeyHeader, c.key))
+				ctx = context.WithValue(ctx, "userID", c.userID)
+
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					handled++
+					if c.fail {
+						return nil, status.Error(codes.Unavailable, "try again")
+					}
+					if err := grpc.SetHeader(ctx, metadata.Pairs("task_version", "7")); err != nil {
+						return nil, err
+					}
+					return wrapperspb.String("response to " + req.(*wrapperspb.StringValue).GetValue()), nil
+				}
+
+				resp, err := interceptor(ctx, wrapperspb.String(c.req), &grpc.UnaryServerInfo{FullMethod: c.method}, handler)
+				return resp, stream, err
+			}
+
+			first, _, err := invoke(tt.first)
+			if tt.first.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			second, stream, err := invoke(tt.second)
+			assert.Equal(t, tt.wantHandled, handled)
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "response to "+tt.second.req, second.(*wrapperspb.StringValue).GetValue())
+			assert.Equal(t, []string{"7"}, stream.header.Get("task_version"), "headers are sent on replay too")
+			if tt.wantReplay {
+				assert.True(t, proto.Equal(first.(proto.Message), second.(proto.Message)))
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	store := newMemoryIdempotencyStore()
+	interceptor := NewIdempotencyInterceptor(log, store, time.Hour, time.Minute).UnaryServerInterceptor(createMethod)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("app_id", "1", IdempotencyKeyHeader, "k1"))
+	ctx = context.WithValue(ctx, "userID", int64(1))
+	store.locks[idempotencyStoreKey("1", 1, createMethod, "k1")] = true
+
+	_, err := interceptor(ctx, wrapperspb.String("a"), &grpc.UnaryServerInfo{FullMethod: createMethod}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("handler must not run")
+	})
+
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
@@ -0,0 +1,40 @@
+package gmiddleware
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExpectedVersionHeader заголовок метаданных, в котором клиент передаёт версию изменяемой задачи
+const ExpectedVersionHeader = "expected_version"
+
+// ExpectedVersionUnaryInterceptor кладёт в контекст версию задачи, которую ожидает клиент;
+// без заголовка запрос выполняется без проверки версии
+func ExpectedVersionUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withExpectedVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func withExpectedVersion(ctx context.Context) (context.Context, error) {
+	value := metautils.ExtractIncoming(ctx).Get(ExpectedVersionHeader)
+	if value == "" {
+		return ctx, nil
+	}
+
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return ctx, status.Errorf(codes.InvalidArgument, "%s is invalid", ExpectedVersionHeader)
+	}
+
+	return context.WithValue(ctx, "expectedVersion", version), nil
+}